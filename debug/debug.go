@@ -5,15 +5,14 @@ import (
 )
 
 type Debugger struct {
-	CPU         *goboy.CPU
+	Emulator    *goboy.Emulator
 	Breakpoints map[uint16]struct{}
 }
 
-func (d Debugger) StepToNextBreakpoint(sink chan<- [160 * 144]uint8) {
+func (d Debugger) StepToNextBreakpoint() {
 	for {
-		if _, found := d.Breakpoints[d.CPU.PC]; !found {
-			cycles := d.CPU.RunSingleOpcode()
-			d.CPU.Memory.GPU.Run(cycles, sink)
+		if _, found := d.Breakpoints[d.Emulator.CPU.PC]; !found {
+			d.Emulator.StepInstruction()
 		} else {
 			break
 		}
@@ -21,10 +20,10 @@ func (d Debugger) StepToNextBreakpoint(sink chan<- [160 * 144]uint8) {
 }
 
 func (d Debugger) TranslateOpcode(addr uint16) DecodedInsturction {
-	opcode := d.CPU.Memory.Read(addr)
+	opcode := d.Emulator.CPU.Memory.Read(addr)
 	if opcode == 0xCB {
 		return DecodedInsturction{
-			Instruction: bitInstructions[d.CPU.Memory.Read(addr+1)],
+			Instruction: bitInstructions[d.Emulator.CPU.Memory.Read(addr+1)],
 			Addr:        addr,
 		}
 	}
@@ -36,7 +35,7 @@ func (d Debugger) TranslateOpcode(addr uint16) DecodedInsturction {
 			Addr:        addr,
 		}
 	case 2:
-		data := d.CPU.Memory.Read(addr + 1)
+		data := d.Emulator.CPU.Memory.Read(addr + 1)
 		return DecodedInsturction{
 			Instruction: instr,
 			Data:        uint16(data),
@@ -44,8 +43,8 @@ func (d Debugger) TranslateOpcode(addr uint16) DecodedInsturction {
 			HasData:     true,
 		}
 	case 3:
-		low := d.CPU.Memory.Read(addr + 1)
-		high := d.CPU.Memory.Read(addr + 2)
+		low := d.Emulator.CPU.Memory.Read(addr + 1)
+		high := d.Emulator.CPU.Memory.Read(addr + 2)
 		val := uint16(low) | uint16(high)<<8
 		return DecodedInsturction{
 			Instruction: instr,
@@ -60,7 +59,7 @@ func (d Debugger) TranslateOpcode(addr uint16) DecodedInsturction {
 
 func (d Debugger) DecodeROM() ([]DecodedInsturction, map[uint16]int) {
 	// addrToSearch := []uint16{0x0, 0x8, 0x10, 0x18, 0x20, 0x28, 0x30, 0x38, 0x100}
-	addrToSearch := []uint16{d.CPU.PC}
+	addrToSearch := []uint16{d.Emulator.CPU.PC}
 	decoded := make(map[uint16]DecodedInsturction)
 	var addr uint16
 	for len(addrToSearch) > 0 {
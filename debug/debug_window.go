@@ -10,7 +10,7 @@ import (
 	"github.com/MatiasLyyra/goboy/goboy"
 )
 
-func StartDebugger(d Debugger, sink chan<- [160 * 144]uint8) {
+func StartDebugger(d Debugger) {
 	scan := bufio.NewReader(os.Stdin)
 	for {
 		decoded, lookup := d.DecodeROM()
@@ -27,10 +27,10 @@ func StartDebugger(d Debugger, sink chan<- [160 * 144]uint8) {
 		case "v", "view":
 			printSnippet(d, decoded, lookup)
 		case "s", "step":
-			runSingleStep(d, sink)
+			d.Emulator.StepInstruction()
 			printSnippet(d, decoded, lookup)
 		case "r", "run":
-			d.StepToNextBreakpoint(sink)
+			d.StepToNextBreakpoint()
 			decoded, lookup := d.DecodeROM()
 			printSnippet(d, decoded, lookup)
 		case "b", "break":
@@ -42,7 +42,7 @@ func StartDebugger(d Debugger, sink chan<- [160 * 144]uint8) {
 					d.ToggleBreakpoint(uint16(val))
 				}
 			} else {
-				d.ToggleBreakpoint(d.CPU.PC)
+				d.ToggleBreakpoint(d.Emulator.CPU.PC)
 			}
 			printSnippet(d, decoded, lookup)
 		case "read":
@@ -53,7 +53,7 @@ func StartDebugger(d Debugger, sink chan<- [160 * 144]uint8) {
 			if err != nil || val >= (1<<16) {
 				fmt.Println("invalid value")
 			} else {
-				fmt.Printf("%02X\n", d.CPU.Memory.Read(uint16(val)))
+				fmt.Printf("%02X\n", d.Emulator.CPU.Memory.Read(uint16(val)))
 			}
 		case "write":
 			if len(options) < 2 {
@@ -69,9 +69,9 @@ func StartDebugger(d Debugger, sink chan<- [160 * 144]uint8) {
 				fmt.Println("invalid value")
 				continue
 			}
-			d.CPU.Memory.Write(uint16(addr), uint8(val))
+			d.Emulator.CPU.Memory.Write(uint16(addr), uint8(val))
 		case "mbc1":
-			mbc1, ok := d.CPU.Memory.Cartridge.MBC.(*goboy.MBC1)
+			mbc1, ok := d.Emulator.CPU.Memory.Cartridge.MBC.(*goboy.MBC1)
 			if len(options) == 0 || !ok {
 				if !ok {
 					fmt.Println("Not MBC1")
@@ -90,15 +90,10 @@ func StartDebugger(d Debugger, sink chan<- [160 * 144]uint8) {
 	}
 }
 
-func runSingleStep(d Debugger, sink chan<- [160 * 144]uint8) {
-	cycles := d.CPU.RunSingleOpcode()
-	d.CPU.Memory.GPU.Run(cycles, sink)
-}
-
 func printSnippet(d Debugger, decoded []DecodedInsturction, lookup map[uint16]int) {
-	startAddr := d.CPU.PC
+	startAddr := d.Emulator.CPU.PC
 	ops := []DecodedInsturction{
-		decoded[lookup[d.CPU.PC]],
+		decoded[lookup[d.Emulator.CPU.PC]],
 	}
 outer:
 	for i := 0; i < 5; i++ {
@@ -114,7 +109,7 @@ outer:
 			}
 		}
 	}
-	currentOP := decoded[lookup[d.CPU.PC]]
+	currentOP := decoded[lookup[d.Emulator.CPU.PC]]
 	for i := 0; i < 5; i++ {
 		addr := currentOP.Addr + uint16(currentOP.Len)
 		op := decoded[lookup[addr]]
@@ -123,7 +118,7 @@ outer:
 	}
 	var reg int
 	for _, op := range ops {
-		if op.Addr == d.CPU.PC {
+		if op.Addr == d.Emulator.CPU.PC {
 			fmt.Print("> ")
 		} else if _, found := d.Breakpoints[op.Addr]; found {
 			fmt.Print("* ")
@@ -133,17 +128,17 @@ outer:
 		fmt.Printf("%04X: %v", op.Addr, op)
 		switch reg {
 		case 0:
-			fmt.Printf("\t\tAF: $%04X", d.CPU.AF())
+			fmt.Printf("\t\tAF: $%04X", d.Emulator.CPU.AF())
 		case 1:
-			fmt.Printf("\t\tBC: $%04X", d.CPU.BC())
+			fmt.Printf("\t\tBC: $%04X", d.Emulator.CPU.BC())
 		case 2:
-			fmt.Printf("\t\tDE: $%04X", d.CPU.DE())
+			fmt.Printf("\t\tDE: $%04X", d.Emulator.CPU.DE())
 		case 3:
-			fmt.Printf("\t\tHL: $%04X", d.CPU.HL())
+			fmt.Printf("\t\tHL: $%04X", d.Emulator.CPU.HL())
 		case 4:
-			fmt.Printf("\t\tSP: $%04X", d.CPU.SP)
+			fmt.Printf("\t\tSP: $%04X", d.Emulator.CPU.SP)
 		case 5:
-			fmt.Printf("\t\tPC: $%04X", d.CPU.PC)
+			fmt.Printf("\t\tPC: $%04X", d.Emulator.CPU.PC)
 		}
 		reg++
 		fmt.Println()
@@ -51,7 +51,7 @@ func init() {
 		Instruction{OP: "DEC C", Len: 1},
 		Instruction{OP: "LD C,$%02X", Len: 2},
 		Instruction{OP: "RRC A", Len: 1},
-		Instruction{OP: "STOP", Len: 1, Stop: true},
+		Instruction{OP: "STOP", Len: 2, Stop: true},
 		Instruction{OP: "LD DE,$%04X", Len: 3},
 		Instruction{OP: "LD (DE),A", Len: 1},
 		Instruction{OP: "INC DE", Len: 1},
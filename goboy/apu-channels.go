@@ -0,0 +1,261 @@
+package goboy
+
+// Waveforms of square channels for each duty setting, one bit per step
+var dutyPatterns = [4]uint8{
+	0b00000001, // 12.5%
+	0b10000001, // 25%
+	0b10000111, // 50%
+	0b01111110, // 75%
+}
+
+// Noise channel timer periods for each divisor code
+var noiseDivisors = [8]int{8, 16, 32, 48, 64, 80, 96, 112}
+
+// lengthCounter turns a channel off after the set amount of 256Hz clocks
+type lengthCounter struct {
+	enabled bool
+	counter int
+	max     int
+}
+
+func (l *lengthCounter) load(length int) {
+	l.counter = l.max - length
+}
+
+func (l *lengthCounter) trigger() {
+	if l.counter == 0 {
+		l.counter = l.max
+	}
+}
+
+// clock returns false when the channel should be turned off
+func (l *lengthCounter) clock() bool {
+	if !l.enabled || l.counter == 0 {
+		return true
+	}
+	l.counter--
+	return l.counter != 0
+}
+
+// envelope changes channel volume periodically at 64Hz
+type envelope struct {
+	initial uint8
+	add     bool
+	period  uint8
+	volume  uint8
+	timer   uint8
+}
+
+func (e *envelope) write(data uint8) {
+	e.initial = data >> 4
+	e.add = data&Bit3 != 0
+	e.period = data & 0x7
+}
+
+// dacEnabled reports whether the upper 5 bits of envelope register are set
+func (e *envelope) dacEnabled() bool {
+	return e.initial != 0 || e.add
+}
+
+func (e *envelope) trigger() {
+	e.volume = e.initial
+	e.timer = e.period
+	if e.timer == 0 {
+		e.timer = 8
+	}
+}
+
+func (e *envelope) clock() {
+	if e.period == 0 {
+		return
+	}
+	e.timer--
+	if e.timer > 0 {
+		return
+	}
+	e.timer = e.period
+	if e.add && e.volume < 15 {
+		e.volume++
+	} else if !e.add && e.volume > 0 {
+		e.volume--
+	}
+}
+
+// squareChannel is a square wave generator used by channels 1 and 2.
+// Channel 1 additionally has frequency sweep.
+type squareChannel struct {
+	enabled   bool
+	duty      uint8
+	dutyStep  uint8
+	frequency uint16
+	timer     int
+	length    lengthCounter
+	env       envelope
+
+	hasSweep     bool
+	sweepPeriod  uint8
+	sweepNegate  bool
+	sweepShift   uint8
+	sweepTimer   uint8
+	sweepEnabled bool
+	shadowFreq   uint16
+}
+
+func (c *squareChannel) period() int {
+	return (2048 - int(c.frequency)) * 4
+}
+
+func (c *squareChannel) trigger() {
+	c.enabled = c.env.dacEnabled()
+	c.length.trigger()
+	c.timer = c.period()
+	c.env.trigger()
+	if !c.hasSweep {
+		return
+	}
+	c.shadowFreq = c.frequency
+	c.reloadSweepTimer()
+	c.sweepEnabled = c.sweepPeriod != 0 || c.sweepShift != 0
+	if c.sweepShift != 0 {
+		c.sweepFrequency()
+	}
+}
+
+func (c *squareChannel) reloadSweepTimer() {
+	c.sweepTimer = c.sweepPeriod
+	if c.sweepTimer == 0 {
+		c.sweepTimer = 8
+	}
+}
+
+// sweepFrequency calculates the next frequency and disables the channel on overflow
+func (c *squareChannel) sweepFrequency() uint16 {
+	delta := c.shadowFreq >> c.sweepShift
+	freq := c.shadowFreq + delta
+	if c.sweepNegate {
+		freq = c.shadowFreq - delta
+	}
+	if freq > 2047 {
+		c.enabled = false
+	}
+	return freq
+}
+
+func (c *squareChannel) clockSweep() {
+	c.sweepTimer--
+	if c.sweepTimer > 0 {
+		return
+	}
+	c.reloadSweepTimer()
+	if !c.sweepEnabled || c.sweepPeriod == 0 {
+		return
+	}
+	freq := c.sweepFrequency()
+	if freq <= 2047 && c.sweepShift != 0 {
+		c.shadowFreq = freq
+		c.frequency = freq
+		c.sweepFrequency()
+	}
+}
+
+func (c *squareChannel) run(cycles int) {
+	c.timer -= cycles
+	for c.timer <= 0 {
+		c.timer += c.period()
+		c.dutyStep = (c.dutyStep + 1) & 0x7
+	}
+}
+
+func (c *squareChannel) output() uint8 {
+	if !c.enabled || dutyPatterns[c.duty]&(1<<c.dutyStep) == 0 {
+		return 0
+	}
+	return c.env.volume
+}
+
+// waveChannel plays 32 4-bit samples from wave RAM
+type waveChannel struct {
+	enabled    bool
+	dacEnabled bool
+	volumeCode uint8
+	frequency  uint16
+	timer      int
+	position   uint8
+	sample     uint8
+	length     lengthCounter
+	ram        [16]uint8
+}
+
+func (c *waveChannel) period() int {
+	return (2048 - int(c.frequency)) * 2
+}
+
+func (c *waveChannel) trigger() {
+	c.enabled = c.dacEnabled
+	c.length.trigger()
+	c.timer = c.period()
+	c.position = 0
+}
+
+func (c *waveChannel) run(cycles int) {
+	c.timer -= cycles
+	for c.timer <= 0 {
+		c.timer += c.period()
+		c.position = (c.position + 1) & 0x1F
+		c.sample = c.ram[c.position/2]
+		if c.position&1 == 0 {
+			c.sample >>= 4
+		}
+		c.sample &= 0xF
+	}
+}
+
+func (c *waveChannel) output() uint8 {
+	if !c.enabled || c.volumeCode == 0 {
+		return 0
+	}
+	return c.sample >> (c.volumeCode - 1)
+}
+
+// noiseChannel outputs pseudo random noise from a linear feedback shift register
+type noiseChannel struct {
+	enabled     bool
+	clockShift  uint8
+	widthMode   bool
+	divisorCode uint8
+	timer       int
+	lfsr        uint16
+	length      lengthCounter
+	env         envelope
+}
+
+func (c *noiseChannel) period() int {
+	return noiseDivisors[c.divisorCode] << c.clockShift
+}
+
+func (c *noiseChannel) trigger() {
+	c.enabled = c.env.dacEnabled()
+	c.length.trigger()
+	c.timer = c.period()
+	c.env.trigger()
+	c.lfsr = 0x7FFF
+}
+
+func (c *noiseChannel) run(cycles int) {
+	c.timer -= cycles
+	for c.timer <= 0 {
+		c.timer += c.period()
+		xor := (c.lfsr & 1) ^ ((c.lfsr >> 1) & 1)
+		c.lfsr = (c.lfsr >> 1) | (xor << 14)
+		if c.widthMode {
+			c.lfsr = c.lfsr&^(1<<6) | xor<<6
+		}
+	}
+}
+
+func (c *noiseChannel) output() uint8 {
+	if !c.enabled || c.lfsr&1 != 0 {
+		return 0
+	}
+	return c.env.volume
+}
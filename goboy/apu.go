@@ -0,0 +1,370 @@
+package goboy
+
+import "math"
+
+// Sound registers
+const (
+	AddrNR10 = 0xFF10
+	AddrNR11 = 0xFF11
+	AddrNR12 = 0xFF12
+	AddrNR13 = 0xFF13
+	AddrNR14 = 0xFF14
+	AddrNR21 = 0xFF16
+	AddrNR22 = 0xFF17
+	AddrNR23 = 0xFF18
+	AddrNR24 = 0xFF19
+	AddrNR30 = 0xFF1A
+	AddrNR31 = 0xFF1B
+	AddrNR32 = 0xFF1C
+	AddrNR33 = 0xFF1D
+	AddrNR34 = 0xFF1E
+	AddrNR41 = 0xFF20
+	AddrNR42 = 0xFF21
+	AddrNR43 = 0xFF22
+	AddrNR44 = 0xFF23
+	AddrNR50 = 0xFF24
+	AddrNR51 = 0xFF25
+	AddrNR52 = 0xFF26
+
+	SoundStart   = 0xFF10
+	SoundEnd     = 0xFF3F
+	WaveRAMStart = 0xFF30
+	WaveRAMEnd   = 0xFF3F
+)
+
+const (
+	// DefaultSampleRate is the output sample rate used unless changed with SetSampleRate
+	DefaultSampleRate = 44100
+	// Frame sequencer runs at 512Hz
+	frameSequencerPeriod = ClockSpeed / 512
+)
+
+// Bits that always read as 1 in sound registers 0xFF10-0xFF2F
+var soundReadMasks = [0x20]uint8{
+	0x80, 0x3F, 0x00, 0xFF, 0xBF, // NR10-NR14
+	0xFF, 0x3F, 0x00, 0xFF, 0xBF, // NR20-NR24
+	0x7F, 0xFF, 0x9F, 0xFF, 0xBF, // NR30-NR34
+	0xFF, 0xFF, 0x00, 0x00, 0xBF, // NR40-NR44
+	0x00, 0x00, 0x70, // NR50-NR52
+	0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF,
+}
+
+// APU generates sound from the two square, wave and noise channels. Samples
+// are produced in stereo at the configured sample rate.
+type APU struct {
+	powered bool
+	regs    [0x20]uint8
+
+	ch1 squareChannel
+	ch2 squareChannel
+	ch3 waveChannel
+	ch4 noiseChannel
+
+	frameSeqTimer int
+	frameStep     uint8
+
+	sampleRate    int
+	sampleCounter int
+	// High-pass filter removing DC offset like the capacitors of real hardware
+	capacitorL      float64
+	capacitorR      float64
+	capacitorCharge float64
+	samples         []int16
+}
+
+// NewAPU creates a powered off APU
+func NewAPU() *APU {
+	a := &APU{
+		ch1:           squareChannel{hasSweep: true},
+		frameSeqTimer: frameSequencerPeriod,
+	}
+	a.ch1.length.max = 64
+	a.ch2.length.max = 64
+	a.ch3.length.max = 256
+	a.ch4.length.max = 64
+	a.SetSampleRate(DefaultSampleRate)
+	return a
+}
+
+// SetSampleRate sets the output sample rate in Hz. Rates below 1 Hz select
+// DefaultSampleRate.
+func (a *APU) SetSampleRate(rate int) {
+	if rate <= 0 {
+		rate = DefaultSampleRate
+	}
+	a.sampleRate = rate
+	a.sampleCounter = 0
+	a.capacitorCharge = math.Pow(0.999958, float64(ClockSpeed)/float64(rate))
+}
+
+// SampleRate returns the output sample rate in Hz
+func (a *APU) SampleRate() int {
+	return a.sampleRate
+}
+
+// TakeSamples returns the samples generated since the previous call,
+// interleaved left and right channel
+func (a *APU) TakeSamples() []int16 {
+	samples := a.samples
+	a.samples = make([]int16, 0, cap(samples))
+	return samples
+}
+
+func (a *APU) Read(addr uint16) uint8 {
+	if WaveRAMStart <= addr && addr <= WaveRAMEnd {
+		return a.ch3.ram[addr-WaveRAMStart]
+	}
+	if addr == AddrNR52 {
+		var status uint8
+		if a.powered {
+			status |= Bit7
+		}
+		for i, enabled := range []bool{a.ch1.enabled, a.ch2.enabled, a.ch3.enabled, a.ch4.enabled} {
+			if enabled {
+				status |= 1 << i
+			}
+		}
+		return status | soundReadMasks[addr-SoundStart]
+	}
+	return a.regs[addr-SoundStart] | soundReadMasks[addr-SoundStart]
+}
+
+func (a *APU) Write(addr uint16, data uint8) {
+	if WaveRAMStart <= addr && addr <= WaveRAMEnd {
+		a.ch3.ram[addr-WaveRAMStart] = data
+		return
+	}
+	if addr == AddrNR52 {
+		a.setPower(data&Bit7 != 0)
+		return
+	}
+	if !a.powered {
+		// Only length counters can be written while powered off
+		switch addr {
+		case AddrNR11:
+			a.ch1.length.load(int(data & 0x3F))
+		case AddrNR21:
+			a.ch2.length.load(int(data & 0x3F))
+		case AddrNR31:
+			a.ch3.length.load(int(data))
+		case AddrNR41:
+			a.ch4.length.load(int(data & 0x3F))
+		}
+		return
+	}
+	a.regs[addr-SoundStart] = data
+	switch addr {
+	case AddrNR10:
+		a.ch1.sweepPeriod = (data >> 4) & 0x7
+		a.ch1.sweepNegate = data&Bit3 != 0
+		a.ch1.sweepShift = data & 0x7
+	case AddrNR11:
+		a.ch1.duty = data >> 6
+		a.ch1.length.load(int(data & 0x3F))
+	case AddrNR12:
+		a.ch1.env.write(data)
+		if !a.ch1.env.dacEnabled() {
+			a.ch1.enabled = false
+		}
+	case AddrNR13:
+		a.ch1.frequency = a.ch1.frequency&0x700 | uint16(data)
+	case AddrNR14:
+		a.ch1.frequency = a.ch1.frequency&0xFF | uint16(data&0x7)<<8
+		a.ch1.length.enabled = data&Bit6 != 0
+		if data&Bit7 != 0 {
+			a.ch1.trigger()
+		}
+	case AddrNR21:
+		a.ch2.duty = data >> 6
+		a.ch2.length.load(int(data & 0x3F))
+	case AddrNR22:
+		a.ch2.env.write(data)
+		if !a.ch2.env.dacEnabled() {
+			a.ch2.enabled = false
+		}
+	case AddrNR23:
+		a.ch2.frequency = a.ch2.frequency&0x700 | uint16(data)
+	case AddrNR24:
+		a.ch2.frequency = a.ch2.frequency&0xFF | uint16(data&0x7)<<8
+		a.ch2.length.enabled = data&Bit6 != 0
+		if data&Bit7 != 0 {
+			a.ch2.trigger()
+		}
+	case AddrNR30:
+		a.ch3.dacEnabled = data&Bit7 != 0
+		if !a.ch3.dacEnabled {
+			a.ch3.enabled = false
+		}
+	case AddrNR31:
+		a.ch3.length.load(int(data))
+	case AddrNR32:
+		a.ch3.volumeCode = (data >> 5) & 0x3
+	case AddrNR33:
+		a.ch3.frequency = a.ch3.frequency&0x700 | uint16(data)
+	case AddrNR34:
+		a.ch3.frequency = a.ch3.frequency&0xFF | uint16(data&0x7)<<8
+		a.ch3.length.enabled = data&Bit6 != 0
+		if data&Bit7 != 0 {
+			a.ch3.trigger()
+		}
+	case AddrNR41:
+		a.ch4.length.load(int(data & 0x3F))
+	case AddrNR42:
+		a.ch4.env.write(data)
+		if !a.ch4.env.dacEnabled() {
+			a.ch4.enabled = false
+		}
+	case AddrNR43:
+		a.ch4.clockShift = data >> 4
+		a.ch4.widthMode = data&Bit3 != 0
+		a.ch4.divisorCode = data & 0x7
+	case AddrNR44:
+		a.ch4.length.enabled = data&Bit6 != 0
+		if data&Bit7 != 0 {
+			a.ch4.trigger()
+		}
+	}
+}
+
+// setPower turns the APU on or off. Turning it off clears all of the
+// registers except wave RAM and length counters.
+func (a *APU) setPower(on bool) {
+	if a.powered == on {
+		return
+	}
+	a.powered = on
+	if on {
+		a.frameStep = 0
+		return
+	}
+	lengths := [4]int{a.ch1.length.counter, a.ch2.length.counter, a.ch3.length.counter, a.ch4.length.counter}
+	waveRAM := a.ch3.ram
+	a.regs = [0x20]uint8{}
+	a.ch1 = squareChannel{hasSweep: true}
+	a.ch2 = squareChannel{}
+	a.ch3 = waveChannel{ram: waveRAM}
+	a.ch4 = noiseChannel{}
+	a.ch1.length = lengthCounter{counter: lengths[0], max: 64}
+	a.ch2.length = lengthCounter{counter: lengths[1], max: 64}
+	a.ch3.length = lengthCounter{counter: lengths[2], max: 256}
+	a.ch4.length = lengthCounter{counter: lengths[3], max: 64}
+}
+
+// Run advances the APU by the given amount of clock cycles
+func (a *APU) Run(cycles int) {
+	for cycles > 0 {
+		step := cycles
+		if a.frameSeqTimer < step {
+			step = a.frameSeqTimer
+		}
+		untilSample := (ClockSpeed - a.sampleCounter + a.sampleRate - 1) / a.sampleRate
+		if untilSample < step {
+			step = untilSample
+		}
+		if a.powered {
+			a.ch1.run(step)
+			a.ch2.run(step)
+			a.ch3.run(step)
+			a.ch4.run(step)
+		}
+		a.frameSeqTimer -= step
+		if a.frameSeqTimer == 0 {
+			a.frameSeqTimer = frameSequencerPeriod
+			if a.powered {
+				a.clockFrameSequencer()
+			}
+		}
+		a.sampleCounter += step * a.sampleRate
+		if a.sampleCounter >= ClockSpeed {
+			a.sampleCounter -= ClockSpeed
+			a.mix()
+		}
+		cycles -= step
+	}
+}
+
+// clockFrameSequencer clocks length counters at 256Hz, sweep at 128Hz and
+// envelopes at 64Hz
+func (a *APU) clockFrameSequencer() {
+	switch a.frameStep {
+	case 0, 4:
+		a.clockLengths()
+	case 2, 6:
+		a.clockLengths()
+		a.ch1.clockSweep()
+	case 7:
+		a.ch1.env.clock()
+		a.ch2.env.clock()
+		a.ch4.env.clock()
+	}
+	a.frameStep = (a.frameStep + 1) & 0x7
+}
+
+func (a *APU) clockLengths() {
+	if !a.ch1.length.clock() {
+		a.ch1.enabled = false
+	}
+	if !a.ch2.length.clock() {
+		a.ch2.enabled = false
+	}
+	if !a.ch3.length.clock() {
+		a.ch3.enabled = false
+	}
+	if !a.ch4.length.clock() {
+		a.ch4.enabled = false
+	}
+}
+
+// dacOutput converts digital channel output 0-15 into analog value between -1 and 1
+func dacOutput(sample uint8, enabled bool) float64 {
+	if !enabled {
+		return 0
+	}
+	return float64(sample)/7.5 - 1
+}
+
+// mix combines channel outputs into a stereo sample according to NR50 and NR51
+func (a *APU) mix() {
+	// Don't grow without limit if samples aren't consumed
+	if len(a.samples) >= 2*a.sampleRate {
+		return
+	}
+	var left, right float64
+	if a.powered {
+		outputs := [4]float64{
+			dacOutput(a.ch1.output(), a.ch1.env.dacEnabled()),
+			dacOutput(a.ch2.output(), a.ch2.env.dacEnabled()),
+			dacOutput(a.ch3.output(), a.ch3.dacEnabled),
+			dacOutput(a.ch4.output(), a.ch4.env.dacEnabled()),
+		}
+		nr50 := a.regs[AddrNR50-SoundStart]
+		nr51 := a.regs[AddrNR51-SoundStart]
+		for i, out := range outputs {
+			if nr51&(1<<(i+4)) != 0 {
+				left += out
+			}
+			if nr51&(1<<i) != 0 {
+				right += out
+			}
+		}
+		left *= float64((nr50>>4)&0x7+1) / 32
+		right *= float64(nr50&0x7+1) / 32
+	}
+	left, a.capacitorL = highPass(left, a.capacitorL, a.capacitorCharge)
+	right, a.capacitorR = highPass(right, a.capacitorR, a.capacitorCharge)
+	a.samples = append(a.samples, toSample(left), toSample(right))
+}
+
+func highPass(in, capacitor, charge float64) (float64, float64) {
+	out := in - capacitor
+	return out, in - out*charge
+}
+
+func toSample(val float64) int16 {
+	if val > 1 {
+		val = 1
+	} else if val < -1 {
+		val = -1
+	}
+	return int16(val * math.MaxInt16)
+}
@@ -0,0 +1,173 @@
+package goboy
+
+import (
+	"testing"
+)
+
+func poweredAPU() *APU {
+	a := NewAPU()
+	a.Write(AddrNR52, 0x80)
+	a.Write(AddrNR50, 0x77)
+	return a
+}
+
+func TestAPURegisterReads(t *testing.T) {
+	tests := []struct {
+		addr uint16
+		data uint8
+		want uint8
+	}{
+		{AddrNR10, 0x00, 0x80},
+		{AddrNR11, 0x85, 0xBF},
+		{AddrNR12, 0x5A, 0x5A},
+		{AddrNR13, 0x12, 0xFF},
+		{AddrNR14, 0x47, 0xFF},
+		{AddrNR14, 0x07, 0xBF},
+		{AddrNR30, 0x00, 0x7F},
+		{AddrNR32, 0x20, 0xBF},
+		{AddrNR43, 0x5C, 0x5C},
+		{AddrNR51, 0xA5, 0xA5},
+		{0xFF15, 0x12, 0xFF},
+		{0xFF27, 0x12, 0xFF},
+		{WaveRAMStart, 0x12, 0x12},
+		{WaveRAMEnd, 0xFE, 0xFE},
+	}
+	a := poweredAPU()
+	for _, tt := range tests {
+		a.Write(tt.addr, tt.data)
+		if got := a.Read(tt.addr); got != tt.want {
+			t.Errorf("wrote %02X to %04X, read %02X, want %02X", tt.data, tt.addr, got, tt.want)
+		}
+	}
+}
+
+func TestAPUPowerOff(t *testing.T) {
+	a := poweredAPU()
+	a.Write(AddrNR12, 0xF0)
+	a.Write(AddrNR14, 0x80)
+	a.Write(AddrNR51, 0xFF)
+	a.Write(WaveRAMStart, 0x5A)
+	a.Write(AddrNR52, 0x00)
+	if got := a.Read(AddrNR52); got != 0x70 {
+		t.Errorf("NR52 read %02X after power off, want 70", got)
+	}
+	for _, addr := range []uint16{AddrNR12, AddrNR51} {
+		if got := a.Read(addr); got != 0x00 {
+			t.Errorf("register %04X read %02X after power off, want 00", addr, got)
+		}
+	}
+	a.Write(AddrNR50, 0x77)
+	if got := a.Read(AddrNR50); got != 0x00 {
+		t.Errorf("NR50 written while powered off, read %02X", got)
+	}
+	if got := a.Read(WaveRAMStart); got != 0x5A {
+		t.Errorf("wave RAM read %02X after power off, want 5A", got)
+	}
+	// Length counters can be loaded while powered off
+	a.Write(AddrNR21, 0x3F)
+	a.Write(AddrNR52, 0x80)
+	a.Write(AddrNR22, 0xF0)
+	a.Write(AddrNR24, 0xC0)
+	a.Run(frameSequencerPeriod)
+	if got := a.Read(AddrNR52); got != 0xF0 {
+		t.Errorf("NR52 read %02X, want channel 2 stopped by its length", got)
+	}
+}
+
+func TestAPUChannelStatus(t *testing.T) {
+	tests := []struct {
+		name   string
+		writes []bankWrite
+		cycles int
+		want   uint8
+	}{
+		{"no channels", nil, 0, 0xF0},
+		{"square 1 trigger", []bankWrite{{AddrNR12, 0xF0}, {AddrNR14, 0x80}}, 0, 0xF1},
+		{"trigger with DAC off", []bankWrite{{AddrNR12, 0x00}, {AddrNR14, 0x80}}, 0, 0xF0},
+		{"DAC off stops channel", []bankWrite{{AddrNR12, 0xF0}, {AddrNR14, 0x80}, {AddrNR12, 0x07}}, 0, 0xF0},
+		{"square 2 trigger", []bankWrite{{AddrNR22, 0x08}, {AddrNR24, 0x80}}, 0, 0xF2},
+		{"wave trigger", []bankWrite{{AddrNR30, 0x80}, {AddrNR34, 0x80}}, 0, 0xF4},
+		{"wave DAC off", []bankWrite{{AddrNR30, 0x80}, {AddrNR34, 0x80}, {AddrNR30, 0x00}}, 0, 0xF0},
+		{"noise trigger", []bankWrite{{AddrNR42, 0xF0}, {AddrNR44, 0x80}}, 0, 0xF8},
+		{"length expires", []bankWrite{{AddrNR22, 0xF0}, {AddrNR21, 0x3F}, {AddrNR24, 0xC0}}, frameSequencerPeriod, 0xF0},
+		{"length not enabled", []bankWrite{{AddrNR22, 0xF0}, {AddrNR21, 0x3F}, {AddrNR24, 0x80}}, 8 * frameSequencerPeriod, 0xF2},
+		{"length still counting", []bankWrite{{AddrNR42, 0xF0}, {AddrNR41, 0x3C}, {AddrNR44, 0xC0}}, 2 * frameSequencerPeriod, 0xF8},
+		{"length of 0 is 64", []bankWrite{{AddrNR42, 0xF0}, {AddrNR44, 0xC0}}, 128 * frameSequencerPeriod, 0xF0},
+		{"wave length is 256", []bankWrite{{AddrNR30, 0x80}, {AddrNR34, 0xC0}}, 400 * frameSequencerPeriod, 0xF4},
+		{"sweep overflow", []bankWrite{{AddrNR10, 0x11}, {AddrNR12, 0xF0}, {AddrNR13, 0xFF}, {AddrNR14, 0x87}}, 0, 0xF0},
+		{"sweep down doesn't overflow", []bankWrite{{AddrNR10, 0x19}, {AddrNR12, 0xF0}, {AddrNR13, 0xFF}, {AddrNR14, 0x87}}, 0, 0xF1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := poweredAPU()
+			for _, w := range tt.writes {
+				a.Write(w.addr, w.data)
+			}
+			a.Run(tt.cycles)
+			if got := a.Read(AddrNR52); got != tt.want {
+				t.Errorf("NR52 read %02X, want %02X", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAPUSampleRate(t *testing.T) {
+	for _, rate := range []int{DefaultSampleRate, 48000, 32768, 22050, 8000} {
+		a := poweredAPU()
+		a.SetSampleRate(rate)
+		if a.SampleRate() != rate {
+			t.Errorf("SampleRate() = %d, want %d", a.SampleRate(), rate)
+		}
+		// Half a second in small steps like the CPU runs it
+		for i := 0; i < ClockSpeed/2; i += 4 {
+			a.Run(4)
+		}
+		got := len(a.TakeSamples())
+		if got < rate-2 || got > rate+2 {
+			t.Errorf("rate %d: %d samples in half a second, want %d", rate, got, rate)
+		}
+		if len(a.TakeSamples()) != 0 {
+			t.Errorf("rate %d: samples returned twice", rate)
+		}
+	}
+	for _, rate := range []int{0, -1} {
+		a := poweredAPU()
+		a.SetSampleRate(rate)
+		a.Run(ClockSpeed / 100)
+		if a.SampleRate() != DefaultSampleRate {
+			t.Errorf("SetSampleRate(%d) selected %d Hz", rate, a.SampleRate())
+		}
+	}
+}
+
+func TestAPUPanning(t *testing.T) {
+	tests := []struct {
+		nr51        uint8
+		left, right bool
+	}{
+		{0x00, false, false},
+		{0x20, true, false},
+		{0x02, false, true},
+		{0x22, true, true},
+		// Other channels are silent
+		{0xDD, false, false},
+	}
+	for _, tt := range tests {
+		a := poweredAPU()
+		a.Write(AddrNR51, tt.nr51)
+		a.Write(AddrNR21, 0x80)
+		a.Write(AddrNR22, 0xF0)
+		a.Write(AddrNR23, 0x00)
+		a.Write(AddrNR24, 0x87)
+		a.Run(ClockSpeed / 100)
+		var left, right bool
+		samples := a.TakeSamples()
+		for i := 0; i < len(samples); i += 2 {
+			left = left || samples[i] != 0
+			right = right || samples[i+1] != 0
+		}
+		if left != tt.left || right != tt.right {
+			t.Errorf("NR51 %02X: left %v right %v, want %v %v", tt.nr51, left, right, tt.left, tt.right)
+		}
+	}
+}
@@ -0,0 +1,235 @@
+package goboy
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+)
+
+const (
+	// AddrBoot is the register unmapping boot ROM when written
+	AddrBoot = 0xFF50
+	// AddrKEY0 selects the mode of Color hardware, boot ROM sets bit 2 for
+	// cartridges that don't support Color features
+	AddrKEY0 = 0xFF4C
+)
+
+// ErrInvalidBootROM is returned when boot ROM size doesn't match the model
+var ErrInvalidBootROM = errors.New("invalid boot ROM size")
+
+// BootROMSize returns the size of the boot ROM of the model. Boot ROM of
+// Color hardware is mapped at 0x0000-0x00FF and 0x0200-0x08FF.
+func BootROMSize(model Model) int {
+	if model == ModelCGB {
+		return 0x900
+	}
+	return 0x100
+}
+
+// CPU registers A, F, B, C, D, E, H and L after boot ROM has finished
+var postBootRegisters = map[Model][8]uint8{
+	ModelDMG0: {0x01, 0x00, 0xFF, 0x13, 0x00, 0xC1, 0x84, 0x03},
+	ModelDMG:  {0x01, 0xB0, 0x00, 0x13, 0x00, 0xD8, 0x01, 0x4D},
+	ModelMGB:  {0xFF, 0xB0, 0x00, 0x13, 0x00, 0xD8, 0x01, 0x4D},
+	ModelSGB:  {0x01, 0x00, 0x00, 0x14, 0x00, 0x00, 0xC0, 0x60},
+	ModelCGB:  {0x11, 0x80, 0x00, 0x00, 0xFF, 0x56, 0x00, 0x0D},
+}
+
+// CPU registers A, F, B, C, D, E, H and L after boot ROM of Color hardware
+// has started a DMG cartridge. B, H and L depend on the cartridge, see
+// compatRegisters.
+var postBootCompatRegisters = [8]uint8{0x11, 0x80, 0x00, 0x00, 0x00, 0x08, 0x00, 0x7C}
+
+// Internal timer counter after boot ROM has finished. It depends on how long
+// boot ROM ran, which isn't known for SGB and CGB, so those are left at zero.
+var postBootCounter = map[Model]uint16{
+	ModelDMG0: 0x1830,
+	ModelDMG:  0xABCC,
+	ModelMGB:  0xABCC,
+}
+
+// Palettes boot ROM of Color hardware gives DMG cartridges that aren't in
+// its table of known games
+var (
+	compatBGColors  = [4]uint16{0x7FFF, 0x1BEF, 0x6180, 0x0000}
+	compatObjColors = [4]uint16{0x7FFF, 0x421F, 0x1CF2, 0x0000}
+)
+
+// LoadBootROM reads boot ROM of the current model from r and resets the
+// emulator to run it
+func (e *Emulator) LoadBootROM(r io.Reader) error {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	if len(data) != BootROMSize(e.Model) {
+		return ErrInvalidBootROM
+	}
+	e.BootROM = data
+	e.Reset()
+	return nil
+}
+
+// skipBoot puts CPU and I/O registers into the state boot ROM leaves them in.
+// Display position is only known for DMG models, see Display.skipBoot.
+func (e *Emulator) skipBoot() {
+	cpu, mmu := e.CPU, e.MMU
+	regs := postBootRegisters[e.Model]
+	if e.Model == ModelCGB && !colorMode(e.Model, e.Cartridge) {
+		regs = compatRegisters(e.Cartridge)
+	}
+	cpu.A, cpu.B, cpu.C, cpu.D, cpu.E, cpu.H, cpu.L = regs[0], regs[2], regs[3], regs[4], regs[5], regs[6], regs[7]
+	cpu.SetF(regs[1])
+	// DMG boot ROM leaves half carry and carry set unless header checksum is 0
+	if (e.Model == ModelDMG || e.Model == ModelMGB) && e.Cartridge.Header.HeaderChecksum == 0 {
+		cpu.FHalfCarry = false
+		cpu.FCarry = false
+	}
+	cpu.PC = 0x0100
+	cpu.SP = 0xFFFE
+
+	mmu.Timer.counter = postBootCounter[e.Model]
+	mmu.Pad.Set(0xCF)
+	mmu.ifReg.RawSet(0xE1)
+	gpu := mmu.GPU
+	gpu.lcdc.RawSet(0x91)
+	gpu.bgp.RawSet(0xFC)
+	gpu.skipBoot(e.Model)
+	if e.Model != ModelCGB {
+		mmu.io[AddrDMA-IOPortsStart].RawSet(0xFF)
+	}
+	if e.Model == ModelCGB {
+		if colorMode(e.Model, e.Cartridge) {
+			mmu.key0.RawSet(uint8(e.Cartridge.GCBFlag()))
+			// Background palettes are set to white
+			for i := range gpu.bgColors.ram {
+				gpu.bgColors.ram[i] = 0xFF
+			}
+		} else {
+			mmu.key0.RawSet(key0Compat)
+			mmu.setColorMode(false)
+			gpu.bgColors.setColors(0, compatBGColors)
+			gpu.objColors.setColors(0, compatObjColors)
+			gpu.objColors.setColors(1, compatObjColors)
+		}
+	}
+	mmu.APU.skipBoot(e.Model)
+}
+
+// compatRegisters returns the CPU registers boot ROM of Color hardware leaves
+// for a DMG cartridge. B is the sum of title bytes for cartridges licensed by
+// Nintendo, which is also used to pick the palette. HL points to the end of
+// the logo in the tile map if the sum matches one of two special cases.
+func compatRegisters(cart *Cartridge) [8]uint8 {
+	regs := postBootCompatRegisters
+	header := cart.Header
+	if header.OldLicenseeCode == 0x01 || header.OldLicenseeCode == 0x33 && header.NewLicenseeCode == "01" {
+		var sum uint8
+		for _, b := range cart.Bank0[0x134:0x144] {
+			sum += b
+		}
+		regs[2] = sum
+	}
+	if regs[2] == 0x43 || regs[2] == 0x58 {
+		regs[6], regs[7] = 0x99, 0x1A
+	}
+	return regs
+}
+
+// skipBoot puts the display at the point of the frame where boot ROM
+// finishes. DMG boot ROM ends in VBlank after LY has changed to 0 on the last
+// row, the one of the original DMG on row 145. Where boot ROMs of SGB and CGB
+// end isn't known, so the display starts from the beginning of the frame.
+func (d *Display) skipBoot(model Model) {
+	switch model {
+	case ModelDMG0:
+		d.cycles = 145 * (OAMDuration + TransferDuration + HBlankDuration)
+	case ModelDMG, ModelMGB:
+		d.cycles = 153*(OAMDuration+TransferDuration+HBlankDuration) + lastRowLYDuration
+	default:
+		return
+	}
+	// VBlank has already started, so running the display only updates LY
+	// and STAT
+	d.stat.RawSet(ModeVBlank)
+	d.Run(0)
+}
+
+// skipBoot sets sound registers to the values boot ROM leaves them in. The
+// boot sound on channel 1 has faded out but the channel is still on, except
+// on SGB which doesn't play the sound.
+func (a *APU) skipBoot(model Model) {
+	a.Write(AddrNR52, 0x80)
+	nr14 := uint8(0xBF)
+	if model == ModelSGB {
+		nr14 = 0x3F
+	}
+	regs := []struct {
+		addr uint16
+		data uint8
+	}{
+		{AddrNR10, 0x80}, {AddrNR11, 0xBF}, {AddrNR12, 0xF3}, {AddrNR13, 0xFF}, {AddrNR14, nr14},
+		{AddrNR21, 0x3F}, {AddrNR22, 0x00}, {AddrNR23, 0xFF}, {AddrNR24, 0xBF},
+		{AddrNR30, 0x7F}, {AddrNR31, 0xFF}, {AddrNR32, 0x9F}, {AddrNR33, 0xFF}, {AddrNR34, 0xBF},
+		{AddrNR41, 0xFF}, {AddrNR42, 0x00}, {AddrNR43, 0x00}, {AddrNR44, 0xBF},
+		{AddrNR50, 0x77}, {AddrNR51, 0xF3},
+	}
+	for _, reg := range regs {
+		a.Write(reg.addr, reg.data)
+	}
+	a.ch1.env.volume = 0
+}
+
+// setBootROM maps boot ROM over the start of cartridge ROM
+func (mmu *MMU) setBootROM(rom []byte) {
+	mmu.bootROM = rom
+	mmu.BootEnabled = true
+	mmu.mapBootROM()
+}
+
+// disableBootROM unmaps boot ROM when bit 0 is written to 0xFF50, it can't
+// be mapped again without a reset. Color hardware switches to compatibility
+// mode at the same time if it's selected in KEY0.
+func (mmu *MMU) disableBootROM(data uint8) {
+	if data&Bit0 != 0 && mmu.BootEnabled {
+		mmu.BootEnabled = false
+		mmu.mapBootROM()
+		if mmu.key0 != nil && mmu.key0.value&key0Compat != 0 {
+			mmu.setColorMode(false)
+		}
+	}
+}
+
+// KEY0 bit selecting DMG compatibility mode
+const key0Compat = Bit2
+
+// modeRegister is KEY0, it's locked once boot ROM is unmapped
+type modeRegister struct {
+	mmu   *MMU
+	value uint8
+}
+
+func (r *modeRegister) RawSet(data uint8) { r.value = data }
+func (r *modeRegister) Get() uint8        { return r.value }
+func (r *modeRegister) Set(data uint8) {
+	if r.mmu.BootEnabled {
+		r.value = data
+	}
+}
+
+// mapBootROM maps pages covered by boot ROM to either boot ROM or
+// cartridge. Cartridge header at 0x0100-0x01FF is never covered.
+func (mmu *MMU) mapBootROM() {
+	for i := 0; i<<8 < BootROMSize(ModelCGB); i++ {
+		p := &mmu.pages[i]
+		if mmu.BootEnabled && i != 1 && i<<8 < len(mmu.bootROM) {
+			p.read = mmu.readBootROM
+		} else {
+			p.read = mmu.Cartridge.Read
+		}
+	}
+}
+
+func (mmu *MMU) readBootROM(addr uint16) uint8 {
+	return mmu.bootROM[addr]
+}
@@ -0,0 +1,245 @@
+package goboy
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestPostBootState(t *testing.T) {
+	tests := []struct {
+		model   Model
+		flag    CGB
+		af      uint16
+		bc, hl  uint16
+		counter uint16
+		dma     uint8
+		nr52    uint8
+		key0    uint8
+		compat  bool
+		// LY and STAT, zero where the display position isn't known
+		ly, stat uint8
+	}{
+		{ModelDMG0, GB, 0x0100, 0xFF13, 0x8403, 0x1830, 0xFF, 0xF1, 0, false, 0x91, 0x81},
+		{ModelDMG, GB, 0x01B0, 0x0013, 0x014D, 0xABCC, 0xFF, 0xF1, 0, false, 0x00, 0x85},
+		{ModelMGB, GB, 0xFFB0, 0x0013, 0x014D, 0xABCC, 0xFF, 0xF1, 0, false, 0x00, 0x85},
+		{ModelSGB, GB, 0x0100, 0x0014, 0xC060, 0, 0xFF, 0xF0, 0, false, 0, 0},
+		{ModelCGB, NonCGB, 0x1180, 0x0000, 0x000D, 0, 0x00, 0xF1, 0x80, false, 0, 0},
+		{ModelCGB, OnlyCGB, 0x1180, 0x0000, 0x000D, 0, 0x00, 0xF1, 0xC0, false, 0, 0},
+		// B is the sum of the title bytes of the Nintendo licensed test ROM
+		{ModelCGB, GB, 0x1180, 0x4000, 0x007C, 0, 0x00, 0xF1, 0x04, true, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.model.String()+" "+tt.flag.String(), func(t *testing.T) {
+			img := testROM(CART_ROM_ONLY, Banks_0, ExtRAMNone)
+			img[0x143] = uint8(tt.flag)
+			fixChecksums(img)
+			e := NewEmulator(loadTestCartridge(t, img))
+			e.Model = tt.model
+			e.Reset()
+			cpu, mmu := e.CPU, e.MMU
+			if af := uint16(cpu.A)<<8 | uint16(cpu.F()); af != tt.af {
+				t.Errorf("AF %04X, want %04X", af, tt.af)
+			}
+			if bc, hl := uint16(cpu.B)<<8|uint16(cpu.C), uint16(cpu.H)<<8|uint16(cpu.L); bc != tt.bc || hl != tt.hl {
+				t.Errorf("BC %04X HL %04X, want %04X %04X", bc, hl, tt.bc, tt.hl)
+			}
+			if cpu.PC != 0x0100 || cpu.SP != 0xFFFE {
+				t.Errorf("PC %04X SP %04X", cpu.PC, cpu.SP)
+			}
+			if mmu.Timer.counter != tt.counter {
+				t.Errorf("timer counter %04X, want %04X", mmu.Timer.counter, tt.counter)
+			}
+			regs := []struct {
+				addr uint16
+				want uint8
+			}{
+				{AddrLCDC, 0x91}, {AddrBGP, 0xFC}, {AddrIF, 0xE1}, {AddrDMA, tt.dma}, {AddrNR52, tt.nr52},
+			}
+			for _, reg := range regs {
+				if got := mmu.Read(reg.addr); got != reg.want {
+					t.Errorf("%04X is %02X, want %02X", reg.addr, got, reg.want)
+				}
+			}
+			if tt.stat != 0 {
+				if ly, stat := mmu.Read(AddrLY), mmu.Read(AddrLCDCStat)|Bit7; ly != tt.ly || stat != tt.stat {
+					t.Errorf("LY %02X STAT %02X, want %02X %02X", ly, stat, tt.ly, tt.stat)
+				}
+			}
+			if mmu.key0 != nil && mmu.key0.value != tt.key0 {
+				t.Errorf("KEY0 %02X, want %02X", mmu.key0.value, tt.key0)
+			}
+			if mmu.GPU.compat != tt.compat {
+				t.Errorf("compatibility mode %v, want %v", mmu.GPU.compat, tt.compat)
+			}
+			if mmu.BootEnabled {
+				t.Error("boot ROM is mapped")
+			}
+		})
+	}
+}
+
+func TestCompatRegisters(t *testing.T) {
+	tests := []struct {
+		name     string
+		licensee uint8
+		title    string
+		b        uint8
+		hl       uint16
+	}{
+		{"other licensee", 0x08, "TEST", 0x00, 0x007C},
+		{"Nintendo", 0x01, "TEST", 0x40, 0x007C},
+		{"Nintendo with new licensee code", 0x33, "TEST", 0x40, 0x007C},
+		{"special title", 0x01, "C", 0x43, 0x991A},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img := testROM(CART_ROM_ONLY, Banks_0, ExtRAMNone)
+			copy(img[0x134:0x144], make([]byte, 0x10))
+			copy(img[0x134:], tt.title)
+			img[0x14B] = tt.licensee
+			fixChecksums(img)
+			regs := compatRegisters(loadTestCartridge(t, img))
+			if hl := uint16(regs[6])<<8 | uint16(regs[7]); regs[2] != tt.b || hl != tt.hl {
+				t.Errorf("B %02X HL %04X, want %02X %04X", regs[2], hl, tt.b, tt.hl)
+			}
+		})
+	}
+}
+
+// testBootROM returns a boot ROM for model writing key0 to KEY0 and
+// unmapping itself at 0x00FE, so the cartridge starts from 0x0100
+func testBootROM(model Model, key0 uint8) []byte {
+	rom := make([]byte, BootROMSize(model))
+	copy(rom, []byte{
+		0x31, 0xFE, 0xFF, // LD SP,0xFFFE
+		0x3E, key0, // LD A,key0
+		0xE0, 0x4C, // LDH (0x4C),A
+	})
+	copy(rom[0xFC:], []byte{
+		0x3E, 0x11, // LD A,0x11
+		0xE0, 0x50, // LDH (0x50),A
+	})
+	return rom
+}
+
+// runBootROM runs the emulator until boot ROM has jumped to the cartridge
+func runBootROM(t *testing.T, e *Emulator) {
+	t.Helper()
+	for i := 0; i < 0x200 && e.CPU.PC != 0x0100; i++ {
+		e.StepInstruction()
+	}
+	if e.CPU.PC != 0x0100 {
+		t.Fatalf("boot ROM didn't finish, PC %04X", e.CPU.PC)
+	}
+}
+
+func TestBootROM(t *testing.T) {
+	tests := []struct {
+		name  string
+		model Model
+		flag  CGB
+		key0  uint8
+		cgb   bool
+	}{
+		{"DMG", ModelDMG, GB, 0, false},
+		{"SGB", ModelSGB, GB, 0, false},
+		{"Color cartridge on CGB", ModelCGB, NonCGB, 0x80, true},
+		{"DMG cartridge on CGB", ModelCGB, GB, 0x04, false},
+		{"compatibility mode not selected", ModelCGB, GB, 0x00, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img := testROM(CART_ROM_ONLY, Banks_0, ExtRAMNone)
+			img[0x143] = uint8(tt.flag)
+			fixChecksums(img)
+			e := NewEmulator(loadTestCartridge(t, img))
+			e.Model = tt.model
+			if err := e.LoadBootROM(bytes.NewReader(testBootROM(tt.model, tt.key0))); err != nil {
+				t.Fatal(err)
+			}
+			mmu, gpu := e.MMU, e.MMU.GPU
+			// Power on state, boot ROM is mapped and LCD is off
+			if e.CPU.PC != 0 || mmu.Read(0x0000) != 0x31 || !mmu.BootEnabled {
+				t.Fatalf("boot ROM not running, PC %04X", e.CPU.PC)
+			}
+			if mmu.Read(AddrLCDC) != 0 {
+				t.Errorf("LCDC is %02X at power on", mmu.Read(AddrLCDC))
+			}
+			e.StepInstruction()
+			if gpu.lcdOn {
+				t.Error("LCD is on at power on")
+			}
+			// Color hardware runs boot ROM in Color mode
+			if gpu.cgb != (tt.model == ModelCGB) {
+				t.Errorf("Color mode %v while boot ROM runs", gpu.cgb)
+			}
+
+			runBootROM(t, e)
+			if mmu.BootEnabled || mmu.Read(0x0000) != 0x00 || mmu.Read(0x0100) != img[0x100] {
+				t.Error("boot ROM is still mapped")
+			}
+			if gpu.cgb != tt.cgb || mmu.ColorMode() != tt.cgb || gpu.compat != (tt.model == ModelCGB && !tt.cgb) {
+				t.Errorf("cgb %v compat %v after boot", gpu.cgb, gpu.compat)
+			}
+			if got := mmu.Read(AddrKEY1); (got != 0xFF) != tt.cgb {
+				t.Errorf("KEY1 reads %02X", got)
+			}
+			// Boot ROM can't be mapped again and KEY0 is locked
+			mmu.Write(AddrBoot, 0)
+			mmu.Write(AddrKEY0, 0xFF)
+			if mmu.BootEnabled || mmu.Read(0x0000) != 0x00 {
+				t.Error("boot ROM mapped again")
+			}
+			if mmu.key0 != nil && mmu.key0.value != tt.key0 {
+				t.Errorf("KEY0 changed to %02X after boot", mmu.key0.value)
+			}
+		})
+	}
+}
+
+func TestBootROMSize(t *testing.T) {
+	for _, model := range []Model{ModelDMG, ModelCGB} {
+		e := testEmulator(t, model)
+		size := BootROMSize(ModelCGB) + BootROMSize(ModelDMG) - BootROMSize(model)
+		if err := e.LoadBootROM(bytes.NewReader(make([]byte, size))); err != ErrInvalidBootROM {
+			t.Errorf("%v: got %v, want ErrInvalidBootROM", model, err)
+		}
+		if e.BootROM != nil || e.CPU.PC != 0x0100 {
+			t.Errorf("%v: emulator reset with invalid boot ROM", model)
+		}
+	}
+}
+
+// Loading a state saved while boot ROM runs restores Color mode after the
+// switch to compatibility mode, and the other way around
+func TestBootStateColorMode(t *testing.T) {
+	e := NewEmulator(loadTestCartridge(t, testROM(CART_ROM_ONLY, Banks_0, ExtRAMNone)))
+	e.Model = ModelCGB
+	if err := e.LoadBootROM(bytes.NewReader(testBootROM(ModelCGB, key0Compat))); err != nil {
+		t.Fatal(err)
+	}
+	e.StepInstruction()
+	booting := saveState(t, e)
+	runBootROM(t, e)
+	compat := saveState(t, e)
+	gpu := e.MMU.GPU
+
+	if err := e.LoadState(bytes.NewReader(booting)); err != nil {
+		t.Fatal(err)
+	}
+	if !gpu.cgb || gpu.compat || e.MMU.Read(AddrKEY1) == 0xFF {
+		t.Error("Color mode not restored")
+	}
+	runBootROM(t, e)
+	if gpu.cgb || !gpu.compat {
+		t.Error("compatibility mode not selected after loaded boot ROM state")
+	}
+
+	e.LoadState(bytes.NewReader(booting))
+	if err := e.LoadState(bytes.NewReader(compat)); err != nil {
+		t.Fatal(err)
+	}
+	if gpu.cgb || !gpu.compat || e.MMU.Read(AddrKEY1) != 0xFF {
+		t.Error("compatibility mode not restored")
+	}
+}
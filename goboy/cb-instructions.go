@@ -151,9 +151,9 @@ func init() {
 		},
 		// 0x06: RLC (HL)
 		func(cpu *CPU) int {
-			val := cpu.Memory.Read(cpu.HL())
+			val := cpu.read(cpu.HL())
 			val = rlc(val, cpu)
-			cpu.Memory.Write(cpu.HL(), val)
+			cpu.write(cpu.HL(), val)
 			return 16
 		},
 		// 0x07: RLC A
@@ -193,9 +193,9 @@ func init() {
 		},
 		// 0x0E: RRC (HL)
 		func(cpu *CPU) int {
-			val := cpu.Memory.Read(cpu.HL())
+			val := cpu.read(cpu.HL())
 			val = rrc(val, cpu)
-			cpu.Memory.Write(cpu.HL(), val)
+			cpu.write(cpu.HL(), val)
 			return 16
 		},
 		// 0x0F: RRC A
@@ -235,9 +235,9 @@ func init() {
 		},
 		// 0x16: RL (HL)
 		func(cpu *CPU) int {
-			val := cpu.Memory.Read(cpu.HL())
+			val := cpu.read(cpu.HL())
 			val = rl(val, cpu)
-			cpu.Memory.Write(cpu.HL(), val)
+			cpu.write(cpu.HL(), val)
 			return 16
 		},
 		// 0x17: RL A
@@ -277,9 +277,9 @@ func init() {
 		},
 		// 0x1E: RR (HL)
 		func(cpu *CPU) int {
-			val := cpu.Memory.Read(cpu.HL())
+			val := cpu.read(cpu.HL())
 			val = rr(val, cpu)
-			cpu.Memory.Write(cpu.HL(), val)
+			cpu.write(cpu.HL(), val)
 			return 16
 		},
 		// 0x1F: RR A
@@ -319,9 +319,9 @@ func init() {
 		},
 		// 0x26: SLA (HL)
 		func(cpu *CPU) int {
-			val := cpu.Memory.Read(cpu.HL())
+			val := cpu.read(cpu.HL())
 			val = sla(val, cpu)
-			cpu.Memory.Write(cpu.HL(), val)
+			cpu.write(cpu.HL(), val)
 			return 16
 		},
 		// 0x27: SLA A
@@ -361,9 +361,9 @@ func init() {
 		},
 		// 0x2E: SRA (HL)
 		func(cpu *CPU) int {
-			val := cpu.Memory.Read(cpu.HL())
+			val := cpu.read(cpu.HL())
 			val = sra(val, cpu)
-			cpu.Memory.Write(cpu.HL(), val)
+			cpu.write(cpu.HL(), val)
 			return 16
 		},
 		// 0x2F: SRA A
@@ -403,9 +403,9 @@ func init() {
 		},
 		// 0x36: SWAP (HL)
 		func(cpu *CPU) int {
-			val := cpu.Memory.Read(cpu.HL())
+			val := cpu.read(cpu.HL())
 			val = swap(val, cpu)
-			cpu.Memory.Write(cpu.HL(), val)
+			cpu.write(cpu.HL(), val)
 			return 16
 		},
 		// 0x37: SWAP A
@@ -445,9 +445,9 @@ func init() {
 		},
 		// 0x3E: SRL (HL)
 		func(cpu *CPU) int {
-			val := cpu.Memory.Read(cpu.HL())
+			val := cpu.read(cpu.HL())
 			val = srl(val, cpu)
-			cpu.Memory.Write(cpu.HL(), val)
+			cpu.write(cpu.HL(), val)
 			return 16
 		},
 		// 0x3F: SRL A
@@ -487,7 +487,7 @@ func init() {
 		},
 		// 0x46: BIT 0, (HL)
 		func(cpu *CPU) int {
-			val := cpu.Memory.Read(cpu.HL())
+			val := cpu.read(cpu.HL())
 			testBit(val, 0, cpu)
 			return 12
 		},
@@ -528,7 +528,7 @@ func init() {
 		},
 		// 0x4E: BIT 1, (HL)
 		func(cpu *CPU) int {
-			val := cpu.Memory.Read(cpu.HL())
+			val := cpu.read(cpu.HL())
 			testBit(val, 1, cpu)
 			return 12
 		},
@@ -570,7 +570,7 @@ func init() {
 		},
 		// 0x56: BIT 2, (HL)
 		func(cpu *CPU) int {
-			val := cpu.Memory.Read(cpu.HL())
+			val := cpu.read(cpu.HL())
 			testBit(val, 2, cpu)
 			return 12
 		},
@@ -611,7 +611,7 @@ func init() {
 		},
 		// 0x5E: BIT 3, (HL)
 		func(cpu *CPU) int {
-			val := cpu.Memory.Read(cpu.HL())
+			val := cpu.read(cpu.HL())
 			testBit(val, 3, cpu)
 			return 12
 		},
@@ -653,7 +653,7 @@ func init() {
 		},
 		// 0x66: BIT 4, (HL)
 		func(cpu *CPU) int {
-			val := cpu.Memory.Read(cpu.HL())
+			val := cpu.read(cpu.HL())
 			testBit(val, 4, cpu)
 			return 12
 		},
@@ -694,7 +694,7 @@ func init() {
 		},
 		// 0x6E: BIT 5, (HL)
 		func(cpu *CPU) int {
-			val := cpu.Memory.Read(cpu.HL())
+			val := cpu.read(cpu.HL())
 			testBit(val, 5, cpu)
 			return 12
 		},
@@ -736,7 +736,7 @@ func init() {
 		},
 		// 0x76: BIT 6, (HL)
 		func(cpu *CPU) int {
-			val := cpu.Memory.Read(cpu.HL())
+			val := cpu.read(cpu.HL())
 			testBit(val, 6, cpu)
 			return 12
 		},
@@ -777,7 +777,7 @@ func init() {
 		},
 		// 0x7E: BIT 7, (HL)
 		func(cpu *CPU) int {
-			val := cpu.Memory.Read(cpu.HL())
+			val := cpu.read(cpu.HL())
 			testBit(val, 7, cpu)
 			return 12
 		},
@@ -818,9 +818,9 @@ func init() {
 		},
 		// 0x86: RES 0, (HL)
 		func(cpu *CPU) int {
-			val := cpu.Memory.Read(cpu.HL())
+			val := cpu.read(cpu.HL())
 			val = resetBit(val, 0)
-			cpu.Memory.Write(cpu.HL(), val)
+			cpu.write(cpu.HL(), val)
 			return 16
 		},
 		// 0x87: RES 0, A
@@ -860,9 +860,9 @@ func init() {
 		},
 		// 0x8E: RES 1, (HL)
 		func(cpu *CPU) int {
-			val := cpu.Memory.Read(cpu.HL())
+			val := cpu.read(cpu.HL())
 			val = resetBit(val, 1)
-			cpu.Memory.Write(cpu.HL(), val)
+			cpu.write(cpu.HL(), val)
 			return 16
 		},
 		// 0x8F: RES 1, A
@@ -903,9 +903,9 @@ func init() {
 		},
 		// 0x96: RES 2, (HL)
 		func(cpu *CPU) int {
-			val := cpu.Memory.Read(cpu.HL())
+			val := cpu.read(cpu.HL())
 			val = resetBit(val, 2)
-			cpu.Memory.Write(cpu.HL(), val)
+			cpu.write(cpu.HL(), val)
 			return 16
 		},
 		// 0x97: RES 2, A
@@ -945,9 +945,9 @@ func init() {
 		},
 		// 0x9E: RES 3, (HL)
 		func(cpu *CPU) int {
-			val := cpu.Memory.Read(cpu.HL())
+			val := cpu.read(cpu.HL())
 			val = resetBit(val, 3)
-			cpu.Memory.Write(cpu.HL(), val)
+			cpu.write(cpu.HL(), val)
 			return 16
 		},
 		// 0x9F: RES 3, A
@@ -988,9 +988,9 @@ func init() {
 		},
 		// 0xA6: RES 4, (HL)
 		func(cpu *CPU) int {
-			val := cpu.Memory.Read(cpu.HL())
+			val := cpu.read(cpu.HL())
 			val = resetBit(val, 4)
-			cpu.Memory.Write(cpu.HL(), val)
+			cpu.write(cpu.HL(), val)
 			return 16
 		},
 		// 0xA7: RES 4, A
@@ -1030,9 +1030,9 @@ func init() {
 		},
 		// 0xAE: RES 5, (HL)
 		func(cpu *CPU) int {
-			val := cpu.Memory.Read(cpu.HL())
+			val := cpu.read(cpu.HL())
 			val = resetBit(val, 5)
-			cpu.Memory.Write(cpu.HL(), val)
+			cpu.write(cpu.HL(), val)
 			return 16
 		},
 		// 0xAF: RES 5, A
@@ -1073,9 +1073,9 @@ func init() {
 		},
 		// 0xB6: RES 6, (HL)
 		func(cpu *CPU) int {
-			val := cpu.Memory.Read(cpu.HL())
+			val := cpu.read(cpu.HL())
 			val = resetBit(val, 6)
-			cpu.Memory.Write(cpu.HL(), val)
+			cpu.write(cpu.HL(), val)
 			return 16
 		},
 		// 0xB7: RES 6, A
@@ -1115,9 +1115,9 @@ func init() {
 		},
 		// 0xBE: RES 7, (HL)
 		func(cpu *CPU) int {
-			val := cpu.Memory.Read(cpu.HL())
+			val := cpu.read(cpu.HL())
 			val = resetBit(val, 7)
-			cpu.Memory.Write(cpu.HL(), val)
+			cpu.write(cpu.HL(), val)
 			return 16
 		},
 		// 0xBF: RES 7, A
@@ -1158,9 +1158,9 @@ func init() {
 		},
 		// 0xC6: SET 0, (HL)
 		func(cpu *CPU) int {
-			val := cpu.Memory.Read(cpu.HL())
+			val := cpu.read(cpu.HL())
 			val = setBit(val, 0)
-			cpu.Memory.Write(cpu.HL(), val)
+			cpu.write(cpu.HL(), val)
 			return 16
 		},
 		// 0xC7: SET 0, A
@@ -1200,9 +1200,9 @@ func init() {
 		},
 		// 0xCE: SET 1, (HL)
 		func(cpu *CPU) int {
-			val := cpu.Memory.Read(cpu.HL())
+			val := cpu.read(cpu.HL())
 			val = setBit(val, 1)
-			cpu.Memory.Write(cpu.HL(), val)
+			cpu.write(cpu.HL(), val)
 			return 16
 		},
 		// 0xCF: SET 1, A
@@ -1243,9 +1243,9 @@ func init() {
 		},
 		// 0xD6: SET 2, (HL)
 		func(cpu *CPU) int {
-			val := cpu.Memory.Read(cpu.HL())
+			val := cpu.read(cpu.HL())
 			val = setBit(val, 2)
-			cpu.Memory.Write(cpu.HL(), val)
+			cpu.write(cpu.HL(), val)
 			return 16
 		},
 		// 0xD7: SET 2, A
@@ -1285,9 +1285,9 @@ func init() {
 		},
 		// 0xDE: SET 3, (HL)
 		func(cpu *CPU) int {
-			val := cpu.Memory.Read(cpu.HL())
+			val := cpu.read(cpu.HL())
 			val = setBit(val, 3)
-			cpu.Memory.Write(cpu.HL(), val)
+			cpu.write(cpu.HL(), val)
 			return 16
 		},
 		// 0xDF: SET 3, A
@@ -1328,9 +1328,9 @@ func init() {
 		},
 		// 0xE6: SET 4, (HL)
 		func(cpu *CPU) int {
-			val := cpu.Memory.Read(cpu.HL())
+			val := cpu.read(cpu.HL())
 			val = setBit(val, 4)
-			cpu.Memory.Write(cpu.HL(), val)
+			cpu.write(cpu.HL(), val)
 			return 16
 		},
 		// 0xE7: SET 4, A
@@ -1370,9 +1370,9 @@ func init() {
 		},
 		// 0xEE: SET 5, (HL)
 		func(cpu *CPU) int {
-			val := cpu.Memory.Read(cpu.HL())
+			val := cpu.read(cpu.HL())
 			val = setBit(val, 5)
-			cpu.Memory.Write(cpu.HL(), val)
+			cpu.write(cpu.HL(), val)
 			return 16
 		},
 		// 0xEF: SET 5, A
@@ -1413,9 +1413,9 @@ func init() {
 		},
 		// 0xF6: SET 6, (HL)
 		func(cpu *CPU) int {
-			val := cpu.Memory.Read(cpu.HL())
+			val := cpu.read(cpu.HL())
 			val = setBit(val, 6)
-			cpu.Memory.Write(cpu.HL(), val)
+			cpu.write(cpu.HL(), val)
 			return 16
 		},
 		// 0xF7: SET 6, A
@@ -1455,9 +1455,9 @@ func init() {
 		},
 		// 0xFE: SET 7, (HL)
 		func(cpu *CPU) int {
-			val := cpu.Memory.Read(cpu.HL())
+			val := cpu.read(cpu.HL())
 			val = setBit(val, 7)
-			cpu.Memory.Write(cpu.HL(), val)
+			cpu.write(cpu.HL(), val)
 			return 16
 		},
 		// 0xFF: SET 7, A
@@ -0,0 +1,80 @@
+package goboy
+
+const (
+	AddrBCPS = 0xFF68
+	AddrBCPD = 0xFF69
+	AddrOCPS = 0xFF6A
+	AddrOCPD = 0xFF6B
+)
+
+// ColorPalette is palette RAM of Color hardware holding 8 palettes of 4
+// RGB555 colors. It's accessed through an index and a data register, writing
+// data advances the index when auto increment is set.
+type ColorPalette struct {
+	ram           [64]uint8
+	index         uint8
+	autoIncrement bool
+}
+
+// Color returns RGB555 value of color in palette
+func (p *ColorPalette) Color(palette, color uint8) uint16 {
+	i := int(palette)*8 + int(color)*2
+	return (uint16(p.ram[i]) | uint16(p.ram[i+1])<<8) & 0x7FFF
+}
+
+// setColors sets the four colors of palette
+func (p *ColorPalette) setColors(palette uint8, colors [4]uint16) {
+	for i, c := range colors {
+		j := int(palette)*8 + i*2
+		p.ram[j] = uint8(c)
+		p.ram[j+1] = uint8(c >> 8)
+	}
+}
+
+// paletteRegister exposes the index or data register of a palette to the MMU
+type paletteRegister struct {
+	display *Display
+	palette *ColorPalette
+	data    bool
+}
+
+func (r paletteRegister) RawSet(data uint8) {
+	p := r.palette
+	if r.data {
+		p.ram[p.index] = data
+		return
+	}
+	p.index = data & 0x3F
+	p.autoIncrement = data&Bit7 != 0
+}
+
+func (r paletteRegister) Set(data uint8) {
+	p := r.palette
+	if !r.data {
+		r.RawSet(data)
+		return
+	}
+	// Palette RAM can't be written while it's used for drawing, the index
+	// is still incremented
+	if r.display.accessible(VideoRAMStart) {
+		p.ram[p.index] = data
+	}
+	if p.autoIncrement {
+		p.index = (p.index + 1) & 0x3F
+	}
+}
+
+func (r paletteRegister) Get() uint8 {
+	p := r.palette
+	if !r.data {
+		data := p.index | Bit6
+		if p.autoIncrement {
+			data |= Bit7
+		}
+		return data
+	}
+	if !r.display.accessible(VideoRAMStart) {
+		return 0xFF
+	}
+	return p.ram[p.index]
+}
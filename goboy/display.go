@@ -4,6 +4,12 @@ import (
 	"sort"
 )
 
+// Size of the LCD in pixels
+const (
+	ScreenWidth  = 160
+	ScreenHeight = 144
+)
+
 const (
 	prioUndrawn = iota
 	prioBackground
@@ -23,6 +29,9 @@ const (
 	VBlankDuration   = 4560
 	OAMDuration      = 80
 	TransferDuration = 172
+
+	// Number of cycles LY reads as 153 before changing to 0 on the last row
+	lastRowLYDuration = 4
 )
 
 type Sprite struct {
@@ -41,9 +50,25 @@ func (s Sprite) Lower8x16() uint8 {
 	return s.TileID | 0x01
 }
 
+// DMGPalette maps the four shades of DMG to RGB555 colors
+var DMGPalette = [4]uint16{0x06F3, 0x06B1, 0x1986, 0x04E1}
+
 func NewDisplay(mmu *MMU) *Display {
 	d := &Display{
 		mmu: mmu,
+		// LCD is off at power on, the screen is blanked on the first update
+		lcdOn: true,
+		lcdc:  NewRWRegister(0, 0),
+		stat:  NewRWRegister(0, 0b111),
+		scy:   NewRWRegister(0, 0),
+		scx:   NewRWRegister(0, 0),
+		ly:    NewRWRegister(0, 255),
+		lyc:   NewRWRegister(0, 0),
+		bgp:   NewRWRegister(0, 0),
+		obp0:  NewRWRegister(0, 0),
+		obp1:  NewRWRegister(0, 0),
+		wy:    NewRWRegister(0, 0),
+		wx:    NewRWRegister(0, 0),
 	}
 	// // TODO: Read the actual values from memory
 	// // These should be initially zero
@@ -57,68 +82,96 @@ func NewDisplay(mmu *MMU) *Display {
 
 type Display struct {
 	mmu *MMU
-
-	VRAM [VideoRAMSize]uint8
-	oam  [OAMSize]uint8
+	// Color hardware features are enabled
+	cgb bool
+	// Color hardware is running a DMG cartridge, shades are colored with
+	// the first background palette and the first two object palettes
+	compat bool
+
+	lcdc *RWRegister
+	stat *RWRegister
+	scy  *RWRegister
+	scx  *RWRegister
+	ly   *RWRegister
+	lyc  *RWRegister
+	bgp  *RWRegister
+	obp0 *RWRegister
+	obp1 *RWRegister
+	wy   *RWRegister
+	wx   *RWRegister
+
+	// Color hardware has a second VRAM bank holding more tiles and
+	// background map attributes
+	VRAM     [2 * VideoRAMSize]uint8
+	oam      [OAMSize]uint8
+	vramBank int
+
+	bgColors  ColorPalette
+	objColors ColorPalette
 
 	cycles         int
 	row            int
 	spritePalettes [2][4]uint8
 	bgPalette      [4]uint8
 	priorityBuffer [160]uint8
-	spriteBuffer   [160 * 144]uint8
+	// Background tiles with priority attribute are drawn over sprites
+	bgPriority   [160]bool
+	spriteBuffer [160 * 144]uint16
+	// DMG shades of the frame before they're mapped to colors
+	shades [160 * 144]uint8
+
+	// Internal line counter of the window, only advanced on rows where
+	// window is drawn
+	windowLine int
+	// State of the STAT interrupt line after the previous update
+	statLine bool
+	lcdOn    bool
+	// First frame after turning LCD on isn't shown
+	skipFrame bool
+
+	// UnrestrictedAccess allows CPU to access VRAM and OAM in every mode,
+	// useful for debugging
+	UnrestrictedAccess bool
 }
 
 func (d *Display) Run(cycles int) bool {
-	lcdc := d.mmu.registers[AddrLCDC]
-	if lcdc.Get()&(1<<7) == 0 {
+	if d.lcdc.Get()&(1<<7) == 0 {
+		if d.lcdOn {
+			d.turnOff()
+		}
 		return false
 	}
+	if !d.lcdOn {
+		d.lcdOn = true
+		d.skipFrame = true
+	}
 	var hasDrawn bool
 	var (
-		lcdcStat = d.mmu.registers[AddrLCDCStat]
-		ifReg    = d.mmu.registers[AddrIF]
-		ly       = d.mmu.registers[AddrLY]
-		lyc      = d.mmu.registers[AddrLYC]
+		lcdcStat = d.stat
+		ly       = d.ly
+		lyc      = d.lyc
 	)
 	d.cycles += cycles
+	if d.cycles >= CyclesPerFrame {
+		d.cycles -= CyclesPerFrame
+	}
 
 	currentMode := lcdcStat.Get() & 0x3
 	rowCycles := d.cycles % (OAMDuration + TransferDuration + HBlankDuration)
 	row := d.cycles / (OAMDuration + TransferDuration + HBlankDuration)
-	if uint8(row) == lyc.Get() {
-		// Set Coincidence flag
-		lcdcStat.RawSet(setBit(lcdcStat.Get(), 2))
-		// Request LCD STAT interrupt if Coincidence Interrupts are enabled
-		// if lcdcStat.Get()&(1<<6) != 0 {
-		ifReg.RawSet(setBit(ifReg.Get(), LCDStatInt))
-		// }
-	} else {
-		// Unset coincidence flag
-		lcdcStat.RawSet(resetBit(lcdcStat.Get(), 2))
-	}
 	// VBlank
 	if row >= 144 {
-		// End of VBlank
-		if row > 153 {
-			row = 0
-			d.cycles = 0
-		}
 		if currentMode != ModeVBlank {
 			lcdcStat.RawSet((lcdcStat.Get() & ^uint8(0x3)) | ModeVBlank)
-			// if lcdcStat.Get()&(1<<4) != 0 {
-			ifReg.RawSet(setBit(ifReg.Get(), VBlankInt))
-			// }
+			d.mmu.requestInterrupt(VBlankInt)
+			d.windowLine = 0
 			currentMode = ModeVBlank
-			hasDrawn = true
+			hasDrawn = !d.skipFrame
+			d.skipFrame = false
 		}
 	} else if rowCycles < OAMDuration {
 		if currentMode != ModeOAM {
 			lcdcStat.RawSet((lcdcStat.Get() & ^uint8(0x3)) | ModeOAM)
-			// Request LCD STAT interrupt if OAM Interrupts are enabled
-			// if lcdcStat.Get()&(1<<5) != 0 {
-			ifReg.RawSet(setBit(ifReg.Get(), LCDStatInt))
-			// }
 			currentMode = ModeOAM
 		}
 	} else if rowCycles < OAMDuration+TransferDuration {
@@ -130,45 +183,119 @@ func (d *Display) Run(cycles int) bool {
 		if currentMode != ModeHBlank {
 			currentMode = ModeHBlank
 			lcdcStat.RawSet((lcdcStat.Get() & ^uint8(0x3)) | ModeHBlank)
-			var (
-				bgp  = d.mmu.registers[AddrBGP].Get()
-				obp0 = d.mmu.registers[AddrOBP0].Get()
-				obp1 = d.mmu.registers[AddrOBP1].Get()
-			)
 			// Start of HBlank, draw row into screen buffer
-			// Request LCD STAT interrupt if HBlank Interrupts are enabled
-			for i := range d.priorityBuffer {
-				d.priorityBuffer[i] = prioUndrawn
+			if !d.skipFrame {
+				d.drawRow(row)
+			}
+			if d.mmu.HDMA != nil {
+				d.mmu.HDMA.hblank()
 			}
-			d.bgPalette[0] = bgp & 3
-			d.bgPalette[1] = (bgp & (3 << 2)) >> 2
-			d.bgPalette[2] = (bgp & (3 << 4)) >> 4
-			d.bgPalette[3] = (bgp & (3 << 6)) >> 6
-
-			d.spritePalettes[0][1] = (obp0 & (3 << 2)) >> 2
-			d.spritePalettes[0][2] = (obp0 & (3 << 4)) >> 4
-			d.spritePalettes[0][3] = (obp0 & (3 << 6)) >> 6
-
-			d.spritePalettes[1][1] = (obp1 & (3 << 2)) >> 2
-			d.spritePalettes[1][2] = (obp1 & (3 << 4)) >> 4
-			d.spritePalettes[1][3] = (obp1 & (3 << 6)) >> 6
-
-			d.drawBackground(row)
-			d.drawSpriteRow(row)
-			// if lcdcStat.Get()&(1<<3) != 0 {
-			ifReg.RawSet(setBit(ifReg.Get(), LCDStatInt))
-			// }
-			// sink <- d.spriteBuffer
 		}
 	}
 	lcdcStat.RawSet((lcdcStat.Get() & ^uint8(0x3)) | currentMode)
-	ly.RawSet(uint8(row))
+	d.row = row
+	// LY already reads as 0 shortly after the start of the last VBlank row
+	lyVal := uint8(row)
+	if row == 153 && rowCycles >= lastRowLYDuration {
+		lyVal = 0
+	}
+	ly.RawSet(lyVal)
+	// Coincidence flag follows LY==LYC, so it only changes when the line
+	// changes or when LYC is written
+	if lyVal == lyc.Get() {
+		lcdcStat.RawSet(setBit(lcdcStat.Get(), 2))
+	} else {
+		lcdcStat.RawSet(resetBit(lcdcStat.Get(), 2))
+	}
+	d.updateStatLine(lcdcStat.Get())
 	return hasDrawn
 }
 
+func (d *Display) drawRow(row int) {
+	var (
+		bgp  = d.bgp.Get()
+		obp0 = d.obp0.Get()
+		obp1 = d.obp1.Get()
+	)
+	for i := range d.priorityBuffer {
+		d.priorityBuffer[i] = prioUndrawn
+		d.bgPriority[i] = false
+	}
+	d.bgPalette[0] = bgp & 3
+	d.bgPalette[1] = (bgp & (3 << 2)) >> 2
+	d.bgPalette[2] = (bgp & (3 << 4)) >> 4
+	d.bgPalette[3] = (bgp & (3 << 6)) >> 6
+
+	d.spritePalettes[0][1] = (obp0 & (3 << 2)) >> 2
+	d.spritePalettes[0][2] = (obp0 & (3 << 4)) >> 4
+	d.spritePalettes[0][3] = (obp0 & (3 << 6)) >> 6
+
+	d.spritePalettes[1][1] = (obp1 & (3 << 2)) >> 2
+	d.spritePalettes[1][2] = (obp1 & (3 << 4)) >> 4
+	d.spritePalettes[1][3] = (obp1 & (3 << 6)) >> 6
+
+	d.drawBackground(row)
+	d.drawWindow(row)
+	d.drawSpriteRow(row)
+}
+
+// turnOff stops the LCD when LCDC bit 7 is cleared. LY and mode are reset
+// and the screen is left blank.
+func (d *Display) turnOff() {
+	lcdcStat := d.stat
+	d.lcdOn = false
+	d.cycles = 0
+	d.row = 0
+	d.windowLine = 0
+	d.statLine = false
+	d.ly.RawSet(0)
+	lcdcStat.RawSet((lcdcStat.Get() & ^uint8(0x3)) | ModeHBlank)
+	blank := DMGPalette[0]
+	if d.cgb || d.compat {
+		blank = 0x7FFF
+	}
+	for i := range d.spriteBuffer {
+		d.spriteBuffer[i] = blank
+		d.shades[i] = 0
+	}
+}
+
+// accessible reports whether CPU can access addr in the current mode.
+// VRAM can't be accessed while it's used for drawing in mode 3 and OAM in
+// modes 2 and 3.
+func (d *Display) accessible(addr uint16) bool {
+	if d.UnrestrictedAccess || !d.lcdOn {
+		return true
+	}
+	mode := d.stat.Get() & 0x3
+	if VideoRAMStart <= addr && addr <= VideoRAMEnd {
+		return mode != ModeTransfer
+	}
+	return mode != ModeOAM && mode != ModeTransfer
+}
+
+// updateStatLine recalculates the shared STAT interrupt line from the
+// sources selected by STAT bits 3-6. The interrupt is only requested when
+// the line goes from low to high, so while one source keeps the line high
+// other sources can't request new interrupts.
+func (d *Display) updateStatLine(stat uint8) {
+	mode := stat & 0x3
+	line := (stat&(1<<3) != 0 && mode == ModeHBlank) ||
+		(stat&(1<<4) != 0 && mode == ModeVBlank) ||
+		(stat&(1<<5) != 0 && mode == ModeOAM) ||
+		(stat&(1<<6) != 0 && stat&(1<<2) != 0)
+	if line && !d.statLine {
+		d.mmu.requestInterrupt(LCDStatInt)
+	}
+	d.statLine = line
+}
+
 func (d *Display) Read(addr uint16) uint8 {
+	if !d.accessible(addr) {
+		return 0xFF
+	}
 	if VideoRAMStart <= addr && addr <= VideoRAMEnd {
-		return d.VRAM[addr-VideoRAMStart]
+		return d.VRAM[d.vramBank*VideoRAMSize+int(addr-VideoRAMStart)]
 	}
 	if OAMStart <= addr && addr <= OAMEnd {
 		return d.oam[addr-OAMStart]
@@ -177,8 +304,11 @@ func (d *Display) Read(addr uint16) uint8 {
 }
 
 func (d *Display) Write(addr uint16, data uint8) {
+	if !d.accessible(addr) {
+		return
+	}
 	if VideoRAMStart <= addr && addr <= VideoRAMEnd {
-		d.VRAM[addr-VideoRAMStart] = data
+		d.VRAM[d.vramBank*VideoRAMSize+int(addr-VideoRAMStart)] = data
 		return
 	}
 	if OAMStart <= addr && addr <= OAMEnd {
@@ -188,47 +318,131 @@ func (d *Display) Write(addr uint16, data uint8) {
 	panic("Invalid addr")
 }
 
+// selectVRAMBank selects VRAM bank accessed by CPU
+func (d *Display) selectVRAMBank(bank uint8) {
+	d.vramBank = int(bank)
+}
+
+// drawBackground draws one row of the background scrolled by SCX and SCY.
+// The scroll registers are read for each row, so changes made by the game
+// between rows show up on the following rows.
 func (d *Display) drawBackground(row int) {
 	var (
-		scx         = 0 // d.mmu.registers[AddrSCX].Get()
-		scy         = 0 // d.mmu.registers[AddrSCY].Get()
-		lcdc        = d.mmu.registers[AddrLCDC]
-		useLowerMap = lcdc.Get()&(1<<3) == 0
-		tileData    []uint8
+		scx         = int(d.scx.Get())
+		scy         = int(d.scy.Get())
+		useLowerMap = d.lcdc.Get()&(1<<3) == 0
+		mapOffset   = 0x1C00
 	)
 	if useLowerMap {
-		tileData = d.backgroundTileMap1()
-	} else {
-		tileData = d.backgroundTileMap2()
-	}
-	var i int
-outer:
-	for {
-		pixelPosX := (i + int(scx)) % 256
-		pixelPosY := (row + int(scy)) % 256
-		tileX := pixelPosX / 8
-		tileY := pixelPosY / 8
-		tileID := tileData[tileY*32+tileX]
-		tile := d.GetTile(tileID, false)
-		tileRowStart := ((row) % 8) * 2
-		pixels := getPixelRow([2]uint8{tile[tileRowStart], tile[tileRowStart+1]}, false)
-		for _, val := range pixels {
-			d.spriteBuffer[row*160+i] = d.bgPalette[val]
-
-			if val != 0 {
-				d.priorityBuffer[i] = prioBackground
-			}
-			i++
-			if i >= 160 {
-				break outer
-			}
+		mapOffset = 0x1800
+	}
+	// Background map is 256x256 pixels and wraps around on both axes
+	pixelPosY := (row + scy) % 256
+	tileY := pixelPosY / 8
+	var (
+		pixels [8]uint8
+		attr   uint8
+	)
+	for i := 0; i < 160; i++ {
+		pixelPosX := (i + scx) % 256
+		if i == 0 || pixelPosX%8 == 0 {
+			tileX := pixelPosX / 8
+			pixels, attr = d.bgTileRow(mapOffset+tileY*32+tileX, pixelPosY%8)
+		}
+		val := pixels[pixelPosX%8]
+		d.setBGPixel(row*160+i, attr, val)
+		d.bgPriority[i] = attr&Bit7 != 0
+		if val != 0 {
+			d.priorityBuffer[i] = prioBackground
+		}
+	}
+}
+
+// drawWindow draws one row of the window on top of the background. Window
+// is enabled by LCDC bit 5 and its tile map is selected by LCDC bit 6.
+func (d *Display) drawWindow(row int) {
+	var (
+		lcdc = d.lcdc.Get()
+		wy   = int(d.wy.Get())
+		// WX is the window position plus 7
+		windowX = int(d.wx.Get()) - 7
+	)
+	if lcdc&(1<<5) == 0 || row < wy || windowX >= 160 {
+		return
+	}
+	mapOffset := 0x1800
+	if lcdc&(1<<6) != 0 {
+		mapOffset = 0x1C00
+	}
+	tileY := d.windowLine / 8
+	var (
+		pixels [8]uint8
+		attr   uint8
+	)
+	// With WX < 7 the window starts left of the screen and its first
+	// columns are cut off
+	for i := windowX; i < 160; i++ {
+		pixelPosX := i - windowX
+		if pixelPosX%8 == 0 || i == windowX {
+			pixels, attr = d.bgTileRow(mapOffset+tileY*32+pixelPosX/8, d.windowLine%8)
+		}
+		if i < 0 {
+			continue
 		}
+		val := pixels[pixelPosX%8]
+		d.setBGPixel(row*160+i, attr, val)
+		d.bgPriority[i] = attr&Bit7 != 0
+		if val != 0 {
+			d.priorityBuffer[i] = prioBackground
+		} else {
+			d.priorityBuffer[i] = prioUndrawn
+		}
+	}
+	d.windowLine++
+}
+
+// bgTileRow returns pixels of one row of the background or window tile at
+// mapIndex in VRAM and the attributes of the tile. On Color hardware the
+// attributes select the tile bank and flip the tile.
+func (d *Display) bgTileRow(mapIndex int, line int) ([8]uint8, uint8) {
+	var attr uint8
+	if d.cgb {
+		attr = d.VRAM[VideoRAMSize+mapIndex]
+	}
+	if attr&Bit6 != 0 {
+		line = 7 - line
 	}
+	tile := d.getTile(d.VRAM[mapIndex], false, int(attr>>3)&1)
+	return getPixelRow([2]uint8{tile[line*2], tile[line*2+1]}, attr&Bit5 != 0), attr
+}
+
+// setBGPixel sets pixel at idx to color val of the background palette
+func (d *Display) setBGPixel(idx int, attr, val uint8) {
+	if d.cgb {
+		d.spriteBuffer[idx] = d.bgColors.Color(attr&0x7, val)
+		return
+	}
+	shade := d.bgPalette[val]
+	d.setShade(idx, shade)
+	if d.compat {
+		d.spriteBuffer[idx] = d.bgColors.Color(0, shade)
+	}
+}
+
+// setShade sets pixel at idx to one of the four DMG shades
+func (d *Display) setShade(idx int, shade uint8) {
+	d.shades[idx] = shade
+	d.spriteBuffer[idx] = DMGPalette[shade]
 }
 
 func (d *Display) drawSpriteRow(row int) {
 	var spriteHeight int = 8
-	var longSprites = d.mmu.registers[AddrLCDC].Get()&(1<<2) != 0
+	var (
+		lcdc        = d.lcdc.Get()
+		longSprites = lcdc&(1<<2) != 0
+		// On Color hardware clearing LCDC bit 0 draws sprites over everything
+		bgPriority = !d.cgb || lcdc&Bit0 != 0
+	)
 	// Check LCDC register bit 2 for current sprite size
 	// 0 = 8x8, 1 = 8x16
 	if longSprites {
@@ -242,10 +456,12 @@ func (d *Display) drawSpriteRow(row int) {
 	})
 	// Sort sprites according to their X values
 	// Use stable sort because we also want to keep memory location order
-	// if x-values are equal
-	sort.SliceStable(sprites, func(i, j int) bool {
-		return sprites[i].X < sprites[j].X
-	})
+	// if x-values are equal. Color hardware only uses the memory order.
+	if !d.cgb {
+		sort.SliceStable(sprites, func(i, j int) bool {
+			return sprites[i].X < sprites[j].X
+		})
+	}
 	// Note: sprite X and Y are location of lower right corner of the sprite
 	for i := 0; i < len(sprites) && i < 10; i++ {
 		sprite := sprites[i]
@@ -270,7 +486,11 @@ func (d *Display) drawSpriteRow(row int) {
 			}
 
 		}
-		tile := d.GetTile(tileID, true)
+		var bank int
+		if d.cgb {
+			bank = int(sprite.Flags>>3) & 1
+		}
+		tile := d.getTile(tileID, true, bank)
 		tileRowStart := ((row - (int(sprite.Y) - 16)) % 8) * 2
 		// Check flag bit 7 if we are drawing sprite always above bg
 		aboveBG := sprite.Flags&(1<<7) == 0
@@ -288,10 +508,19 @@ func (d *Display) drawSpriteRow(row int) {
 				continue
 			}
 			// Check if that we aren't drawing above another sprite that was already drawn
-			if d.priorityBuffer[pixelX] != prioSprite && (aboveBG || d.priorityBuffer[pixelX] == prioUndrawn) {
-				if pixelVal != 0 {
-					d.priorityBuffer[pixelX] = prioSprite
-					d.spriteBuffer[idx] = d.spritePalettes[spritePaletteID][pixelVal]
+			if d.priorityBuffer[pixelX] == prioSprite || pixelVal == 0 {
+				continue
+			}
+			if !bgPriority || d.priorityBuffer[pixelX] == prioUndrawn || (aboveBG && !d.bgPriority[pixelX]) {
+				d.priorityBuffer[pixelX] = prioSprite
+				if d.cgb {
+					d.spriteBuffer[idx] = d.objColors.Color(sprite.Flags&0x7, pixelVal)
+				} else {
+					shade := d.spritePalettes[spritePaletteID][pixelVal]
+					d.setShade(idx, shade)
+					if d.compat {
+						d.spriteBuffer[idx] = d.objColors.Color(spritePaletteID, shade)
+					}
 				}
 			}
 		}
@@ -314,31 +543,29 @@ func getPixelRow(rawTileRow [2]uint8, mirror bool) (pixels [8]uint8) {
 }
 
 func (d *Display) GetTile(id uint8, spriteData bool) []uint8 {
-	if spriteData || d.mmu.registers[AddrLCDC].Get()&(1<<4) != 0 {
+	return d.getTile(id, spriteData, 0)
+}
+
+func (d *Display) getTile(id uint8, spriteData bool, bank int) []uint8 {
+	if spriteData || d.lcdc.Get()&(1<<4) != 0 {
 		memoryLoc := int(id) * 16
-		return d.tilePatternTable1()[memoryLoc : memoryLoc+16]
+		return d.tilePatternTable1(bank)[memoryLoc : memoryLoc+16]
 	}
 	memoryLoc := 0x800 + int(int8(id))*16
-	return d.tilePatternTable2()[memoryLoc : memoryLoc+16]
+	return d.tilePatternTable2(bank)[memoryLoc : memoryLoc+16]
 }
 
-func (d *Display) tilePatternTable1() []uint8 {
+func (d *Display) tilePatternTable1(bank int) []uint8 {
 	// Tile Pattern Table 1 starts at 0x8000 and ends at 0x8FFF
 	// Return slice from 0x0 to 0x1000 (exclusive)
-	return d.VRAM[0x0000:0x1000]
+	offset := bank * VideoRAMSize
+	return d.VRAM[offset : offset+0x1000]
 }
-func (d *Display) tilePatternTable2() []uint8 {
+func (d *Display) tilePatternTable2(bank int) []uint8 {
 	// Tile Pattern Table 2 starts at 0x8800 and ends at 0x97FF
 	// Return slice from 0x0800 to 0x1800 (exclusive)
-	return d.VRAM[0x0800:0x1800]
-}
-
-func (d *Display) backgroundTileMap1() []uint8 {
-	return d.VRAM[0x1800:0x1C00]
-}
-
-func (d *Display) backgroundTileMap2() []uint8 {
-	return d.VRAM[0x1C00:0x2000]
+	offset := bank * VideoRAMSize
+	return d.VRAM[offset+0x0800 : offset+0x1800]
 }
 
 func (d *Display) GetSprite(id int) Sprite {
@@ -368,6 +595,7 @@ func (d *Display) FilterSprites(filterFunc func(Sprite) bool) []Sprite {
 	return sprites
 }
 
-func (d *Display) ScreenBuffer() []uint8 {
+// ScreenBuffer returns the last drawn frame as RGB555 colors
+func (d *Display) ScreenBuffer() []uint16 {
 	return d.spriteBuffer[:]
 }
@@ -0,0 +1,601 @@
+package goboy
+
+import (
+	"testing"
+)
+
+// testDisplay returns a DMG running an endless loop and its display. BGP
+// maps each color to the shade of the same number.
+func testDisplay(t *testing.T) (*Emulator, *Display) {
+	t.Helper()
+	e := testEmulator(t, ModelDMG, 0x18, 0xFE)
+	d := e.MMU.GPU
+	d.bgp.RawSet(0xE4)
+	return e, d
+}
+
+// setTile fills tile id of the unsigned tile data area with one color
+func setTile(d *Display, id int, color uint8) {
+	var low, high uint8
+	if color&1 != 0 {
+		low = 0xFF
+	}
+	if color&2 != 0 {
+		high = 0xFF
+	}
+	for i := 0; i < 8; i++ {
+		d.VRAM[id*16+i*2] = low
+		d.VRAM[id*16+i*2+1] = high
+	}
+}
+
+// setTileMap fills the map at mapOffset with the tile returned by tile for
+// each map position
+func setTileMap(d *Display, mapOffset int, tile func(x, y int) uint8) {
+	for y := 0; y < 32; y++ {
+		for x := 0; x < 32; x++ {
+			d.VRAM[mapOffset+y*32+x] = tile(x, y)
+		}
+	}
+}
+
+// edgeTiles marks the first row and column of the map so wrapping can be seen
+func edgeTiles(x, y int) uint8 {
+	var tile uint8
+	if x == 0 {
+		tile |= 1
+	}
+	if y == 0 {
+		tile |= 2
+	}
+	return tile
+}
+
+func shadeAt(d *Display, x, y int) uint8 {
+	return d.shades[y*ScreenWidth+x]
+}
+
+func TestBackgroundScroll(t *testing.T) {
+	tests := []struct {
+		name     string
+		scx, scy int
+	}{
+		{"no scroll", 0, 0},
+		{"fine scroll", 3, 5},
+		{"tile scroll", 16, 24},
+		{"wraps horizontally", 250, 0},
+		{"wraps vertically", 0, 200},
+		{"wraps on both axes", 255, 255},
+		{"odd offsets", 131, 77},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, d := testDisplay(t)
+			for i := 0; i < 4; i++ {
+				setTile(d, i, uint8(i))
+			}
+			setTileMap(d, 0x1800, edgeTiles)
+			d.scx.RawSet(uint8(tt.scx))
+			d.scy.RawSet(uint8(tt.scy))
+			for row := 0; row < ScreenHeight; row++ {
+				d.drawRow(row)
+			}
+			for y := 0; y < ScreenHeight; y++ {
+				for x := 0; x < ScreenWidth; x++ {
+					want := edgeTiles(((x+tt.scx)%256)/8, ((y+tt.scy)%256)/8)
+					if got := shadeAt(d, x, y); got != want {
+						t.Fatalf("pixel %d,%d has shade %d, want %d", x, y, got, want)
+					}
+				}
+			}
+		})
+	}
+}
+
+// checkerTiles alternates tile colors on both axes
+func checkerTiles(x, y int) uint8 {
+	return uint8(x%2 | y%2<<1)
+}
+
+// Scroll registers written in the middle of a frame apply from the next
+// drawn line on
+func TestBackgroundScrollMidFrame(t *testing.T) {
+	tests := []struct {
+		name     string
+		addr     uint16
+		line     int
+		scx, scy int
+	}{
+		{"SCX", AddrSCX, 40, 4, 0},
+		{"SCY", AddrSCY, 100, 0, 4},
+		{"first line", AddrSCX, 0, 4, 0},
+		{"last line", AddrSCY, 143, 0, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, d := testDisplay(t)
+			for i := 0; i < 4; i++ {
+				setTile(d, i, uint8(i))
+			}
+			setTileMap(d, 0x1800, checkerTiles)
+			e.RunFrame()
+			for int(e.MMU.Read(AddrLY)) != tt.line {
+				e.StepInstruction()
+			}
+			// Write during OAM search of the line before it's drawn
+			for e.MMU.Read(AddrLCDCStat)&0x3 != ModeOAM {
+				e.StepInstruction()
+			}
+			e.MMU.Write(tt.addr, uint8(tt.scx+tt.scy))
+			e.RunFrame()
+			for y := 0; y < ScreenHeight; y++ {
+				scx, scy := 0, 0
+				if y >= tt.line {
+					scx, scy = tt.scx, tt.scy
+				}
+				for x := 0; x < ScreenWidth; x++ {
+					want := checkerTiles((x+scx)/8, (y+scy)/8)
+					if got := shadeAt(d, x, y); got != want {
+						t.Fatalf("pixel %d,%d has shade %d, want %d", x, y, got, want)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestWindowPosition(t *testing.T) {
+	tests := []struct {
+		name         string
+		lcdc         uint8
+		wx, wy       int
+		left, top    int
+		windowHidden bool
+	}{
+		{"whole screen", 0xF1, 7, 0, 0, 0, false},
+		{"bottom right corner", 0xF1, 87, 72, 80, 72, false},
+		{"WX below 7 cuts off left columns", 0xF1, 0, 10, 0, 10, false},
+		{"WX 6", 0xF1, 6, 10, 0, 10, false},
+		{"last column", 0xF1, 166, 0, 159, 0, false},
+		{"right of screen", 0xF1, 167, 0, 0, 0, true},
+		{"last line", 0xF1, 7, 143, 0, 143, false},
+		{"below screen", 0xF1, 7, 144, 0, 0, true},
+		{"disabled", 0xD1, 7, 0, 0, 0, true},
+		// LCDC bit 6 selects the map, the other map is blank
+		{"lower map", 0xB1, 7, 0, 0, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, d := testDisplay(t)
+			setTile(d, 3, 3)
+			setTileMap(d, 0x1C00, func(x, y int) uint8 { return 3 })
+			d.lcdc.RawSet(tt.lcdc)
+			d.wx.RawSet(uint8(tt.wx))
+			d.wy.RawSet(uint8(tt.wy))
+			for row := 0; row < ScreenHeight; row++ {
+				d.drawRow(row)
+			}
+			for y := 0; y < ScreenHeight; y++ {
+				for x := 0; x < ScreenWidth; x++ {
+					var want uint8
+					if !tt.windowHidden && x >= tt.left && y >= tt.top {
+						want = 3
+					}
+					if got := shadeAt(d, x, y); got != want {
+						t.Fatalf("pixel %d,%d has shade %d, want %d", x, y, got, want)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestWindowScrollWithWXBelow7(t *testing.T) {
+	_, d := testDisplay(t)
+	for i := 0; i < 4; i++ {
+		setTile(d, i, uint8(i))
+	}
+	setTileMap(d, 0x1C00, checkerTiles)
+	d.lcdc.RawSet(0xF1)
+	d.wx.RawSet(3)
+	d.drawRow(0)
+	for x := 0; x < ScreenWidth; x++ {
+		if want, got := checkerTiles((x+4)/8, 0), shadeAt(d, x, 0); got != want {
+			t.Fatalf("pixel %d has shade %d, want %d", x, got, want)
+		}
+	}
+}
+
+// Window line counter only advances on lines where window is drawn, so
+// window continues from where it left off after being hidden
+func TestWindowLineCounter(t *testing.T) {
+	tests := []struct {
+		name string
+		hide func(d *Display)
+		show func(d *Display)
+	}{
+		{
+			"disabled with LCDC",
+			func(d *Display) { d.lcdc.RawSet(0xD1) },
+			func(d *Display) { d.lcdc.RawSet(0xF1) },
+		},
+		{
+			"moved right of screen",
+			func(d *Display) { d.wx.RawSet(200) },
+			func(d *Display) { d.wx.RawSet(7) },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, d := testDisplay(t)
+			for i := 0; i < 4; i++ {
+				setTile(d, i, uint8(i))
+			}
+			setTileMap(d, 0x1C00, func(x, y int) uint8 { return uint8(y % 4) })
+			d.lcdc.RawSet(0xF1)
+			d.wx.RawSet(7)
+			for row := 0; row < ScreenHeight; row++ {
+				if row == 10 {
+					tt.hide(d)
+				} else if row == 20 {
+					tt.show(d)
+				}
+				d.drawRow(row)
+			}
+			for y := 0; y < ScreenHeight; y++ {
+				var want uint8
+				switch {
+				case y < 10:
+					want = uint8(y / 8 % 4)
+				case y >= 20:
+					want = uint8((y - 10) / 8 % 4)
+				}
+				if got := shadeAt(d, 0, y); got != want {
+					t.Errorf("line %d has shade %d, want %d", y, got, want)
+				}
+			}
+			// Counter starts from 0 on the next frame
+			e.RunFrame()
+			if d.windowLine != 0 {
+				t.Errorf("window line %d after VBlank, want 0", d.windowLine)
+			}
+		})
+	}
+}
+
+func TestWindowSpritePriority(t *testing.T) {
+	tests := []struct {
+		name        string
+		windowColor uint8
+		spriteX     int
+		behindBG    bool
+		want        uint8
+	}{
+		{"sprite above window", 2, 80, false, 3},
+		{"sprite behind window", 2, 80, true, 2},
+		{"sprite behind window color 0", 0, 80, true, 3},
+		{"sprite above window color 0", 0, 80, false, 3},
+		{"sprite behind background", 2, 0, true, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, d := testDisplay(t)
+			for i := 0; i < 4; i++ {
+				setTile(d, i, uint8(i))
+			}
+			setTileMap(d, 0x1800, func(x, y int) uint8 { return 1 })
+			setTileMap(d, 0x1C00, func(x, y int) uint8 { return tt.windowColor })
+			d.lcdc.RawSet(0xF3)
+			d.wx.RawSet(7 + 40)
+			d.obp0.RawSet(0xE4)
+			var flags uint8
+			if tt.behindBG {
+				flags = Bit7
+			}
+			copy(d.oam[:], []uint8{16, uint8(tt.spriteX + 8), 3, flags})
+			d.drawRow(0)
+			if got := shadeAt(d, tt.spriteX+4, 0); got != tt.want {
+				t.Errorf("shade %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+// runUntilRow runs the display until the start of row
+func runUntilRow(d *Display, row int) {
+	for d.row == row {
+		d.Run(4)
+	}
+	for d.row != row {
+		d.Run(4)
+	}
+}
+
+// countStatInterrupts runs the display for one frame and counts the STAT
+// interrupts requested
+func countStatInterrupts(e *Emulator) int {
+	var count int
+	for i := 0; i < CyclesPerFrame; i += 4 {
+		e.MMU.GPU.Run(4)
+		if e.MMU.ifReg.Get()&(1<<LCDStatInt) != 0 {
+			count++
+			e.MMU.ifReg.RawSet(0)
+		}
+	}
+	return count
+}
+
+func TestSTATInterruptSources(t *testing.T) {
+	tests := []struct {
+		name string
+		stat uint8
+		lyc  uint8
+		want int
+	}{
+		{"no sources", 0x00, 0, 0},
+		{"HBlank", 0x08, 200, 144},
+		{"VBlank", 0x10, 200, 1},
+		{"OAM", 0x20, 200, 144},
+		{"LY=LYC", 0x40, 10, 1},
+		{"LY=LYC on line 0", 0x40, 0, 1},
+		{"LY=LYC on line 153", 0x40, 153, 1},
+		{"LYC never matches", 0x40, 200, 0},
+		// Line stays high from HBlank to the next OAM, so only the OAM
+		// after VBlank requests an interrupt besides HBlanks
+		{"HBlank and OAM", 0x28, 200, 145},
+		{"HBlank and VBlank", 0x18, 200, 144},
+		{"OAM and VBlank", 0x30, 200, 144},
+		{"HBlank and LY=LYC", 0x48, 10, 143},
+		// LY=LYC keeps the line high through line 10 and VBlank through
+		// the first OAM
+		{"everything", 0x78, 10, 143},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, d := testDisplay(t)
+			e.MMU.Write(AddrLYC, tt.lyc)
+			e.MMU.Write(AddrLCDCStat, tt.stat)
+			countStatInterrupts(e)
+			if got := countStatInterrupts(e); got != tt.want {
+				t.Errorf("%d interrupts in a frame, want %d", got, tt.want)
+			}
+			if got := d.stat.Get() & 0x78; got != tt.stat {
+				t.Errorf("STAT sources read %02X, want %02X", got, tt.stat)
+			}
+		})
+	}
+}
+
+func TestSTATModes(t *testing.T) {
+	tests := []struct {
+		cycles int
+		mode   uint8
+	}{
+		{0, ModeOAM},
+		{OAMDuration - 4, ModeOAM},
+		{OAMDuration, ModeTransfer},
+		{OAMDuration + TransferDuration - 4, ModeTransfer},
+		{OAMDuration + TransferDuration, ModeHBlank},
+		{OAMDuration + TransferDuration + HBlankDuration - 4, ModeHBlank},
+	}
+	for _, tt := range tests {
+		_, d := testDisplay(t)
+		runUntilRow(d, 50)
+		d.Run(tt.cycles)
+		if got := d.stat.Get() & 0x3; got != tt.mode {
+			t.Errorf("mode %d after %d cycles of a line, want %d", got, tt.cycles, tt.mode)
+		}
+	}
+	_, d := testDisplay(t)
+	runUntilRow(d, 144)
+	for row := 144; row < 154; row++ {
+		if got := d.stat.Get() & 0x3; got != ModeVBlank {
+			t.Errorf("mode %d on line %d, want VBlank", got, row)
+		}
+		d.Run(OAMDuration + TransferDuration + HBlankDuration)
+	}
+}
+
+// LY changes to 0 shortly after line 153 starts, and LY=LYC compares
+// against the value LY reads as
+func TestLYOnLastLine(t *testing.T) {
+	tests := []struct {
+		cycles   int
+		ly       uint8
+		lyc      uint8
+		coincide bool
+	}{
+		{0, 153, 153, true},
+		{0, 153, 0, false},
+		{lastRowLYDuration, 0, 0, true},
+		{lastRowLYDuration, 0, 153, false},
+		{400, 0, 0, true},
+	}
+	for _, tt := range tests {
+		e, d := testDisplay(t)
+		e.MMU.Write(AddrLYC, tt.lyc)
+		runUntilRow(d, 153)
+		d.Run(tt.cycles)
+		if got := e.MMU.Read(AddrLY); got != tt.ly {
+			t.Errorf("LY read %d after %d cycles of line 153, want %d", got, tt.cycles, tt.ly)
+		}
+		if got := e.MMU.Read(AddrLCDCStat)&Bit2 != 0; got != tt.coincide {
+			t.Errorf("LYC %d: coincidence flag %v after %d cycles, want %v", tt.lyc, got, tt.cycles, tt.coincide)
+		}
+	}
+}
+
+func TestLCDOff(t *testing.T) {
+	tests := []struct {
+		name   string
+		row    int
+		cycles int
+	}{
+		{"during OAM search", 20, 8},
+		{"during transfer", 60, 100},
+		{"during HBlank", 100, 300},
+		{"during VBlank", 150, 12},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, d := testDisplay(t)
+			setTile(d, 0, 3)
+			runUntilRow(d, tt.row)
+			d.Run(tt.cycles)
+			e.MMU.Write(AddrLCDC, 0x11)
+			for i := 0; i < 3; i++ {
+				d.Run(OAMDuration + TransferDuration + HBlankDuration)
+				if got := e.MMU.Read(AddrLY); got != 0 {
+					t.Errorf("LY read %d with LCD off, want 0", got)
+				}
+				if got := e.MMU.Read(AddrLCDCStat) & 0x3; got != ModeHBlank {
+					t.Errorf("mode %d with LCD off, want 0", got)
+				}
+			}
+			for i, c := range d.ScreenBuffer() {
+				if c != DMGPalette[0] {
+					t.Fatalf("pixel %d is %04X with LCD off, want blank", i, c)
+				}
+			}
+			// LCD starts from the beginning of the first line when turned on
+			e.MMU.Write(AddrLCDC, 0x91)
+			d.Run(4)
+			if d.row != 0 || d.cycles != 4 {
+				t.Errorf("turned on at row %d cycle %d, want row 0 cycle 4", d.row, d.cycles)
+			}
+		})
+	}
+}
+
+func TestLCDOnSkipsFirstFrame(t *testing.T) {
+	e, d := testDisplay(t)
+	setTile(d, 0, 3)
+	e.MMU.Write(AddrLCDC, 0x11)
+	d.Run(4)
+	e.MMU.Write(AddrLCDC, 0x91)
+	var frames []int
+	for i := 0; i < 3*CyclesPerFrame; i += 4 {
+		if d.Run(4) {
+			frames = append(frames, i)
+		}
+	}
+	if len(frames) != 2 || frames[0] < CyclesPerFrame {
+		t.Fatalf("frames drawn at cycles %v, want the first frame skipped", frames)
+	}
+	// Frames after the skipped one are drawn
+	for i, c := range d.ScreenBuffer() {
+		if c != DMGPalette[3] {
+			t.Fatalf("pixel %d is %04X, want %04X", i, c, DMGPalette[3])
+		}
+	}
+}
+
+func TestVideoMemoryAccess(t *testing.T) {
+	tests := []struct {
+		name         string
+		row, cycles  int
+		lcdOff       bool
+		unrestricted bool
+		vram, oam    bool
+	}{
+		{"OAM search", 50, 4, false, false, true, false},
+		{"transfer", 50, OAMDuration + 4, false, false, false, false},
+		{"HBlank", 50, OAMDuration + TransferDuration + 4, false, false, true, true},
+		{"VBlank", 150, 4, false, false, true, true},
+		{"LCD off", 50, OAMDuration + 4, true, false, true, true},
+		{"unrestricted during transfer", 50, OAMDuration + 4, false, true, true, true},
+		{"unrestricted during OAM search", 50, 4, false, true, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, d := testDisplay(t)
+			d.VRAM[0] = 0x12
+			d.oam[0] = 0x34
+			d.UnrestrictedAccess = tt.unrestricted
+			runUntilRow(d, tt.row)
+			d.Run(tt.cycles)
+			if tt.lcdOff {
+				e.MMU.Write(AddrLCDC, 0x11)
+				d.Run(4)
+			}
+			access := []struct {
+				addr       uint16
+				mem        *uint8
+				accessible bool
+			}{
+				{VideoRAMStart, &d.VRAM[0], tt.vram},
+				{OAMStart, &d.oam[0], tt.oam},
+			}
+			for _, a := range access {
+				want := *a.mem
+				if !a.accessible {
+					want = 0xFF
+				}
+				if got := e.MMU.Read(a.addr); got != want {
+					t.Errorf("read %04X = %02X, want %02X", a.addr, got, want)
+				}
+				old := *a.mem
+				e.MMU.Write(a.addr, 0x56)
+				want = 0x56
+				if !a.accessible {
+					want = old
+				}
+				if *a.mem != want {
+					t.Errorf("write %04X stored %02X, want %02X", a.addr, *a.mem, want)
+				}
+			}
+		})
+	}
+}
+
+func TestCompatibilityMode(t *testing.T) {
+	tests := []struct {
+		name              string
+		model             Model
+		flag              CGB
+		cgb, compat       bool
+		bgColor, objColor uint16
+	}{
+		{"DMG cartridge on DMG", ModelDMG, GB, false, false, DMGPalette[1], DMGPalette[2]},
+		{"DMG cartridge on CGB", ModelCGB, GB, false, true, 0x1BEF, 0x1CF2},
+		{"Color cartridge on DMG", ModelDMG, NonCGB, false, false, DMGPalette[1], DMGPalette[2]},
+		{"Color cartridge on CGB", ModelCGB, NonCGB, true, false, 0x7FFF, 0x0000},
+		{"Color only cartridge on CGB", ModelCGB, OnlyCGB, true, false, 0x7FFF, 0x0000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img := testROM(CART_ROM_ONLY, Banks_0, ExtRAMNone)
+			img[0x143] = uint8(tt.flag)
+			fixChecksums(img)
+			e := NewEmulator(loadTestCartridge(t, img))
+			e.Model = tt.model
+			e.Reset()
+			d := e.MMU.GPU
+			if d.cgb != tt.cgb || d.compat != tt.compat {
+				t.Fatalf("cgb %v compat %v, want %v %v", d.cgb, d.compat, tt.cgb, tt.compat)
+			}
+			// Color registers are only mapped in Color mode
+			for _, addr := range []uint16{AddrKEY1, AddrVBK, AddrBCPS} {
+				if got := e.MMU.Read(addr); (got != 0xFF) != tt.cgb {
+					t.Errorf("%04X reads %02X", addr, got)
+				}
+			}
+
+			// Background uses shade 1 and the sprite shade 2 of OBP1
+			setTile(d, 0, 1)
+			setTile(d, 1, 2)
+			setTileMap(d, 0x1800, func(x, y int) uint8 { return 0 })
+			d.lcdc.RawSet(0x93)
+			d.bgp.RawSet(0xE4)
+			d.obp1.RawSet(0xE4)
+			copy(d.oam[:], []uint8{16, 8 + 8, 1, Bit4})
+			d.drawRow(0)
+			if got := d.spriteBuffer[0]; got != tt.bgColor {
+				t.Errorf("background color %04X, want %04X", got, tt.bgColor)
+			}
+			if got := d.spriteBuffer[8]; got != tt.objColor {
+				t.Errorf("sprite color %04X, want %04X", got, tt.objColor)
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package goboy
+
+// OAMDMA copies sprite attributes to OAM one byte per 4 cycles, taking 160
+// machine cycles in total. While the transfer is running CPU can only access
+// I/O registers and HRAM.
+type OAMDMA struct {
+	mmu     *MMU
+	active  bool
+	started bool
+	// Bus is in use from the first copied byte until the transfer ends,
+	// restarting the transfer keeps it in use
+	busy   bool
+	source uint16
+	index  int
+	// Cycles left until the transfer starts or the next byte is copied
+	timer int
+}
+
+// Start begins a new transfer from address data*0x100. The transfer starts
+// after one machine cycle, a transfer already running is replaced.
+func (dma *OAMDMA) Start(data uint8) {
+	dma.active = true
+	dma.started = false
+	dma.source = uint16(data) << 8
+	// Addresses above WRAM are mapped to WRAM
+	if dma.source >= EchoStart {
+		dma.source -= EchoOffset
+	}
+	dma.index = 0
+	dma.timer = 4
+}
+
+// Run advances the transfer by the given amount of cycles
+func (dma *OAMDMA) Run(cycles int) {
+	if !dma.active {
+		return
+	}
+	dma.timer -= cycles
+	for dma.active && dma.timer <= 0 {
+		dma.timer += 4
+		if !dma.started {
+			dma.started = true
+			dma.busy = true
+			continue
+		}
+		dma.mmu.GPU.oam[dma.index] = dma.mmu.read(dma.source + uint16(dma.index))
+		dma.index++
+		if dma.index == OAMSize {
+			dma.active = false
+			dma.busy = false
+		}
+	}
+}
+
+// Blocks reports whether CPU access to addr conflicts with the running
+// transfer. I/O registers, HRAM and IE aren't on the bus used by DMA.
+func (dma *OAMDMA) Blocks(addr uint16) bool {
+	return dma.busy && addr < IOPortsStart
+}
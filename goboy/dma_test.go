@@ -0,0 +1,170 @@
+package goboy
+
+import (
+	"testing"
+)
+
+// startDMA fills WRAM page src with a pattern and starts OAM DMA from it
+func startDMA(t *testing.T, src uint8) *Emulator {
+	t.Helper()
+	e := testEmulator(t, ModelDMG, 0x18, 0xFE)
+	e.MMU.GPU.UnrestrictedAccess = true
+	for i := 0; i < OAMSize; i++ {
+		e.MMU.Write(uint16(src)<<8+uint16(i), dmaPattern(i))
+	}
+	e.MMU.Write(AddrDMA, src)
+	return e
+}
+
+// copiedOAM returns the number of bytes copied from the pattern of startDMA
+func copiedOAM(e *Emulator) int {
+	var n int
+	for i, b := range e.MMU.GPU.oam {
+		if b == dmaPattern(i) {
+			n++
+		}
+	}
+	return n
+}
+
+// dmaPattern is the value of byte i of a transfer, it differs from cleared OAM
+func dmaPattern(i int) uint8 {
+	return uint8(i) + 1
+}
+
+func TestOAMDMATiming(t *testing.T) {
+	tests := []struct {
+		cycles int
+		copied int
+		active bool
+		blocks bool
+	}{
+		{0, 0, true, false},
+		// Transfer starts after one machine cycle
+		{4, 0, true, true},
+		{8, 1, true, true},
+		{4 + 80*4, 80, true, true},
+		{160 * 4, 159, true, true},
+		{4 + 160*4, 160, false, false},
+		{1000, 160, false, false},
+	}
+	for _, tt := range tests {
+		e := startDMA(t, 0xC0)
+		e.MMU.DMA.Run(tt.cycles)
+		if got := copiedOAM(e); got != tt.copied {
+			t.Errorf("%d cycles: copied %d bytes, want %d", tt.cycles, got, tt.copied)
+		}
+		if e.MMU.DMA.active != tt.active {
+			t.Errorf("%d cycles: active %v, want %v", tt.cycles, e.MMU.DMA.active, tt.active)
+		}
+		if got := e.MMU.DMA.Blocks(WRAMStart); got != tt.blocks {
+			t.Errorf("%d cycles: blocks WRAM %v, want %v", tt.cycles, got, tt.blocks)
+		}
+	}
+}
+
+func TestOAMDMASources(t *testing.T) {
+	tests := []struct {
+		name string
+		src  uint8
+		fill uint16
+	}{
+		{"VRAM", 0x80, 0x8000},
+		{"WRAM", 0xC1, 0xC100},
+		{"WRAM bank 1", 0xDF, 0xDF00},
+		{"echo maps to WRAM", 0xE2, 0xC200},
+		{"above echo maps to WRAM", 0xFE, 0xDE00},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := startDMA(t, 0xC0)
+			for i := 0; i < OAMSize; i++ {
+				e.MMU.Write(tt.fill+uint16(i), dmaPattern(i))
+			}
+			e.MMU.GPU.oam = [OAMSize]uint8{}
+			e.MMU.DMA.Start(tt.src)
+			e.MMU.DMA.Run(4 + 160*4)
+			if got := copiedOAM(e); got != OAMSize {
+				t.Errorf("copied %d bytes, want %d", got, OAMSize)
+			}
+		})
+	}
+}
+
+func TestOAMDMABusConflicts(t *testing.T) {
+	tests := []struct {
+		name    string
+		addr    uint16
+		blocked bool
+	}{
+		{"ROM", 0x0150, true},
+		{"switchable ROM", 0x4000, true},
+		{"VRAM", 0x8000, true},
+		{"WRAM", 0xC123, true},
+		{"echo", 0xE123, true},
+		{"OAM", 0xFE00, true},
+		{"joypad", AddrJoy, false},
+		{"IF", AddrIF, false},
+		{"BGP", AddrBGP, false},
+		{"DMA", AddrDMA, false},
+		{"HRAM", 0xFF80, false},
+		{"HRAM end", 0xFFFE, false},
+		{"IE", AddrIE, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := startDMA(t, 0xC0)
+			e.MMU.DMA.Run(4 + 10*4)
+			if tt.addr != AddrDMA {
+				e.MMU.Write(tt.addr, 0x00)
+			}
+			want := e.MMU.read(tt.addr)
+			if tt.blocked {
+				want = 0xFF
+			}
+			if got := e.MMU.Read(tt.addr); got != want {
+				t.Errorf("read %02X, want %02X", got, want)
+			}
+			if tt.addr == AddrDMA {
+				if got := e.MMU.Read(AddrDMA); got != 0xC0 {
+					t.Errorf("DMA register read %02X, want C0", got)
+				}
+				return
+			}
+			old := e.MMU.read(tt.addr)
+			e.MMU.Write(tt.addr, 0x11)
+			if changed := e.MMU.read(tt.addr) != old; changed == tt.blocked {
+				t.Errorf("write changed value %v, want %v", changed, !tt.blocked)
+			}
+		})
+	}
+}
+
+func TestOAMDMARestart(t *testing.T) {
+	e := startDMA(t, 0xC0)
+	for i := 0; i < OAMSize; i++ {
+		e.MMU.Write(0xC100+uint16(i), 0xA5)
+	}
+	e.MMU.DMA.Run(4 + 100*4)
+	e.MMU.Write(AddrDMA, 0xC1)
+	if got := e.MMU.Read(AddrDMA); got != 0xC1 {
+		t.Errorf("DMA register read %02X, want C1", got)
+	}
+	// Bus stays in use while the new transfer starts
+	if !e.MMU.DMA.Blocks(WRAMStart) {
+		t.Error("restarted transfer doesn't block the bus")
+	}
+	e.MMU.DMA.Run(4)
+	if !e.MMU.DMA.Blocks(WRAMStart) {
+		t.Error("restarted transfer doesn't block the bus after starting")
+	}
+	e.MMU.DMA.Run(160 * 4)
+	if e.MMU.DMA.Blocks(WRAMStart) {
+		t.Error("bus blocked after restarted transfer")
+	}
+	for i, b := range e.MMU.GPU.oam {
+		if b != 0xA5 {
+			t.Fatalf("OAM byte %d is %02X, want A5 from the new source", i, b)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package goboy
+
+import "io"
+
+// ClockSpeed is the number of clock cycles per second
+const ClockSpeed = 4194304
+
+// CyclesPerFrame is the number of clock cycles it takes to draw one frame
+const CyclesPerFrame = (OAMDuration + TransferDuration + HBlankDuration) * 154
+
+// Emulator wires CPU, memory and cartridge together and runs them in sync.
+// It doesn't depend on any frontend, so it can be run headless.
+type Emulator struct {
+	CPU       *CPU
+	MMU       *MMU
+	Cartridge *Cartridge
+	// Model is the emulated hardware, changes take effect on Reset
+	Model Model
+	// BootROM is run on reset when set, otherwise the emulator starts from
+	// the state boot ROM leaves the machine in
+	BootROM []byte
+	// linkCable is connected to the serial port, it stays connected on Reset
+	linkCable LinkCable
+	// sampleRate is the audio output rate kept over Reset, 0 uses the default
+	sampleRate int
+}
+
+// NewEmulator creates an emulator in the state after the boot ROM has finished,
+// running the model the cartridge is made for
+func NewEmulator(cart *Cartridge) *Emulator {
+	e := &Emulator{
+		Cartridge: cart,
+		Model:     DefaultModel(cart),
+	}
+	e.Reset()
+	return e
+}
+
+// Reset turns the power off and on again. Contents of cartridge RAM are kept.
+func (e *Emulator) Reset() {
+	if mbc, ok := e.Cartridge.MBC.(resetter); ok {
+		mbc.reset()
+	}
+	e.MMU = NewMMU(e.Cartridge, e.Model)
+	e.MMU.Serial.cable = e.linkCable
+	if e.sampleRate != 0 {
+		e.MMU.APU.SetSampleRate(e.sampleRate)
+	}
+	e.CPU = &CPU{
+		Memory: e.MMU,
+	}
+	if e.BootROM != nil {
+		e.MMU.setBootROM(e.BootROM)
+		return
+	}
+	e.skipBoot()
+}
+
+// StepInstruction runs a single CPU instruction, the rest of the system is
+// ticked by the CPU as it runs. Returns the number of cycles taken.
+func (e *Emulator) StepInstruction() int {
+	return e.CPU.RunSingleOpcode()
+}
+
+// RunFrame runs the emulator until the next frame has been drawn. When the
+// LCD is off it returns after the time of one frame has passed.
+func (e *Emulator) RunFrame() {
+	var cycles int
+	frameCycles := CyclesPerFrame
+	if speed := e.MMU.Speed; speed != nil && speed.DoubleSpeed {
+		frameCycles *= 2
+	}
+	for !e.MMU.frameReady && cycles < frameCycles {
+		cycles += e.StepInstruction()
+	}
+	e.MMU.frameReady = false
+}
+
+// SetInput sets the currently pressed keys
+func (e *Emulator) SetInput(keys Keystate) {
+	e.MMU.Pad.Update(keys)
+}
+
+// Framebuffer returns the last drawn frame as RGB555 colors. On Super Game
+// Boy the frame includes the border.
+func (e *Emulator) Framebuffer() []uint16 {
+	if e.MMU.SGB != nil {
+		return e.MMU.SGB.Framebuffer()
+	}
+	return e.MMU.GPU.ScreenBuffer()
+}
+
+// ScreenSize returns the width and height of the frames in pixels
+func (e *Emulator) ScreenSize() (int, int) {
+	if e.Model == ModelSGB {
+		return SGBWidth, SGBHeight
+	}
+	return ScreenWidth, ScreenHeight
+}
+
+// AudioSamples returns stereo samples generated since the previous call,
+// interleaved left and right channel
+func (e *Emulator) AudioSamples() []int16 {
+	return e.MMU.APU.TakeSamples()
+}
+
+// SetLinkCable connects cable to the serial port, nil disconnects it
+func (e *Emulator) SetLinkCable(cable LinkCable) {
+	e.linkCable = cable
+	e.MMU.Serial.cable = cable
+}
+
+// SetSampleRate sets the rate of generated audio samples in Hz, rates
+// below 1 Hz select DefaultSampleRate
+func (e *Emulator) SetSampleRate(rate int) {
+	e.MMU.APU.SetSampleRate(rate)
+	e.sampleRate = e.MMU.APU.SampleRate()
+}
+
+// SaveState writes the state of the whole machine into w
+func (e *Emulator) SaveState(w io.Writer) error {
+	return e.CPU.SaveState(w)
+}
+
+// LoadState restores machine state written by SaveState
+func (e *Emulator) LoadState(r io.Reader) error {
+	return e.CPU.LoadState(r)
+}
@@ -0,0 +1,214 @@
+package goboy
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestStepInstructionCycles(t *testing.T) {
+	tests := []struct {
+		name   string
+		prog   []byte
+		cycles int
+		pc     uint16
+	}{
+		{"NOP", []byte{0x00}, 4, 0x0101},
+		{"LD BC,d16", []byte{0x01, 0x34, 0x12}, 12, 0x0103},
+		{"LDH A,(a8)", []byte{0xF0, 0x44}, 12, 0x0102},
+		{"JR taken", []byte{0x18, 0x10}, 12, 0x0112},
+		{"JR NC not taken", []byte{0x30, 0x10}, 8, 0x0102},
+		{"JP a16", []byte{0xC3, 0x00, 0x02}, 16, 0x0200},
+		{"CALL a16", []byte{0xCD, 0x00, 0x02}, 24, 0x0200},
+		{"PUSH BC", []byte{0xC5}, 16, 0x0101},
+		{"RST 38", []byte{0xFF}, 16, 0x0038},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Header checksum isn't 0, so DMG starts with carry set
+			e := testEmulator(t, ModelDMG, tt.prog...)
+			if got := e.StepInstruction(); got != tt.cycles {
+				t.Errorf("took %d cycles, want %d", got, tt.cycles)
+			}
+			if e.CPU.PC != tt.pc {
+				t.Errorf("PC %04X, want %04X", e.CPU.PC, tt.pc)
+			}
+		})
+	}
+}
+
+func TestRunFrame(t *testing.T) {
+	tests := []struct {
+		name   string
+		prog   []byte
+		wantLY uint8
+	}{
+		// JR -2
+		{"LCD on", []byte{0x18, 0xFE}, 144},
+		// XOR A; LDH (LCDC),A; JR -2
+		{"LCD off", []byte{0xAF, 0xE0, 0x40, 0x18, 0xFE}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := testEmulator(t, ModelDMG, tt.prog...)
+			e.RunFrame()
+			for i := 0; i < 3; i++ {
+				start := e.MMU.Timer.counter
+				e.RunFrame()
+				if got := e.MMU.Read(AddrLY); got != tt.wantLY {
+					t.Errorf("frame %d: LY %d, want %d", i, got, tt.wantLY)
+				}
+				// 16-bit counter wraps once during a frame
+				elapsed := int(e.MMU.Timer.counter-start) + 0x10000
+				if elapsed < CyclesPerFrame-12 || elapsed > CyclesPerFrame+12 {
+					t.Errorf("frame %d took %d cycles, want %d", i, elapsed, CyclesPerFrame)
+				}
+			}
+		})
+	}
+}
+
+func TestFramebuffer(t *testing.T) {
+	tests := []struct {
+		bgp  uint8
+		want uint16
+	}{
+		{0xFC, DMGPalette[0]},
+		{0xE5, DMGPalette[1]},
+		{0x02, DMGPalette[2]},
+		{0xFF, DMGPalette[3]},
+	}
+	for _, tt := range tests {
+		// LD A,bgp; LDH (BGP),A; JR -2
+		e := testEmulator(t, ModelDMG, 0x3E, tt.bgp, 0xE0, 0x47, 0x18, 0xFE)
+		e.RunFrame()
+		e.RunFrame()
+		for i, c := range e.Framebuffer() {
+			if c != tt.want {
+				t.Errorf("BGP %02X: pixel %d is %04X, want %04X", tt.bgp, i, c, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestScreenSize(t *testing.T) {
+	tests := []struct {
+		model         Model
+		width, height int
+	}{
+		{ModelDMG, ScreenWidth, ScreenHeight},
+		{ModelMGB, ScreenWidth, ScreenHeight},
+		{ModelCGB, ScreenWidth, ScreenHeight},
+		{ModelSGB, SGBWidth, SGBHeight},
+	}
+	for _, tt := range tests {
+		e := testEmulator(t, tt.model, 0x18, 0xFE)
+		e.RunFrame()
+		w, h := e.ScreenSize()
+		if w != tt.width || h != tt.height {
+			t.Errorf("%v: screen %dx%d, want %dx%d", tt.model, w, h, tt.width, tt.height)
+		}
+		if got := len(e.Framebuffer()); got != w*h {
+			t.Errorf("%v: framebuffer has %d pixels, want %d", tt.model, got, w*h)
+		}
+	}
+}
+
+func TestSetInput(t *testing.T) {
+	tests := []struct {
+		name       string
+		keys       Keystate
+		buttons    uint8
+		directions uint8
+	}{
+		{"nothing pressed", Keystate{}, 0xDF, 0xEF},
+		{"A and Start", Keystate{A: true, Start: true}, 0xD6, 0xEF},
+		{"B and Select", Keystate{B: true, Select: true}, 0xD9, 0xEF},
+		{"Up and Left", Keystate{Up: true, Left: true}, 0xDF, 0xE9},
+		{"Down and Right", Keystate{Down: true, Right: true}, 0xDF, 0xE6},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := testEmulator(t, ModelDMG, 0x18, 0xFE)
+			e.MMU.Write(AddrIF, 0)
+			e.SetInput(tt.keys)
+			e.MMU.Write(AddrJoy, 0x10)
+			if got := e.MMU.Read(AddrJoy); got != tt.buttons {
+				t.Errorf("buttons read %02X, want %02X", got, tt.buttons)
+			}
+			e.MMU.Write(AddrJoy, 0x20)
+			if got := e.MMU.Read(AddrJoy); got != tt.directions {
+				t.Errorf("directions read %02X, want %02X", got, tt.directions)
+			}
+			pressed := tt.keys != Keystate{}
+			if got := e.MMU.Read(AddrIF)&(1<<JoypadInt) != 0; got != pressed {
+				t.Errorf("joypad interrupt requested %v, want %v", got, pressed)
+			}
+		})
+	}
+}
+
+func TestReset(t *testing.T) {
+	for _, model := range []Model{ModelDMG, ModelCGB, ModelSGB} {
+		t.Run(model.String(), func(t *testing.T) {
+			e := testEmulator(t, model, fillProgram...)
+			want := saveState(t, e)
+			runInstructions(e, 5000)
+			e.Reset()
+			if got := saveState(t, e); !bytes.Equal(got, want) {
+				t.Error("state after reset differs from power on")
+			}
+		})
+	}
+}
+
+func TestResetKeepsCartridgeRAM(t *testing.T) {
+	e := NewEmulator(loadTestCartridge(t, testROM(CART_MBC5_RAM_BATTERY, Banks_16, ExtRAM32KB)))
+	e.MMU.Write(0x0000, 0x0A)
+	e.MMU.Write(0x4000, 0x03)
+	e.MMU.Write(0xA000, 0x42)
+	e.MMU.Write(0x2000, 0x07)
+	e.Reset()
+	if got := romBank(e.Cartridge); got != 1 {
+		t.Errorf("ROM bank %d after reset, want 1", got)
+	}
+	e.MMU.Write(0x0000, 0x0A)
+	e.MMU.Write(0x4000, 0x03)
+	if got := e.MMU.Read(0xA000); got != 0x42 {
+		t.Errorf("RAM read %02X after reset, want 42", got)
+	}
+}
+
+func TestSampleRateKeptOnReset(t *testing.T) {
+	e := testEmulator(t, ModelDMG, 0x18, 0xFE)
+	e.SetSampleRate(22050)
+	e.Reset()
+	if got := e.MMU.APU.SampleRate(); got != 22050 {
+		t.Errorf("sample rate %d after reset, want 22050", got)
+	}
+	e.RunFrame()
+	e.AudioSamples()
+	e.RunFrame()
+	want := 22050 * CyclesPerFrame / ClockSpeed
+	if got := len(e.AudioSamples()) / 2; got < want-1 || got > want+1 {
+		t.Errorf("%d samples in a frame, want %d", got, want)
+	}
+}
+
+// benchProgram mixes I/O, WRAM and ROM accesses in a loop:
+// LD HL,0xC000; LDH A,(LY); LD (HL+),A; INC B; LD A,H; AND 0xDF; LD H,A; JR -10
+var benchProgram = []byte{0x21, 0x00, 0xC0, 0xF0, 0x44, 0x22, 0x04, 0x7C, 0xE6, 0xDF, 0x67, 0x18, 0xF6}
+
+func BenchmarkStepInstruction(b *testing.B) {
+	e := testEmulator(b, ModelDMG, benchProgram...)
+	b.ResetTimer()
+	start := time.Now()
+	for i := 0; i < b.N; i++ {
+		e.StepInstruction()
+	}
+	b.ReportMetric(float64(b.N)/time.Since(start).Seconds(), "instr/s")
+	if e.CPU.PC < 0x0103 || e.CPU.PC > 0x010C {
+		b.Fatalf("PC %04X left the loop", e.CPU.PC)
+	}
+}
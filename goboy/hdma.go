@@ -0,0 +1,113 @@
+package goboy
+
+const (
+	AddrHDMA1 = 0xFF51
+	AddrHDMA2 = 0xFF52
+	AddrHDMA3 = 0xFF53
+	AddrHDMA4 = 0xFF54
+	AddrHDMA5 = 0xFF55
+)
+
+// hdmaBlockSize is the number of bytes copied at once
+const hdmaBlockSize = 0x10
+
+// HDMA copies data into VRAM on Color hardware. General purpose DMA copies
+// everything at once and H-Blank DMA copies 16 bytes at the start of each
+// HBlank. CPU is halted while data is copied.
+type HDMA struct {
+	mmu    *MMU
+	source uint16
+	dest   uint16
+	// Number of 16 byte blocks left to copy
+	blocks int
+	// H-Blank DMA is in progress
+	active bool
+}
+
+// start starts a transfer when HDMA5 is written. Bit 7 selects H-Blank DMA
+// and the rest is the number of blocks minus one. Writing bit 7=0 during
+// H-Blank DMA cancels it.
+func (h *HDMA) start(data uint8) {
+	if h.active && data&Bit7 == 0 {
+		h.active = false
+		return
+	}
+	h.blocks = int(data&0x7F) + 1
+	if data&Bit7 != 0 {
+		h.active = true
+		return
+	}
+	for h.blocks > 0 {
+		h.copyBlock()
+	}
+}
+
+// hblank is called by display at the start of HBlank
+func (h *HDMA) hblank() {
+	if !h.active {
+		return
+	}
+	h.copyBlock()
+	if h.blocks == 0 {
+		h.active = false
+	}
+}
+
+// copyBlock copies 16 bytes and halts CPU for the time it takes
+func (h *HDMA) copyBlock() {
+	d := h.mmu.GPU
+	for i := 0; i < hdmaBlockSize; i++ {
+		d.VRAM[d.vramBank*VideoRAMSize+int(h.dest&0x1FFF)] = h.mmu.read(h.source)
+		h.source++
+		h.dest = VideoRAMStart | (h.dest+1)&0x1FFF
+	}
+	h.blocks--
+	// Copying takes 8 machine cycles, or 16 in double speed
+	cycles := 32
+	if h.mmu.Speed.DoubleSpeed {
+		cycles *= 2
+	}
+	h.mmu.stall += cycles
+}
+
+// status is the value of HDMA5, remaining blocks minus one while H-Blank DMA
+// is active and 0xFF after it has finished. Bit 7 is set when not active.
+func (h *HDMA) status() uint8 {
+	remaining := uint8(h.blocks-1) & 0x7F
+	if h.active {
+		return remaining
+	}
+	return Bit7 | remaining
+}
+
+// hdmaRegister exposes one of the HDMA registers to the MMU
+type hdmaRegister struct {
+	hdma *HDMA
+	addr uint16
+}
+
+// RawSet does nothing, the state is saved separately
+func (r hdmaRegister) RawSet(uint8) {}
+
+func (r hdmaRegister) Set(data uint8) {
+	h := r.hdma
+	switch r.addr {
+	case AddrHDMA1:
+		h.source = uint16(data)<<8 | h.source&0xFF
+	case AddrHDMA2:
+		h.source = h.source&0xFF00 | uint16(data&0xF0)
+	case AddrHDMA3:
+		h.dest = VideoRAMStart | uint16(data&0x1F)<<8 | h.dest&0xFF
+	case AddrHDMA4:
+		h.dest = h.dest&0xFF00 | uint16(data&0xF0)
+	case AddrHDMA5:
+		h.start(data)
+	}
+}
+
+func (r hdmaRegister) Get() uint8 {
+	if r.addr == AddrHDMA5 {
+		return r.hdma.status()
+	}
+	return 0xFF
+}
@@ -0,0 +1,151 @@
+package goboy
+
+import (
+	"testing"
+)
+
+// startHDMA fills WRAM at 0xC000 with dmaPattern and sets the source and
+// destination registers. The emulator stores A with LDH (0x55),A.
+func startHDMA(t *testing.T, source, dest uint16) *Emulator {
+	t.Helper()
+	e := testEmulator(t, ModelCGB, 0xE0, 0x55, 0x18, 0xFE)
+	for i := 0; i < 0x1000; i++ {
+		e.MMU.Write(WRAMStart+uint16(i), dmaPattern(i))
+	}
+	e.MMU.Write(AddrHDMA1, uint8(source>>8))
+	e.MMU.Write(AddrHDMA2, uint8(source))
+	e.MMU.Write(AddrHDMA3, uint8(dest>>8))
+	e.MMU.Write(AddrHDMA4, uint8(dest))
+	return e
+}
+
+// copiedVRAM returns the length of the pattern copied from the start of WRAM
+// to offset of VRAM bank
+func copiedVRAM(e *Emulator, bank, offset int) int {
+	vram := e.MMU.GPU.VRAM[bank*VideoRAMSize:][:VideoRAMSize]
+	n := 0
+	for n < VideoRAMSize && vram[(offset+n)&0x1FFF] == dmaPattern(n) {
+		n++
+	}
+	return n
+}
+
+func TestGeneralPurposeDMA(t *testing.T) {
+	tests := []struct {
+		name   string
+		dest   uint16
+		hdma5  uint8
+		bank   uint8
+		double bool
+		offset int
+		copied int
+	}{
+		{"one block", 0x8000, 0x00, 0, false, 0, 0x10},
+		{"many blocks", 0x8800, 0x0F, 0, false, 0x800, 0x100},
+		{"largest transfer", 0x8000, 0x7F, 0, false, 0, 0x800},
+		{"low bits of destination are ignored", 0x810F, 0x01, 0, false, 0x100, 0x20},
+		{"destination upper bits are ignored", 0xE000, 0x00, 0, false, 0, 0x10},
+		{"destination wraps within VRAM", 0x9FF0, 0x01, 0, false, 0x1FF0, 0x20},
+		{"second VRAM bank", 0x8000, 0x03, 1, false, 0, 0x40},
+		{"double speed", 0x8000, 0x03, 0, true, 0, 0x40},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Low 4 bits of source are ignored too
+			e := startHDMA(t, WRAMStart|0x0F, tt.dest)
+			e.MMU.Write(AddrVBK, tt.bank)
+			e.MMU.Speed.DoubleSpeed = tt.double
+			e.CPU.A = tt.hdma5
+			cycles := e.StepInstruction()
+			if got := copiedVRAM(e, int(tt.bank), tt.offset); got != tt.copied {
+				t.Errorf("copied %d bytes, want %d", got, tt.copied)
+			}
+			// CPU is halted for 8 machine cycles per block after the write
+			blockCycles := 32
+			if tt.double {
+				blockCycles = 64
+			}
+			blocks := int(tt.hdma5) + 1
+			if want := 12 + blocks*blockCycles; cycles != want {
+				t.Errorf("took %d cycles, want %d", cycles, want)
+			}
+			if got := e.MMU.Read(AddrHDMA5); got != 0xFF {
+				t.Errorf("HDMA5 reads %02X after transfer, want FF", got)
+			}
+		})
+	}
+}
+
+func TestHBlankDMA(t *testing.T) {
+	e := startHDMA(t, WRAMStart, VideoRAMStart)
+	d := e.MMU.GPU
+	e.MMU.Write(AddrHDMA5, Bit7|0x02)
+	if got := copiedVRAM(e, 0, 0); got != 0 {
+		t.Fatalf("copied %d bytes before HBlank", got)
+	}
+	// One block is copied at the start of each HBlank
+	for row := 1; row <= 3; row++ {
+		runUntilRow(d, row)
+		if got := copiedVRAM(e, 0, 0); got != row*hdmaBlockSize {
+			t.Errorf("row %d: copied %d bytes, want %d", row, got, row*hdmaBlockSize)
+		}
+		want := uint8(2 - row)
+		if row == 3 {
+			want = 0xFF
+		}
+		if got := e.MMU.Read(AddrHDMA5); got != want {
+			t.Errorf("row %d: HDMA5 reads %02X, want %02X", row, got, want)
+		}
+		if e.MMU.stall != 32*row {
+			t.Errorf("row %d: CPU halted for %d cycles", row, e.MMU.stall)
+		}
+	}
+	runUntilRow(d, 5)
+	if got := copiedVRAM(e, 0, 0); got != 3*hdmaBlockSize {
+		t.Errorf("copied %d bytes after the transfer ended", got)
+	}
+}
+
+func TestHBlankDMACancel(t *testing.T) {
+	e := startHDMA(t, WRAMStart, VideoRAMStart)
+	d := e.MMU.GPU
+	e.MMU.Write(AddrHDMA5, Bit7|0x05)
+	runUntilRow(d, 1)
+	runUntilRow(d, 2)
+	// Writing bit 7=0 stops the transfer, remaining length can be read back
+	e.MMU.Write(AddrHDMA5, 0x00)
+	if got := e.MMU.Read(AddrHDMA5); got != Bit7|0x03 {
+		t.Errorf("HDMA5 reads %02X after cancel, want 83", got)
+	}
+	runUntilRow(d, 5)
+	if got := copiedVRAM(e, 0, 0); got != 2*hdmaBlockSize {
+		t.Errorf("copied %d bytes, want %d", got, 2*hdmaBlockSize)
+	}
+	// Source and destination continue from where the transfer stopped
+	e.MMU.Write(AddrHDMA5, 0x00)
+	if got := copiedVRAM(e, 0, 0); got != 3*hdmaBlockSize {
+		t.Errorf("copied %d bytes after restart, want %d", got, 3*hdmaBlockSize)
+	}
+}
+
+// Display keeps drawing rows while CPU is halted by a long transfer
+func TestGeneralPurposeDMAMidFrame(t *testing.T) {
+	e := startHDMA(t, WRAMStart, 0x8800)
+	d := e.MMU.GPU
+	setTile(d, 0, 3)
+	d.bgColors.setColors(0, [4]uint16{0, 0, 0, 0x1234})
+	runUntilRow(d, 100)
+	for i := range d.spriteBuffer {
+		d.spriteBuffer[i] = 0
+	}
+	e.CPU.A = 0x7F
+	e.StepInstruction()
+	if d.row < 109 {
+		t.Fatalf("display at row %d after the transfer", d.row)
+	}
+	for row := 100; row < 109; row++ {
+		if color := d.spriteBuffer[row*ScreenWidth]; color != 0x1234 {
+			t.Errorf("row %d not drawn during the transfer", row)
+		}
+	}
+}
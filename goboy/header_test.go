@@ -0,0 +1,126 @@
+package goboy
+
+import (
+	"testing"
+)
+
+// testHeader returns the first bank of a ROM with the given bytes set
+func testHeader(fields map[int][]byte) []byte {
+	bank0 := make([]byte, ROMBankSize)
+	for offset, data := range fields {
+		copy(bank0[offset:], data)
+	}
+	return bank0
+}
+
+func TestParseHeader(t *testing.T) {
+	tests := []struct {
+		name   string
+		fields map[int][]byte
+		check  func(h Header) bool
+	}{
+		{
+			"16 character title",
+			map[int][]byte{0x134: []byte("POKEMON RED\x00\x00\x00\x00\x00")},
+			func(h Header) bool { return h.Title == "POKEMON RED" && h.CGBFlag == GB },
+		},
+		{
+			"title with digits and punctuation",
+			map[int][]byte{0x134: []byte("F-1 RACE 2")},
+			func(h Header) bool { return h.Title == "F-1 RACE 2" },
+		},
+		{
+			"title uses the whole area on old cartridges",
+			map[int][]byte{0x134: []byte("ABCDEFGHIJKLMNOP")},
+			func(h Header) bool { return h.Title == "ABCDEFGHIJKLMNOP" },
+		},
+		{
+			"unprintable title characters",
+			map[int][]byte{0x134: []byte("AB\x01C\xFF")},
+			func(h Header) bool { return h.Title == "AB?C?" },
+		},
+		{
+			"CGB flag and manufacturer code",
+			map[int][]byte{0x134: []byte("ZELDA\x00\x00\x00\x00\x00\x00AZ7E\x80")},
+			func(h Header) bool {
+				return h.Title == "ZELDA" && h.ManufacturerCode == "AZ7E" && h.CGBFlag == NonCGB
+			},
+		},
+		{
+			"CGB only without manufacturer code",
+			map[int][]byte{0x134: []byte("CGB GAME\x00\x00\x00\x00\x00\x00\x00\xC0")},
+			func(h Header) bool {
+				return h.Title == "CGB GAME" && h.ManufacturerCode == "" && h.CGBFlag == OnlyCGB
+			},
+		},
+		{
+			"unknown CGB flag is treated as old cartridge",
+			map[int][]byte{0x143: {0x42}},
+			func(h Header) bool { return h.CGBFlag == GB },
+		},
+		{
+			"RAM size is read from 0x149",
+			map[int][]byte{0x147: {uint8(CART_MBC1_RAM)}, 0x148: {uint8(Banks_32)}, 0x149: {uint8(ExtRAM32KB)}},
+			func(h Header) bool {
+				return h.CartridgeType == CART_MBC1_RAM && h.ROMBanks == Banks_32 && h.ExtRAMBanks == ExtRAM32KB
+			},
+		},
+		{
+			"new licensee code",
+			map[int][]byte{0x144: []byte("01"), 0x14B: {0x33}},
+			func(h Header) bool { return h.NewLicenseeCode == "01" && h.Publisher() == "Nintendo R&D1" },
+		},
+		{
+			"old licensee code",
+			map[int][]byte{0x144: []byte("01"), 0x14B: {0x08}},
+			func(h Header) bool { return h.NewLicenseeCode == "" && h.Publisher() == "Capcom" },
+		},
+		{
+			"unknown licensee",
+			map[int][]byte{0x14B: {0x02}},
+			func(h Header) bool { return h.Publisher() == "Unknown (02)" },
+		},
+		{
+			"SGB support needs new licensee code",
+			map[int][]byte{0x146: {uint8(SGBFunctions)}, 0x14B: {0x33}},
+			func(h Header) bool { return h.SGBSupported() },
+		},
+		{
+			"SGB flag ignored with old licensee code",
+			map[int][]byte{0x146: {uint8(SGBFunctions)}, 0x14B: {0x01}},
+			func(h Header) bool { return !h.SGBSupported() },
+		},
+		{
+			"version and checksums",
+			map[int][]byte{0x14A: {0x01}, 0x14C: {0x02}, 0x14D: {0x3C}, 0x14E: {0x12, 0x34}},
+			func(h Header) bool {
+				return h.DestinationCode == DestinationNonJP && h.MaskROMVersion == 2 &&
+					h.HeaderChecksum == 0x3C && h.GlobalChecksum == 0x1234
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if h := ParseHeader(testHeader(tt.fields)); !tt.check(h) {
+				t.Errorf("unexpected header %+v", h)
+			}
+		})
+	}
+}
+
+// Every value of every header byte must parse and print without panicking
+func TestHeaderNoPanic(t *testing.T) {
+	for offset := 0x134; offset < 0x150; offset++ {
+		for val := 0; val < 0x100; val++ {
+			bank0 := testHeader(map[int][]byte{
+				0x134:  []byte("TITLE"),
+				offset: {uint8(val)},
+			})
+			cart := &Cartridge{Header: ParseHeader(bank0)}
+			_ = cart.String()
+			_ = cart.Header.Publisher()
+			_ = cart.Header.ROMBanks.Count()
+			_ = cart.Header.ExtRAMBanks.Size()
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package goboy
+
+import (
+	"bytes"
+	"testing"
+)
+
+// testROM builds a ROM image with a valid header. The first two bytes of
+// every bank hold the bank number so tests can tell which bank is mapped.
+func testROM(cart CartrigeType, banks ROMBanks, ram ExtRAMBanks) []byte {
+	img := make([]byte, banks.Count()*ROMBankSize)
+	for b := 0; b < banks.Count(); b++ {
+		img[b*ROMBankSize] = uint8(b)
+		img[b*ROMBankSize+1] = uint8(b >> 8)
+	}
+	copy(img[0x134:], "TEST")
+	img[0x14B] = 0x33
+	copy(img[0x144:], "01")
+	img[0x147] = uint8(cart)
+	img[0x148] = uint8(banks)
+	img[0x149] = uint8(ram)
+	fixChecksums(img)
+	return img
+}
+
+// fixChecksums updates header and global checksums after img is modified
+func fixChecksums(img []byte) {
+	img[0x14D] = headerChecksum(img)
+	sum := globalChecksum(img)
+	img[0x14E] = uint8(sum >> 8)
+	img[0x14F] = uint8(sum)
+}
+
+func loadTestCartridge(t testing.TB, img []byte) *Cartridge {
+	t.Helper()
+	cart, err := LoadCartridge(bytes.NewReader(img))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cart
+}
+
+// testEmulator creates an emulator running prog from 0x0100 on a cartridge
+// without a mapper, started from the post-boot state of model. Cartridge
+// supports Color features so Color hardware isn't in compatibility mode.
+func testEmulator(t testing.TB, model Model, prog ...byte) *Emulator {
+	t.Helper()
+	img := testROM(CART_ROM_ONLY, Banks_0, ExtRAMNone)
+	copy(img[0x100:], prog)
+	img[0x143] = uint8(NonCGB)
+	fixChecksums(img)
+	e := NewEmulator(loadTestCartridge(t, img))
+	if e.Model != model {
+		e.Model = model
+		e.Reset()
+	}
+	return e
+}
+
+func runInstructions(e *Emulator, n int) {
+	for i := 0; i < n; i++ {
+		e.StepInstruction()
+	}
+}
@@ -44,15 +44,15 @@ func init() {
 		// 0x01: LD BC, d16
 		// Load value d16 to BC
 		func(cpu *CPU) int {
-			cpu.C = cpu.Memory.Read(cpu.PC)
-			cpu.B = cpu.Memory.Read(cpu.PC + 1)
+			cpu.C = cpu.read(cpu.PC)
+			cpu.B = cpu.read(cpu.PC + 1)
 			cpu.PC += 2
 			return 12
 		},
 		// 0x02: LD (BC), A
 		// Store A into memory pointed by BC
 		func(cpu *CPU) int {
-			cpu.Memory.Write(cpu.BC(), cpu.A)
+			cpu.write(cpu.BC(), cpu.A)
 			return 8
 		},
 		// 0x03: INC BC
@@ -84,7 +84,7 @@ func init() {
 		// 0x06: LD B, n
 		// Loads value n to B
 		func(cpu *CPU) int {
-			cpu.B = cpu.Memory.Read(cpu.PC)
+			cpu.B = cpu.read(cpu.PC)
 			cpu.PC++
 			return 8
 		},
@@ -102,9 +102,9 @@ func init() {
 		// 0x08: LD (a16), SP
 		// Stores SP into address a16
 		func(cpu *CPU) int {
-			addr := uint16(cpu.Memory.Read(cpu.PC+1))<<8 | uint16(cpu.Memory.Read(cpu.PC))
-			cpu.Memory.Write(addr, uint8(cpu.SP))
-			cpu.Memory.Write(addr+1, uint8(cpu.SP>>8))
+			addr := cpu.read16(cpu.PC)
+			cpu.write(addr, uint8(cpu.SP))
+			cpu.write(addr+1, uint8(cpu.SP>>8))
 			cpu.PC += 2
 			return 20
 		},
@@ -123,7 +123,7 @@ func init() {
 		// 0x0A: LD A, (BC)
 		// Load value pointed by BC to A
 		func(cpu *CPU) int {
-			cpu.A = cpu.Memory.Read(cpu.BC())
+			cpu.A = cpu.read(cpu.BC())
 			return 8
 		},
 		// 0x0B: DEC BC
@@ -155,7 +155,7 @@ func init() {
 		// 0x0E: LD C, d8
 		// Loads value d8 to B
 		func(cpu *CPU) int {
-			cpu.C = cpu.Memory.Read(cpu.PC)
+			cpu.C = cpu.read(cpu.PC)
 			cpu.PC++
 			return 8
 		},
@@ -170,26 +170,31 @@ func init() {
 			cpu.FCarry = carry == 1
 			return 4
 		},
-		// 0x10: ???
-		// ????
+		// 0x10: STOP
+		// Enter low power mode or switch speed when KEY1 is armed
 		func(cpu *CPU) int {
 			cpu.PC++
-			// TODO: Something better than just os.Exit...
-			// fmt.Printf("exited at %x\n", cpu.PC)
+			cpu.Memory.Timer.setCounter(0)
+			if speed := cpu.Memory.Speed; speed != nil && speed.Armed {
+				speed.Armed = false
+				speed.DoubleSpeed = !speed.DoubleSpeed
+				return 4
+			}
+			cpu.Stopped = true
 			return 4
 		},
 		// 0x11: LD DE, d16
 		// Load value d16 to DE
 		func(cpu *CPU) int {
-			cpu.D = cpu.Memory.Read(cpu.PC + 1)
-			cpu.E = cpu.Memory.Read(cpu.PC)
+			cpu.E = cpu.read(cpu.PC)
+			cpu.D = cpu.read(cpu.PC + 1)
 			cpu.PC += 2
 			return 12
 		},
 		// 0x12: LD (DE), A
 		// Store A into memory pointed by DE
 		func(cpu *CPU) int {
-			cpu.Memory.Write(cpu.DE(), cpu.A)
+			cpu.write(cpu.DE(), cpu.A)
 			return 8
 		},
 		// 0x13: INC DE
@@ -221,7 +226,7 @@ func init() {
 		// 0x16: LD D, d8
 		// Load value d8 to D
 		func(cpu *CPU) int {
-			cpu.D = cpu.Memory.Read(cpu.PC)
+			cpu.D = cpu.read(cpu.PC)
 			cpu.PC++
 			return 8
 		},
@@ -246,7 +251,7 @@ func init() {
 		// 0x18: JR r8
 		// Relative jump to r8
 		func(cpu *CPU) int {
-			relJump := int(int8(cpu.Memory.Read(cpu.PC)))
+			relJump := int(int8(cpu.read(cpu.PC)))
 			tempPC := int(cpu.PC) + relJump + 1
 			cpu.PC = uint16(tempPC)
 			return 12
@@ -266,7 +271,7 @@ func init() {
 		// 0x1A: LD A, (DE)
 		// Load value pointed by BC to A
 		func(cpu *CPU) int {
-			cpu.A = cpu.Memory.Read(cpu.DE())
+			cpu.A = cpu.read(cpu.DE())
 			return 8
 		},
 		// 0x1B: DEC DE
@@ -298,7 +303,7 @@ func init() {
 		// 0x1E: LD E, d8
 		// Loads value d8 to E
 		func(cpu *CPU) int {
-			cpu.E = cpu.Memory.Read(cpu.PC)
+			cpu.E = cpu.read(cpu.PC)
 			cpu.PC++
 			return 8
 		},
@@ -327,22 +332,22 @@ func init() {
 				cpu.PC++
 				return 8
 			}
-			relJump := int32(int8(cpu.Memory.Read(cpu.PC)))
+			relJump := int32(int8(cpu.read(cpu.PC)))
 			cpu.PC = uint16(int32(cpu.PC) + relJump + 1)
 			return 12
 		},
 		// 0x21: LD HL, d16
 		// Load value d16 to HL
 		func(cpu *CPU) int {
-			cpu.H = cpu.Memory.Read(cpu.PC + 1)
-			cpu.L = cpu.Memory.Read(cpu.PC)
+			cpu.L = cpu.read(cpu.PC)
+			cpu.H = cpu.read(cpu.PC + 1)
 			cpu.PC += 2
 			return 12
 		},
 		// 0x22: LD (HL+), A
 		// Store A into memory pointed by HL and increment HL after it
 		func(cpu *CPU) int {
-			cpu.Memory.Write(cpu.HL(), cpu.A)
+			cpu.write(cpu.HL(), cpu.A)
 			temp := cpu.HL() + 1
 			cpu.H = uint8(temp >> 8)
 			cpu.L = uint8(temp)
@@ -377,7 +382,7 @@ func init() {
 		// 0x26: LD H, d8
 		// Load value d8 to H
 		func(cpu *CPU) int {
-			cpu.H = cpu.Memory.Read(cpu.PC)
+			cpu.H = cpu.read(cpu.PC)
 			cpu.PC++
 			return 8
 		},
@@ -413,7 +418,7 @@ func init() {
 				cpu.PC++
 				return 8
 			}
-			relJump := int32(int8(cpu.Memory.Read(cpu.PC)))
+			relJump := int32(int8(cpu.read(cpu.PC)))
 			cpu.PC = uint16(int32(cpu.PC) + relJump + 1)
 			return 12
 		},
@@ -431,7 +436,7 @@ func init() {
 		},
 		// 0x2A: LD A, (HL+)
 		func(cpu *CPU) int {
-			cpu.A = cpu.Memory.Read(cpu.HL())
+			cpu.A = cpu.read(cpu.HL())
 			temp := cpu.HL() + 1
 			cpu.H = uint8(temp >> 8)
 			cpu.L = uint8(temp)
@@ -466,7 +471,7 @@ func init() {
 		// 0x2E: LD L, d8
 		// Loads value d8 to L
 		func(cpu *CPU) int {
-			cpu.L = cpu.Memory.Read(cpu.PC)
+			cpu.L = cpu.read(cpu.PC)
 			cpu.PC++
 			return 8
 		},
@@ -485,21 +490,21 @@ func init() {
 				cpu.PC++
 				return 8
 			}
-			relJump := int32(int8(cpu.Memory.Read(cpu.PC)))
+			relJump := int32(int8(cpu.read(cpu.PC)))
 			cpu.PC = uint16(int32(cpu.PC) + relJump + 1)
 			return 12
 		},
 		// 0x31: LD SP, d16
 		// Load value d16 to HL
 		func(cpu *CPU) int {
-			cpu.SP = uint16(cpu.Memory.Read(cpu.PC+1))<<8 | uint16(cpu.Memory.Read(cpu.PC))
+			cpu.SP = cpu.read16(cpu.PC)
 			cpu.PC += 2
 			return 12
 		},
 		// 0x32: LD (HL-), A
 		// Store A into memory pointed by HL and decrement HL after it
 		func(cpu *CPU) int {
-			cpu.Memory.Write(cpu.HL(), cpu.A)
+			cpu.write(cpu.HL(), cpu.A)
 			temp := cpu.HL() - 1
 			cpu.H = uint8(temp >> 8)
 			cpu.L = uint8(temp)
@@ -514,27 +519,27 @@ func init() {
 		// 0x34: INC (HL)
 		// Add one value pointed by HL
 		func(cpu *CPU) int {
-			val := cpu.Memory.Read(cpu.HL()) + 1
+			val := cpu.read(cpu.HL()) + 1
 			cpu.FZero = val == 0
 			cpu.FSub = false
 			cpu.FHalfCarry = val&0xf == 0
-			cpu.Memory.Write(cpu.HL(), val)
+			cpu.write(cpu.HL(), val)
 			return 12
 		},
 		// 0x35: DEC (HL)
 		// Subtract one from value pointed by HL
 		func(cpu *CPU) int {
-			val := cpu.Memory.Read(cpu.HL()) - 1
+			val := cpu.read(cpu.HL()) - 1
 			cpu.FZero = val == 0
 			cpu.FSub = true
 			cpu.FHalfCarry = val&0xf == 0xf
-			cpu.Memory.Write(cpu.HL(), val)
+			cpu.write(cpu.HL(), val)
 			return 12
 		},
 		// 0x36: LD (HL), d8
 		// Store value d8 to byte pointed by HL
 		func(cpu *CPU) int {
-			cpu.Memory.Write(cpu.HL(), cpu.Memory.Read(cpu.PC))
+			cpu.write(cpu.HL(), cpu.read(cpu.PC))
 			cpu.PC++
 			return 12
 		},
@@ -553,7 +558,7 @@ func init() {
 				cpu.PC++
 				return 8
 			}
-			relJump := int32(int8(cpu.Memory.Read(cpu.PC)))
+			relJump := int32(int8(cpu.read(cpu.PC)))
 			cpu.PC = uint16(int32(cpu.PC) + relJump + 1)
 			return 12
 		},
@@ -572,7 +577,7 @@ func init() {
 		// 0x3A: LD A, (HL-)
 		// Load value pointed by HL and decrement HL after it
 		func(cpu *CPU) int {
-			cpu.A = cpu.Memory.Read(cpu.HL())
+			cpu.A = cpu.read(cpu.HL())
 			temp := cpu.HL() - 1
 			cpu.H = uint8(temp >> 8)
 			cpu.L = uint8(temp)
@@ -605,7 +610,7 @@ func init() {
 		// 0x3E: LD A, d8
 		// Loads value d8 to A
 		func(cpu *CPU) int {
-			cpu.A = cpu.Memory.Read(cpu.PC)
+			cpu.A = cpu.read(cpu.PC)
 			cpu.PC++
 			return 8
 		},
@@ -649,7 +654,7 @@ func init() {
 		},
 		// 0x46: LD B, (HL)
 		func(cpu *CPU) int {
-			cpu.B = cpu.Memory.Read(cpu.HL())
+			cpu.B = cpu.read(cpu.HL())
 			return 8
 		},
 		// 0x47: LD B, A
@@ -689,7 +694,7 @@ func init() {
 		},
 		// 0x4E: LD C, (HL)
 		func(cpu *CPU) int {
-			cpu.C = cpu.Memory.Read(cpu.HL())
+			cpu.C = cpu.read(cpu.HL())
 			return 8
 		},
 		// 0x4F: LD C, A
@@ -729,7 +734,7 @@ func init() {
 		},
 		// 0x56: LD D, (HL)
 		func(cpu *CPU) int {
-			cpu.D = cpu.Memory.Read(cpu.HL())
+			cpu.D = cpu.read(cpu.HL())
 			return 8
 		},
 		// 0x57: LD D, A
@@ -769,7 +774,7 @@ func init() {
 		},
 		// 0x5E: LD E, (HL)
 		func(cpu *CPU) int {
-			cpu.E = cpu.Memory.Read(cpu.HL())
+			cpu.E = cpu.read(cpu.HL())
 			return 8
 		},
 		// 0x5F: LD E, A
@@ -809,7 +814,7 @@ func init() {
 		},
 		// 0x66: LD H, (HL)
 		func(cpu *CPU) int {
-			cpu.H = cpu.Memory.Read(cpu.HL())
+			cpu.H = cpu.read(cpu.HL())
 			return 8
 		},
 		// 0x67: LD H, A
@@ -849,7 +854,7 @@ func init() {
 		},
 		// 0x6E: LD L, (HL)
 		func(cpu *CPU) int {
-			cpu.L = cpu.Memory.Read(cpu.HL())
+			cpu.L = cpu.read(cpu.HL())
 			return 8
 		},
 		// 0x6F: LD L, A
@@ -859,42 +864,48 @@ func init() {
 		},
 		// 0x70: LD (HL), B
 		func(cpu *CPU) int {
-			cpu.Memory.Write(cpu.HL(), cpu.B)
+			cpu.write(cpu.HL(), cpu.B)
 			return 8
 		},
 		// 0x71: LD (HL), C
 		func(cpu *CPU) int {
-			cpu.Memory.Write(cpu.HL(), cpu.C)
+			cpu.write(cpu.HL(), cpu.C)
 			return 8
 		},
 		// 0x72: LD (HL), D
 		func(cpu *CPU) int {
-			cpu.Memory.Write(cpu.HL(), cpu.D)
+			cpu.write(cpu.HL(), cpu.D)
 			return 8
 		},
 		// 0x73: LD (HL), E
 		func(cpu *CPU) int {
-			cpu.Memory.Write(cpu.HL(), cpu.E)
+			cpu.write(cpu.HL(), cpu.E)
 			return 8
 		},
 		// 0x74: LD (HL), H
 		func(cpu *CPU) int {
-			cpu.Memory.Write(cpu.HL(), cpu.H)
+			cpu.write(cpu.HL(), cpu.H)
 			return 8
 		},
 		// 0x75: LD (HL), L
 		func(cpu *CPU) int {
-			cpu.Memory.Write(cpu.HL(), cpu.L)
+			cpu.write(cpu.HL(), cpu.L)
 			return 8
 		},
 		// 0x76: HALT
 		func(cpu *CPU) int {
-			cpu.Halt = true
+			// With IME off and an interrupt already pending HALT exits
+			// immediately and fails to increment PC
+			if !cpu.IME && cpu.pendingInterrupts() != 0 {
+				cpu.haltBug = true
+			} else {
+				cpu.Halt = true
+			}
 			return 4
 		},
 		// 0x77: LD (HL), A
 		func(cpu *CPU) int {
-			cpu.Memory.Write(cpu.HL(), cpu.A)
+			cpu.write(cpu.HL(), cpu.A)
 			return 8
 		},
 		// 0x78: LD A, B
@@ -929,7 +940,7 @@ func init() {
 		},
 		// 0x7E: LD A, (HL)
 		func(cpu *CPU) int {
-			cpu.A = cpu.Memory.Read(cpu.HL())
+			cpu.A = cpu.read(cpu.HL())
 			return 8
 		},
 		// 0x7F: LD A, A
@@ -1005,7 +1016,7 @@ func init() {
 		},
 		// 0x86: ADD A, (HL)
 		func(cpu *CPU) int {
-			temp := uint16(cpu.A) + uint16(cpu.Memory.Read(cpu.HL()))
+			temp := uint16(cpu.A) + uint16(cpu.read(cpu.HL()))
 			uint8Val := uint8(temp)
 			cpu.FZero = uint8Val == 0
 			cpu.FSub = false
@@ -1057,7 +1068,7 @@ func init() {
 		},
 		// 0x8E: ADC A, (HL)
 		func(cpu *CPU) int {
-			opADC(cpu, cpu.Memory.Read(cpu.HL()))
+			opADC(cpu, cpu.read(cpu.HL()))
 			return 8
 		},
 		// 0x8F: ADC A, A
@@ -1127,7 +1138,7 @@ func init() {
 		},
 		// 0x96: SUB A, (HL)
 		func(cpu *CPU) int {
-			temp := int(cpu.A) - int(cpu.Memory.Read(cpu.HL()))
+			temp := int(cpu.A) - int(cpu.read(cpu.HL()))
 			cpu.FZero = temp == 0
 			cpu.FSub = true
 			cpu.FHalfCarry = cpu.A&0xf < uint8(temp)&0xf
@@ -1176,7 +1187,7 @@ func init() {
 		},
 		// 0x9E: SBC A, (HL)
 		func(cpu *CPU) int {
-			opSBC(cpu, cpu.Memory.Read(cpu.HL()))
+			opSBC(cpu, cpu.read(cpu.HL()))
 			return 8
 		},
 		// 0x9D: SBC A, A
@@ -1240,7 +1251,7 @@ func init() {
 		},
 		// 0xA6: AND (HL)
 		func(cpu *CPU) int {
-			cpu.A = cpu.A & cpu.Memory.Read(cpu.HL())
+			cpu.A = cpu.A & cpu.read(cpu.HL())
 			cpu.FZero = cpu.A == 0
 			cpu.FSub = false
 			cpu.FHalfCarry = true
@@ -1312,7 +1323,7 @@ func init() {
 		},
 		// 0xAE: XOR (HL)
 		func(cpu *CPU) int {
-			cpu.A = cpu.A ^ cpu.Memory.Read(cpu.HL())
+			cpu.A = cpu.A ^ cpu.read(cpu.HL())
 			cpu.FZero = cpu.A == 0
 			cpu.FSub = false
 			cpu.FHalfCarry = false
@@ -1384,7 +1395,7 @@ func init() {
 		},
 		// 0xB6: OR (HL)
 		func(cpu *CPU) int {
-			cpu.A = cpu.A | cpu.Memory.Read(cpu.HL())
+			cpu.A = cpu.A | cpu.read(cpu.HL())
 			cpu.FZero = cpu.A == 0
 			cpu.FSub = false
 			cpu.FHalfCarry = false
@@ -1456,7 +1467,7 @@ func init() {
 		},
 		// 0xBE: CP (HL)
 		func(cpu *CPU) int {
-			temp := int(cpu.A) - int(cpu.Memory.Read(cpu.HL()))
+			temp := int(cpu.A) - int(cpu.read(cpu.HL()))
 			cpu.FZero = temp == 0
 			cpu.FSub = true
 			cpu.FHalfCarry = temp&0xf > int(cpu.A&0xf)
@@ -1474,17 +1485,19 @@ func init() {
 		},
 		// 0xC0: RET NZ
 		func(cpu *CPU) int {
+			// Condition is checked during an internal cycle
+			cpu.tick(4)
 			if cpu.FZero {
 				return 8
 			}
-			cpu.PC = uint16(cpu.Memory.Read(cpu.SP+1))<<8 | uint16(cpu.Memory.Read(cpu.SP))
+			cpu.PC = cpu.read16(cpu.SP)
 			cpu.SP += 2
 			return 20
 		},
 		// 0xC1: POP BC
 		func(cpu *CPU) int {
-			cpu.C = cpu.Memory.Read(cpu.SP)
-			cpu.B = cpu.Memory.Read(cpu.SP + 1)
+			cpu.C = cpu.read(cpu.SP)
+			cpu.B = cpu.read(cpu.SP + 1)
 			cpu.SP += 2
 			return 12
 		},
@@ -1494,12 +1507,12 @@ func init() {
 				cpu.PC += 2
 				return 12
 			}
-			cpu.PC = uint16(cpu.Memory.Read(cpu.PC+1))<<8 | uint16(cpu.Memory.Read(cpu.PC))
+			cpu.PC = cpu.read16(cpu.PC)
 			return 16
 		},
 		// 0xC3: JP a16
 		func(cpu *CPU) int {
-			cpu.PC = uint16(cpu.Memory.Read(cpu.PC+1))<<8 | uint16(cpu.Memory.Read(cpu.PC))
+			cpu.PC = cpu.read16(cpu.PC)
 			return 16
 		},
 		// 0xC4: CALL NZ, a16
@@ -1509,22 +1522,24 @@ func init() {
 				return 12
 			}
 			tempPC := cpu.PC + 2
-			cpu.Memory.Write(cpu.SP-1, uint8(tempPC>>8))
-			cpu.Memory.Write(cpu.SP-2, uint8(tempPC))
+			cpu.PC = cpu.read16(cpu.PC)
+			cpu.tick(4)
+			cpu.write(cpu.SP-1, uint8(tempPC>>8))
+			cpu.write(cpu.SP-2, uint8(tempPC))
 			cpu.SP -= 2
-			cpu.PC = uint16(cpu.Memory.Read(cpu.PC+1))<<8 | uint16(cpu.Memory.Read(cpu.PC))
 			return 24
 		},
 		// 0xC5: PUSH BC
 		func(cpu *CPU) int {
-			cpu.Memory.Write(cpu.SP-1, cpu.B)
-			cpu.Memory.Write(cpu.SP-2, cpu.C)
+			cpu.tick(4)
+			cpu.write(cpu.SP-1, cpu.B)
+			cpu.write(cpu.SP-2, cpu.C)
 			cpu.SP -= 2
 			return 16
 		},
 		// 0xC6: ADD A, d8
 		func(cpu *CPU) int {
-			temp := uint16(cpu.A) + uint16(cpu.Memory.Read(cpu.PC))
+			temp := uint16(cpu.A) + uint16(cpu.read(cpu.PC))
 			uint8Val := uint8(temp)
 			cpu.FZero = uint8Val == 0
 			cpu.FSub = false
@@ -1536,24 +1551,27 @@ func init() {
 		},
 		// 0xC7: RST 00H
 		func(cpu *CPU) int {
-			cpu.Memory.Write(cpu.SP-1, uint8(cpu.PC>>8))
-			cpu.Memory.Write(cpu.SP-2, uint8(cpu.PC))
+			cpu.tick(4)
+			cpu.write(cpu.SP-1, uint8(cpu.PC>>8))
+			cpu.write(cpu.SP-2, uint8(cpu.PC))
 			cpu.SP -= 2
 			cpu.PC = 0
 			return 16
 		},
 		// 0xC8: RET Z
 		func(cpu *CPU) int {
+			// Condition is checked during an internal cycle
+			cpu.tick(4)
 			if !cpu.FZero {
 				return 8
 			}
-			cpu.PC = uint16(cpu.Memory.Read(cpu.SP+1))<<8 | uint16(cpu.Memory.Read(cpu.SP))
+			cpu.PC = cpu.read16(cpu.SP)
 			cpu.SP += 2
 			return 20
 		},
 		// 0xC9: RET
 		func(cpu *CPU) int {
-			cpu.PC = uint16(cpu.Memory.Read(cpu.SP+1))<<8 | uint16(cpu.Memory.Read(cpu.SP))
+			cpu.PC = cpu.read16(cpu.SP)
 			cpu.SP += 2
 			return 16
 		},
@@ -1563,12 +1581,12 @@ func init() {
 				cpu.PC += 2
 				return 12
 			}
-			cpu.PC = uint16(cpu.Memory.Read(cpu.PC+1))<<8 | uint16(cpu.Memory.Read(cpu.PC))
+			cpu.PC = cpu.read16(cpu.PC)
 			return 16
 		},
 		// 0xCB: CB Prefix
 		func(cpu *CPU) int {
-			cycles := CBInstructionsTable[cpu.Memory.Read(cpu.PC)](cpu)
+			cycles := CBInstructionsTable[cpu.read(cpu.PC)](cpu)
 			cpu.PC++
 			return cycles
 		},
@@ -1579,48 +1597,53 @@ func init() {
 				return 12
 			}
 			tempPC := cpu.PC + 2
-			cpu.Memory.Write(cpu.SP-1, uint8(tempPC>>8))
-			cpu.Memory.Write(cpu.SP-2, uint8(tempPC))
+			cpu.PC = cpu.read16(cpu.PC)
+			cpu.tick(4)
+			cpu.write(cpu.SP-1, uint8(tempPC>>8))
+			cpu.write(cpu.SP-2, uint8(tempPC))
 			cpu.SP -= 2
-			cpu.PC = uint16(cpu.Memory.Read(cpu.PC+1))<<8 | uint16(cpu.Memory.Read(cpu.PC))
 			return 24
 		},
 		// 0xCD: CALL a16
 		func(cpu *CPU) int {
 			tempPC := cpu.PC + 2
-			cpu.Memory.Write(cpu.SP-1, uint8(tempPC>>8))
-			cpu.Memory.Write(cpu.SP-2, uint8(tempPC))
+			cpu.PC = cpu.read16(cpu.PC)
+			cpu.tick(4)
+			cpu.write(cpu.SP-1, uint8(tempPC>>8))
+			cpu.write(cpu.SP-2, uint8(tempPC))
 			cpu.SP -= 2
-			cpu.PC = uint16(cpu.Memory.Read(cpu.PC+1))<<8 | uint16(cpu.Memory.Read(cpu.PC))
 			return 24
 		},
 		// 0xCE: ADC A, d8
 		func(cpu *CPU) int {
-			opADC(cpu, cpu.Memory.Read(cpu.PC))
+			opADC(cpu, cpu.read(cpu.PC))
 			cpu.PC++
 			return 8
 		},
 		// 0xCF: RST 08H
 		func(cpu *CPU) int {
-			cpu.Memory.Write(cpu.SP-1, uint8(cpu.PC>>8))
-			cpu.Memory.Write(cpu.SP-2, uint8(cpu.PC))
+			cpu.tick(4)
+			cpu.write(cpu.SP-1, uint8(cpu.PC>>8))
+			cpu.write(cpu.SP-2, uint8(cpu.PC))
 			cpu.SP -= 2
 			cpu.PC = 0x8
 			return 16
 		},
 		// 0xD0: RET NC
 		func(cpu *CPU) int {
+			// Condition is checked during an internal cycle
+			cpu.tick(4)
 			if cpu.FCarry {
 				return 8
 			}
-			cpu.PC = uint16(cpu.Memory.Read(cpu.SP+1))<<8 | uint16(cpu.Memory.Read(cpu.SP))
+			cpu.PC = cpu.read16(cpu.SP)
 			cpu.SP += 2
 			return 20
 		},
 		// 0xD1: POP DE
 		func(cpu *CPU) int {
-			cpu.E = cpu.Memory.Read(cpu.SP)
-			cpu.D = cpu.Memory.Read(cpu.SP + 1)
+			cpu.E = cpu.read(cpu.SP)
+			cpu.D = cpu.read(cpu.SP + 1)
 			cpu.SP += 2
 			return 12
 		},
@@ -1630,7 +1653,7 @@ func init() {
 				cpu.PC += 2
 				return 12
 			}
-			cpu.PC = uint16(cpu.Memory.Read(cpu.PC+1))<<8 | uint16(cpu.Memory.Read(cpu.PC))
+			cpu.PC = cpu.read16(cpu.PC)
 			return 16
 		},
 		// 0xD3: NOP
@@ -1644,22 +1667,24 @@ func init() {
 				return 12
 			}
 			tempPC := cpu.PC + 2
-			cpu.Memory.Write(cpu.SP-1, uint8(tempPC>>8))
-			cpu.Memory.Write(cpu.SP-2, uint8(tempPC))
+			cpu.PC = cpu.read16(cpu.PC)
+			cpu.tick(4)
+			cpu.write(cpu.SP-1, uint8(tempPC>>8))
+			cpu.write(cpu.SP-2, uint8(tempPC))
 			cpu.SP -= 2
-			cpu.PC = uint16(cpu.Memory.Read(cpu.PC+1))<<8 | uint16(cpu.Memory.Read(cpu.PC))
 			return 24
 		},
 		// 0xD5: PUSH DE
 		func(cpu *CPU) int {
-			cpu.Memory.Write(cpu.SP-1, cpu.D)
-			cpu.Memory.Write(cpu.SP-2, cpu.E)
+			cpu.tick(4)
+			cpu.write(cpu.SP-1, cpu.D)
+			cpu.write(cpu.SP-2, cpu.E)
 			cpu.SP -= 2
 			return 16
 		},
 		// 0xD6: SUB A, d8
 		func(cpu *CPU) int {
-			temp := int(cpu.A) - int(cpu.Memory.Read(cpu.PC))
+			temp := int(cpu.A) - int(cpu.read(cpu.PC))
 			cpu.FZero = temp == 0
 			cpu.FSub = true
 			cpu.FHalfCarry = cpu.A&0xf < uint8(temp)&0xf
@@ -1670,25 +1695,28 @@ func init() {
 		},
 		// 0xD7: RST 10H
 		func(cpu *CPU) int {
-			cpu.Memory.Write(cpu.SP-1, uint8(cpu.PC>>8))
-			cpu.Memory.Write(cpu.SP-2, uint8(cpu.PC))
+			cpu.tick(4)
+			cpu.write(cpu.SP-1, uint8(cpu.PC>>8))
+			cpu.write(cpu.SP-2, uint8(cpu.PC))
 			cpu.SP -= 2
 			cpu.PC = 0x10
 			return 16
 		},
 		// 0xD8: RET C
 		func(cpu *CPU) int {
+			// Condition is checked during an internal cycle
+			cpu.tick(4)
 			if !cpu.FCarry {
 				return 8
 			}
-			cpu.PC = uint16(cpu.Memory.Read(cpu.SP+1))<<8 | uint16(cpu.Memory.Read(cpu.SP))
+			cpu.PC = cpu.read16(cpu.SP)
 			cpu.SP += 2
 			return 20
 		},
 		// 0xD9: RETI
 		func(cpu *CPU) int {
-			cpu.EI = true
-			cpu.PC = uint16(cpu.Memory.Read(cpu.SP+1))<<8 | uint16(cpu.Memory.Read(cpu.SP))
+			cpu.IME = true
+			cpu.PC = cpu.read16(cpu.SP)
 			cpu.SP += 2
 			return 16
 		},
@@ -1698,7 +1726,7 @@ func init() {
 				cpu.PC += 2
 				return 12
 			}
-			cpu.PC = uint16(cpu.Memory.Read(cpu.PC+1))<<8 | uint16(cpu.Memory.Read(cpu.PC))
+			cpu.PC = cpu.read16(cpu.PC)
 			return 16
 		},
 		// 0xDB: NOP
@@ -1712,10 +1740,11 @@ func init() {
 				return 12
 			}
 			tempPC := cpu.PC + 2
-			cpu.Memory.Write(cpu.SP-1, uint8(tempPC>>8))
-			cpu.Memory.Write(cpu.SP-2, uint8(tempPC))
+			cpu.PC = cpu.read16(cpu.PC)
+			cpu.tick(4)
+			cpu.write(cpu.SP-1, uint8(tempPC>>8))
+			cpu.write(cpu.SP-2, uint8(tempPC))
 			cpu.SP -= 2
-			cpu.PC = uint16(cpu.Memory.Read(cpu.PC+1))<<8 | uint16(cpu.Memory.Read(cpu.PC))
 			return 24
 		},
 		// 0xDD: NOP
@@ -1724,34 +1753,35 @@ func init() {
 		},
 		// 0xDE: SBC A, d8
 		func(cpu *CPU) int {
-			opSBC(cpu, cpu.Memory.Read(cpu.PC))
+			opSBC(cpu, cpu.read(cpu.PC))
 			cpu.PC++
 			return 8
 		},
 		// 0xDF: RST 18H
 		func(cpu *CPU) int {
-			cpu.Memory.Write(cpu.SP-1, uint8(cpu.PC>>8))
-			cpu.Memory.Write(cpu.SP-2, uint8(cpu.PC))
+			cpu.tick(4)
+			cpu.write(cpu.SP-1, uint8(cpu.PC>>8))
+			cpu.write(cpu.SP-2, uint8(cpu.PC))
 			cpu.SP -= 2
 			cpu.PC = 0x18
 			return 16
 		},
 		// 0xE0: LDH (a8), A
 		func(cpu *CPU) int {
-			cpu.Memory.Write(0xff00+uint16(cpu.Memory.Read(cpu.PC)), cpu.A)
+			cpu.write(0xff00+uint16(cpu.read(cpu.PC)), cpu.A)
 			cpu.PC++
 			return 12
 		},
 		// 0xE1: POP HL
 		func(cpu *CPU) int {
-			cpu.L = cpu.Memory.Read(cpu.SP)
-			cpu.H = cpu.Memory.Read(cpu.SP + 1)
+			cpu.L = cpu.read(cpu.SP)
+			cpu.H = cpu.read(cpu.SP + 1)
 			cpu.SP += 2
 			return 12
 		},
 		// 0xE2: LD (C), A
 		func(cpu *CPU) int {
-			cpu.Memory.Write(0xff00+uint16(cpu.C), cpu.A)
+			cpu.write(0xff00+uint16(cpu.C), cpu.A)
 			return 8
 		},
 		// 0xE3: NOP
@@ -1764,14 +1794,15 @@ func init() {
 		},
 		// 0xE5: PUSH HL
 		func(cpu *CPU) int {
-			cpu.Memory.Write(cpu.SP-1, cpu.H)
-			cpu.Memory.Write(cpu.SP-2, cpu.L)
+			cpu.tick(4)
+			cpu.write(cpu.SP-1, cpu.H)
+			cpu.write(cpu.SP-2, cpu.L)
 			cpu.SP -= 2
 			return 16
 		},
 		// 0xE6: AND d8
 		func(cpu *CPU) int {
-			cpu.A = cpu.A & cpu.Memory.Read(cpu.PC)
+			cpu.A = cpu.A & cpu.read(cpu.PC)
 			cpu.FZero = cpu.A == 0
 			cpu.FSub = false
 			cpu.FHalfCarry = true
@@ -1781,15 +1812,16 @@ func init() {
 		},
 		// 0xE7: RST 20H
 		func(cpu *CPU) int {
-			cpu.Memory.Write(cpu.SP-1, uint8(cpu.PC>>8))
-			cpu.Memory.Write(cpu.SP-2, uint8(cpu.PC))
+			cpu.tick(4)
+			cpu.write(cpu.SP-1, uint8(cpu.PC>>8))
+			cpu.write(cpu.SP-2, uint8(cpu.PC))
 			cpu.SP -= 2
 			cpu.PC = 0x20
 			return 16
 		},
 		// 0xE8: ADD SP, r8
 		func(cpu *CPU) int {
-			val := int(int8(cpu.Memory.Read(cpu.PC)))
+			val := int(int8(cpu.read(cpu.PC)))
 			temp := val + int(cpu.SP)
 			cpu.FZero = false
 			cpu.FSub = false
@@ -1806,10 +1838,7 @@ func init() {
 		},
 		// 0xEA: LD (a16), A
 		func(cpu *CPU) int {
-			cpu.Memory.Write(
-				uint16(cpu.Memory.Read(cpu.PC+1))<<8|uint16(cpu.Memory.Read(cpu.PC)),
-				cpu.A,
-			)
+			cpu.write(cpu.read16(cpu.PC), cpu.A)
 			cpu.PC += 2
 			return 16
 		},
@@ -1827,7 +1856,7 @@ func init() {
 		},
 		// 0xA8: XOR d8
 		func(cpu *CPU) int {
-			cpu.A = cpu.A ^ cpu.Memory.Read(cpu.PC)
+			cpu.A = cpu.A ^ cpu.read(cpu.PC)
 			cpu.FZero = cpu.A == 0
 			cpu.FSub = false
 			cpu.FHalfCarry = false
@@ -1837,33 +1866,35 @@ func init() {
 		},
 		// 0xDF: RST 28H
 		func(cpu *CPU) int {
-			cpu.Memory.Write(cpu.SP-1, uint8(cpu.PC>>8))
-			cpu.Memory.Write(cpu.SP-2, uint8(cpu.PC))
+			cpu.tick(4)
+			cpu.write(cpu.SP-1, uint8(cpu.PC>>8))
+			cpu.write(cpu.SP-2, uint8(cpu.PC))
 			cpu.SP -= 2
 			cpu.PC = 0x28
 			return 16
 		},
 		// 0xF0: LDH A, (a8)
 		func(cpu *CPU) int {
-			cpu.A = cpu.Memory.Read(0xff00 + uint16(cpu.Memory.Read(cpu.PC)))
+			cpu.A = cpu.read(0xff00 + uint16(cpu.read(cpu.PC)))
 			cpu.PC++
 			return 12
 		},
 		// 0xF1: POP AF
 		func(cpu *CPU) int {
-			cpu.SetF(cpu.Memory.Read(cpu.SP))
-			cpu.A = cpu.Memory.Read(cpu.SP + 1)
+			cpu.SetF(cpu.read(cpu.SP))
+			cpu.A = cpu.read(cpu.SP + 1)
 			cpu.SP += 2
 			return 12
 		},
 		// 0xF2: LD A, (C)
 		func(cpu *CPU) int {
-			cpu.A = cpu.Memory.Read(0xff00 + uint16(cpu.C))
+			cpu.A = cpu.read(0xff00 + uint16(cpu.C))
 			return 8
 		},
 		// 0xF3: DI
 		func(cpu *CPU) int {
-			cpu.EI = false
+			cpu.IME = false
+			cpu.enableIME = false
 			return 4
 		},
 		// 0xF4: NOP
@@ -1872,14 +1903,15 @@ func init() {
 		},
 		// 0xF5: PUSH AF
 		func(cpu *CPU) int {
-			cpu.Memory.Write(cpu.SP-1, cpu.A)
-			cpu.Memory.Write(cpu.SP-2, cpu.F())
+			cpu.tick(4)
+			cpu.write(cpu.SP-1, cpu.A)
+			cpu.write(cpu.SP-2, cpu.F())
 			cpu.SP -= 2
 			return 16
 		},
 		// 0xF6: OR d8
 		func(cpu *CPU) int {
-			cpu.A = cpu.A | cpu.Memory.Read(cpu.PC)
+			cpu.A = cpu.A | cpu.read(cpu.PC)
 			cpu.FZero = cpu.A == 0
 			cpu.FSub = false
 			cpu.FHalfCarry = false
@@ -1889,15 +1921,16 @@ func init() {
 		},
 		// 0xF7: RST 30H
 		func(cpu *CPU) int {
-			cpu.Memory.Write(cpu.SP-1, uint8(cpu.PC>>8))
-			cpu.Memory.Write(cpu.SP-2, uint8(cpu.PC))
+			cpu.tick(4)
+			cpu.write(cpu.SP-1, uint8(cpu.PC>>8))
+			cpu.write(cpu.SP-2, uint8(cpu.PC))
 			cpu.SP -= 2
 			cpu.PC = 0x30
 			return 16
 		},
 		// 0xF8: LD HL, SP+r8
 		func(cpu *CPU) int {
-			r8 := int(int8(cpu.Memory.Read(cpu.PC)))
+			r8 := int(int8(cpu.read(cpu.PC)))
 			result := int(cpu.SP) + r8
 			cpu.H = uint8(result >> 8)
 			cpu.L = uint8(result)
@@ -1915,13 +1948,13 @@ func init() {
 		},
 		// 0xFA: LD A, (a16)
 		func(cpu *CPU) int {
-			cpu.A = cpu.Memory.Read(uint16(cpu.Memory.Read(cpu.PC+1))<<8 | uint16(cpu.Memory.Read(cpu.PC)))
+			cpu.A = cpu.read(cpu.read16(cpu.PC))
 			cpu.PC += 2
 			return 16
 		},
 		// 0xFB: EI
 		func(cpu *CPU) int {
-			cpu.EI = true
+			cpu.enableIME = true
 			return 4
 		},
 		// 0xFC: NOP
@@ -1934,7 +1967,7 @@ func init() {
 		},
 		// 0xFE: CP d8
 		func(cpu *CPU) int {
-			temp := int(cpu.A) - int(cpu.Memory.Read(cpu.PC))
+			temp := int(cpu.A) - int(cpu.read(cpu.PC))
 			cpu.FZero = temp == 0
 			cpu.FSub = true
 			cpu.FHalfCarry = temp&0xf > int(cpu.A&0xf)
@@ -1944,8 +1977,9 @@ func init() {
 		},
 		// 0xFF: RST 38H
 		func(cpu *CPU) int {
-			cpu.Memory.Write(cpu.SP-1, uint8(cpu.PC>>8))
-			cpu.Memory.Write(cpu.SP-2, uint8(cpu.PC))
+			cpu.tick(4)
+			cpu.write(cpu.SP-1, uint8(cpu.PC>>8))
+			cpu.write(cpu.SP-2, uint8(cpu.PC))
 			cpu.SP -= 2
 			cpu.PC = 0x38
 			return 16
@@ -1,9 +1,5 @@
 package goboy
 
-import (
-	"fmt"
-)
-
 type Keystate struct {
 	Up     bool
 	Down   bool
@@ -30,7 +26,6 @@ type Joypad struct {
 // Bit 0 - P10 Input Right or Button A (0=Pressed) (Read Only)
 
 func (j *Joypad) Update(state Keystate) {
-	ifReg := j.mmu.registers[AddrIF]
 	var requestInt bool
 	// if j.buttonKeys {
 	requestInt = requestInt || (!j.state.Select && state.Select)
@@ -46,14 +41,16 @@ func (j *Joypad) Update(state Keystate) {
 	// }
 	j.state = state
 	if requestInt {
-		fmt.Println("Request int")
-		ifReg.RawSet(setBit(ifReg.Get(), JoypadInt))
+		j.mmu.requestInterrupt(JoypadInt)
 	}
 }
 
 func (j *Joypad) Set(data uint8) {
 	j.buttonKeys = data&(1<<5) == 0
 	j.directionKeys = data&(1<<4) == 0
+	if sgb := j.mmu.SGB; sgb != nil {
+		sgb.writeJoypad(data)
+	}
 }
 
 func (j *Joypad) Get() uint8 {
@@ -67,7 +64,18 @@ func (j *Joypad) Get() uint8 {
 	if j.directionKeys {
 		directionKey = 0
 	}
-	var data uint8 = (buttonKey << 5) | (directionKey << 4) | 0xF
+	// Unused upper bits read as 1
+	var data uint8 = 0xC0 | (buttonKey << 5) | (directionKey << 4) | 0xF
+	// Super Game Boy returns the current controller when neither group is
+	// selected, other controllers than the first have no keys pressed
+	if sgb := j.mmu.SGB; sgb != nil {
+		if !j.buttonKeys && !j.directionKeys {
+			return data&0xF0 | sgb.joypadID()
+		}
+		if sgb.player != 0 {
+			return data
+		}
+	}
 	if j.buttonKeys {
 		if j.state.Start {
 			mask := ^uint8(1 << 3)
@@ -0,0 +1,142 @@
+package goboy
+
+import (
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// Kinds of messages sent between two TCP link cable ends
+const (
+	linkClocked = iota
+	linkReply
+)
+
+// linkTimeout is how long Transfer waits for the other end to reply
+const linkTimeout = 500 * time.Millisecond
+
+// TCPLinkCable connects two emulators over TCP. Each byte is sent as a
+// message telling whether it was clocked by the sender or is a reply to a
+// clocked byte.
+type TCPLinkCable struct {
+	conn    net.Conn
+	clocked chan uint8
+	replies chan uint8
+	done    chan struct{}
+	close   sync.Once
+}
+
+// ListenLinkCable waits for another emulator to connect to addr
+func ListenLinkCable(addr string) (*TCPLinkCable, error) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	defer l.Close()
+	conn, err := l.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return NewTCPLinkCable(conn), nil
+}
+
+// DialLinkCable connects to an emulator listening on addr
+func DialLinkCable(addr string) (*TCPLinkCable, error) {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return NewTCPLinkCable(conn), nil
+}
+
+// NewTCPLinkCable creates a link cable using an established connection
+func NewTCPLinkCable(conn net.Conn) *TCPLinkCable {
+	if tcp, ok := conn.(*net.TCPConn); ok {
+		tcp.SetNoDelay(true)
+	}
+	l := &TCPLinkCable{
+		conn:    conn,
+		clocked: make(chan uint8, 16),
+		replies: make(chan uint8, 16),
+		done:    make(chan struct{}),
+	}
+	go l.readLoop()
+	return l
+}
+
+func (l *TCPLinkCable) readLoop() {
+	defer l.Close()
+	var msg [2]uint8
+	for {
+		if _, err := io.ReadFull(l.conn, msg[:]); err != nil {
+			return
+		}
+		ch := l.replies
+		if msg[0] == linkClocked {
+			ch = l.clocked
+		}
+		select {
+		case ch <- msg[1]:
+		case <-l.done:
+			return
+		}
+	}
+}
+
+func (l *TCPLinkCable) send(kind, data uint8) {
+	if _, err := l.conn.Write([]uint8{kind, data}); err != nil {
+		l.Close()
+	}
+}
+
+// Transfer sends data to the other end and waits for its reply. Bytes the
+// other end clocks at the same time get 0xFF back, as neither end is
+// listening. Returns 0xFF if the other end doesn't reply in time.
+func (l *TCPLinkCable) Transfer(data uint8) uint8 {
+	select {
+	case <-l.done:
+		return 0xFF
+	default:
+	}
+	// Drop replies to transfers that timed out
+	for len(l.replies) > 0 {
+		<-l.replies
+	}
+	l.send(linkClocked, data)
+	timeout := time.NewTimer(linkTimeout)
+	defer timeout.Stop()
+	for {
+		select {
+		case reply := <-l.replies:
+			return reply
+		case <-l.clocked:
+			l.send(linkReply, 0xFF)
+		case <-l.done:
+			return 0xFF
+		case <-timeout.C:
+			return 0xFF
+		}
+	}
+}
+
+// Receive returns a byte clocked by the other end and sends reply back
+func (l *TCPLinkCable) Receive(reply uint8) (uint8, bool) {
+	select {
+	case data := <-l.clocked:
+		l.send(linkReply, reply)
+		return data, true
+	default:
+		return 0, false
+	}
+}
+
+// Close disconnects the cable
+func (l *TCPLinkCable) Close() error {
+	var err error
+	l.close.Do(func() {
+		close(l.done)
+		err = l.conn.Close()
+	})
+	return err
+}
@@ -1,18 +1,53 @@
 package goboy
 
+// BatteryBacked is implemented by mappers with RAM that can be kept alive by a battery
+type BatteryBacked interface {
+	// RAM returns all of the external RAM of the mapper
+	RAM() []byte
+}
+
+// resetter is implemented by mappers which have registers to reset on power up
+type resetter interface {
+	reset()
+}
+
+// romOffset returns the offset of addr in ROM when bank is mapped into the
+// switchable ROM area. Banks past the end of ROM wrap around.
+func romOffset(rom []byte, bank uint, addr uint16) uint {
+	return (bank*ROMBankSize + uint(addr) - ROMBankStart) % uint(len(rom))
+}
+
+// ramOffset returns the offset of addr in external RAM when bank is mapped
+// into the external RAM area. Banks past the end of RAM wrap around.
+func ramOffset(ram []byte, bank uint, addr uint16) uint {
+	return (bank*RAMBankSize + uint(addr) - ExtRAMStart) % uint(len(ram))
+}
+
+// MBC0 is a cartridge without a mapper, with optional RAM
 type MBC0 struct {
-	rom [ROMBankSize]byte
+	// rom contains the whole ROM image, including bank 0
+	rom []byte
+	ram []byte
 }
 
 func (mbc *MBC0) Read(addr uint16) uint8 {
 	if addr <= ROMBankEnd {
-		return mbc.rom[uint(addr)-ROMBankStart]
+		return mbc.rom[romOffset(mbc.rom, 1, addr)]
+	} else if len(mbc.ram) > 0 && ExtRAMStart <= addr && addr <= ExtRAMEnd {
+		return mbc.ram[ramOffset(mbc.ram, 0, addr)]
 	}
-	return 0
+	return 0xFF
 }
 
 func (mbc *MBC0) Write(addr uint16, data uint8) {
 	// No writing on my lawn!
+	if len(mbc.ram) > 0 && ExtRAMStart <= addr && addr <= ExtRAMEnd {
+		mbc.ram[ramOffset(mbc.ram, 0, addr)] = data
+	}
+}
+
+func (mbc *MBC0) RAM() []byte {
+	return mbc.ram
 }
 
 type MBC1 struct {
@@ -21,15 +56,16 @@ type MBC1 struct {
 	ramBankNumber uint8
 	romModeSelect uint8
 
-	rom [128 * ROMBankSize]byte
-	ram [4 * RAMBankSize]byte
+	// rom contains the whole ROM image, including bank 0
+	rom []byte
+	ram []byte
 }
 
 func (mbc *MBC1) Read(addr uint16) uint8 {
 	if ROMBankStart <= addr && addr <= ROMBankEnd {
-		return mbc.rom[uint(mbc.SelectedROM())*ROMBankSize+(uint(addr)-ROMBankStart)]
-	} else if mbc.RAMEnabled() && ExtRAMStart <= addr && addr <= ExtRAMEnd {
-		return mbc.ram[uint(mbc.SelectedRAM())*RAMBankSize+(uint(addr)-ExtRAMStart)]
+		return mbc.rom[romOffset(mbc.rom, uint(mbc.SelectedROM()), addr)]
+	} else if mbc.RAMEnabled() && len(mbc.ram) > 0 && ExtRAMStart <= addr && addr <= ExtRAMEnd {
+		return mbc.ram[ramOffset(mbc.ram, uint(mbc.SelectedRAM()), addr)]
 	}
 	return 0xFF
 }
@@ -43,7 +79,7 @@ func (mbc *MBC1) SelectedROM() uint8 {
 	if mbc.RomModeSelected() {
 		romBank |= (mbc.ramBankNumber & 0x3) << 5
 	}
-	return romBank - 1
+	return romBank
 }
 
 func (mbc *MBC1) SelectedRAM() uint8 {
@@ -83,5 +119,264 @@ func (mbc *MBC1) Write(addr uint16, data uint8) {
 
 		return
 	}
+	if mbc.RAMEnabled() && len(mbc.ram) > 0 && ExtRAMStart <= addr && addr <= ExtRAMEnd {
+		mbc.ram[ramOffset(mbc.ram, uint(mbc.SelectedRAM()), addr)] = data
+	}
+}
+
+func (mbc *MBC1) reset() {
+	mbc.ramEnabled = 0
+	mbc.romBankNumber = 1
+	mbc.ramBankNumber = 0
+	mbc.romModeSelect = 0
+}
+
+func (mbc *MBC1) RAM() []byte {
+	return mbc.ram
+}
+
+// MBC3 supports up to 2MB of ROM, 32KB of RAM and an optional real-time clock
+type MBC3 struct {
+	ramEnabled    uint8
+	romBankNumber uint8
+	ramBankNumber uint8
+	latch         uint8
+	hasTimer      bool
+
+	// rom contains the whole ROM image, including bank 0
+	rom []byte
+	ram []byte
+
+	RTC RTC
+}
+
+func (mbc *MBC3) Read(addr uint16) uint8 {
+	if ROMBankStart <= addr && addr <= ROMBankEnd {
+		return mbc.rom[romOffset(mbc.rom, uint(mbc.SelectedROM()), addr)]
+	}
+	if !mbc.RAMEnabled() || addr < ExtRAMStart || addr > ExtRAMEnd {
+		return 0xFF
+	}
+	if mbc.ramBankNumber <= 0x03 {
+		if len(mbc.ram) == 0 {
+			return 0xFF
+		}
+		return mbc.ram[ramOffset(mbc.ram, uint(mbc.ramBankNumber), addr)]
+	}
+	if mbc.hasTimer && RTCSeconds <= mbc.ramBankNumber && mbc.ramBankNumber <= RTCDaysHigh {
+		return mbc.RTC.Read(mbc.ramBankNumber)
+	}
+	return 0xFF
+}
+
+func (mbc *MBC3) reset() {
+	mbc.ramEnabled = 0
+	mbc.romBankNumber = 1
+	mbc.ramBankNumber = 0
+	mbc.latch = 0
+}
+
+func (mbc *MBC3) RAM() []byte {
+	return mbc.ram
+}
+
+func (mbc *MBC3) RAMEnabled() bool {
+	return mbc.ramEnabled&0x0F == 0x0A
+}
+
+func (mbc *MBC3) SelectedROM() uint8 {
+	return mbc.romBankNumber
+}
+
+func (mbc *MBC3) Write(addr uint16, data uint8) {
+	if addr <= 0x1FFF {
+		mbc.ramEnabled = data
+		return
+	}
+	if addr <= 0x3FFF {
+		mbc.romBankNumber = data & 0x7F
+		if mbc.romBankNumber == 0 {
+			mbc.romBankNumber++
+		}
+		return
+	}
+	if addr <= 0x5FFF {
+		mbc.ramBankNumber = data
+		return
+	}
+	if addr <= 0x7FFF {
+		// Writing 0x00 and then 0x01 latches the current time into the RTC registers
+		if mbc.latch == 0x00 && data == 0x01 {
+			mbc.RTC.Latch()
+		}
+		mbc.latch = data
+		return
+	}
+	if !mbc.RAMEnabled() || addr < ExtRAMStart || addr > ExtRAMEnd {
+		return
+	}
+	if mbc.ramBankNumber <= 0x03 {
+		if len(mbc.ram) > 0 {
+			mbc.ram[ramOffset(mbc.ram, uint(mbc.ramBankNumber), addr)] = data
+		}
+		return
+	}
+	if mbc.hasTimer && RTCSeconds <= mbc.ramBankNumber && mbc.ramBankNumber <= RTCDaysHigh {
+		mbc.RTC.Write(mbc.ramBankNumber, data)
+	}
+}
+
+// MBC5 supports up to 8MB of ROM and 128KB of RAM. Bank 0 can also be
+// mapped into the switchable ROM area.
+type MBC5 struct {
+	ramEnabled    uint8
+	romBankNumber uint16
+	ramBankNumber uint8
+	hasRumble     bool
+	rumble        bool
+
+	// OnRumble is called whenever the rumble motor is turned on or off
+	OnRumble func(on bool)
 
+	// rom contains the whole ROM image, including bank 0
+	rom []byte
+	ram []byte
+}
+
+func (mbc *MBC5) Read(addr uint16) uint8 {
+	if ROMBankStart <= addr && addr <= ROMBankEnd {
+		return mbc.rom[romOffset(mbc.rom, uint(mbc.SelectedROM()), addr)]
+	} else if mbc.RAMEnabled() && len(mbc.ram) > 0 && ExtRAMStart <= addr && addr <= ExtRAMEnd {
+		return mbc.ram[ramOffset(mbc.ram, uint(mbc.SelectedRAM()), addr)]
+	}
+	return 0xFF
+}
+
+func (mbc *MBC5) reset() {
+	mbc.ramEnabled = 0
+	mbc.romBankNumber = 1
+	mbc.ramBankNumber = 0
+	mbc.setRumble(false)
+}
+
+func (mbc *MBC5) RAM() []byte {
+	return mbc.ram
+}
+
+func (mbc *MBC5) RAMEnabled() bool {
+	return mbc.ramEnabled&0x0F == 0x0A
+}
+
+func (mbc *MBC5) SelectedROM() uint16 {
+	return mbc.romBankNumber
+}
+
+func (mbc *MBC5) SelectedRAM() uint8 {
+	// Rumble carts use bit 3 for the motor
+	if mbc.hasRumble {
+		return mbc.ramBankNumber & 0x07
+	}
+	return mbc.ramBankNumber & 0x0F
+}
+
+// Rumble reports whether the rumble motor is currently on
+func (mbc *MBC5) Rumble() bool {
+	return mbc.rumble
+}
+
+func (mbc *MBC5) Write(addr uint16, data uint8) {
+	if addr <= 0x1FFF {
+		mbc.ramEnabled = data
+		return
+	}
+	if addr <= 0x2FFF {
+		mbc.romBankNumber = mbc.romBankNumber&0x100 | uint16(data)
+		return
+	}
+	if addr <= 0x3FFF {
+		mbc.romBankNumber = mbc.romBankNumber&0xFF | uint16(data&0x01)<<8
+		return
+	}
+	if addr <= 0x5FFF {
+		mbc.ramBankNumber = data
+		if mbc.hasRumble {
+			mbc.setRumble(data&Bit3 != 0)
+		}
+		return
+	}
+	if addr <= 0x7FFF {
+		return
+	}
+	if mbc.RAMEnabled() && len(mbc.ram) > 0 && ExtRAMStart <= addr && addr <= ExtRAMEnd {
+		mbc.ram[ramOffset(mbc.ram, uint(mbc.SelectedRAM()), addr)] = data
+	}
+}
+
+func (mbc *MBC5) setRumble(on bool) {
+	if mbc.rumble == on {
+		return
+	}
+	mbc.rumble = on
+	if mbc.OnRumble != nil {
+		mbc.OnRumble(on)
+	}
+}
+
+// MBC2 supports up to 256KB of ROM and has 512x4 bits of built-in RAM
+type MBC2 struct {
+	ramEnabled    uint8
+	romBankNumber uint8
+
+	// rom contains the whole ROM image, including bank 0
+	rom []byte
+	ram [MBC2RAMSize]byte
+}
+
+// MBC2RAMSize is the number of 4-bit values in MBC2's built-in RAM
+const MBC2RAMSize = 512
+
+func (mbc *MBC2) Read(addr uint16) uint8 {
+	if ROMBankStart <= addr && addr <= ROMBankEnd {
+		return mbc.rom[romOffset(mbc.rom, uint(mbc.SelectedROM()), addr)]
+	} else if mbc.RAMEnabled() && ExtRAMStart <= addr && addr <= ExtRAMEnd {
+		// Only the lower 9 address bits are used, so RAM is echoed
+		// through the whole external RAM area
+		return mbc.ram[(addr-ExtRAMStart)%MBC2RAMSize] | 0xF0
+	}
+	return 0xFF
+}
+
+func (mbc *MBC2) reset() {
+	mbc.ramEnabled = 0
+	mbc.romBankNumber = 1
+}
+
+func (mbc *MBC2) RAM() []byte {
+	return mbc.ram[:]
+}
+
+func (mbc *MBC2) RAMEnabled() bool {
+	return mbc.ramEnabled&0x0F == 0x0A
+}
+
+func (mbc *MBC2) SelectedROM() uint8 {
+	return mbc.romBankNumber
+}
+
+func (mbc *MBC2) Write(addr uint16, data uint8) {
+	if addr <= 0x3FFF {
+		// Address bit 8 selects between RAM enable and ROM bank number
+		if addr&0x100 == 0 {
+			mbc.ramEnabled = data
+			return
+		}
+		mbc.romBankNumber = data & 0x0F
+		if mbc.romBankNumber == 0 {
+			mbc.romBankNumber++
+		}
+		return
+	}
+	if mbc.RAMEnabled() && ExtRAMStart <= addr && addr <= ExtRAMEnd {
+		mbc.ram[(addr-ExtRAMStart)%MBC2RAMSize] = data & 0x0F
+	}
 }
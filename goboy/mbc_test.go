@@ -0,0 +1,257 @@
+package goboy
+
+import (
+	"testing"
+)
+
+// romBank returns the number of the bank mapped at 0x4000
+func romBank(cart *Cartridge) int {
+	return int(cart.Read(ROMBankStart)) | int(cart.Read(ROMBankStart+1))<<8
+}
+
+type bankWrite struct {
+	addr uint16
+	data uint8
+}
+
+func TestMBC3ROMBanking(t *testing.T) {
+	tests := []struct {
+		name   string
+		banks  ROMBanks
+		writes []bankWrite
+		want   int
+	}{
+		{"default bank", Banks_128, nil, 1},
+		{"bank 0 maps bank 1", Banks_128, []bankWrite{{0x2000, 0x00}}, 1},
+		{"7-bit bank", Banks_128, []bankWrite{{0x2000, 0x7F}}, 0x7F},
+		{"bit 7 is ignored", Banks_128, []bankWrite{{0x3FFF, 0x85}}, 0x05},
+		{"bank past ROM end wraps around", Banks_16, []bankWrite{{0x2000, 0x13}}, 0x03},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cart := loadTestCartridge(t, testROM(CART_MBC3, tt.banks, ExtRAMNone))
+			for _, w := range tt.writes {
+				cart.Write(w.addr, w.data)
+			}
+			if got := romBank(cart); got != tt.want {
+				t.Errorf("got bank %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMBC3RAMBanking(t *testing.T) {
+	cart := loadTestCartridge(t, testROM(CART_MBC3_RAM_BATTERY, Banks_4, ExtRAM32KB))
+	cart.Write(0xA000, 0x12)
+	if got := cart.Read(0xA000); got != 0xFF {
+		t.Errorf("disabled RAM read %02X, want FF", got)
+	}
+	cart.Write(0x0000, 0x0A)
+	for bank := uint8(0); bank < 4; bank++ {
+		cart.Write(0x4000, bank)
+		cart.Write(0xA000, 0x10+bank)
+	}
+	for bank := uint8(0); bank < 4; bank++ {
+		cart.Write(0x4000, bank)
+		if got := cart.Read(0xA000); got != 0x10+bank {
+			t.Errorf("bank %d read %02X, want %02X", bank, got, 0x10+bank)
+		}
+	}
+	// RTC registers aren't mapped without a timer
+	cart.Write(0x4000, RTCSeconds)
+	if got := cart.Read(0xA000); got != 0xFF {
+		t.Errorf("RTC read %02X without timer, want FF", got)
+	}
+}
+
+func TestMBC3RTCRegisters(t *testing.T) {
+	clock := newTestClock()
+	cart := loadTestCartridge(t, testROM(CART_MBC3_TIMER_RAM_BATTERY, Banks_4, ExtRAM32KB))
+	mbc := cart.MBC.(*MBC3)
+	mbc.RTC.Now = clock.now
+	cart.Write(0x0000, 0x0A)
+	cart.Write(0x4000, RTCMinutes)
+	cart.Write(0xA000, 30)
+	clock.advance(65)
+	if got := cart.Read(0xA000); got != 30 {
+		t.Errorf("minutes %d before latch, want 30", got)
+	}
+	// Latching needs a write of 0x00 followed by 0x01
+	cart.Write(0x6000, 0x00)
+	cart.Write(0x6000, 0x01)
+	if got := cart.Read(0xA000); got != 31 {
+		t.Errorf("minutes %d after latch, want 31", got)
+	}
+	clock.advance(60)
+	cart.Write(0x6000, 0x01)
+	if got := cart.Read(0xA000); got != 31 {
+		t.Errorf("minutes %d after writing 0x01 again, want 31", got)
+	}
+	cart.Write(0x4000, RTCSeconds)
+	if got := cart.Read(0xA000); got != 5 {
+		t.Errorf("seconds %d after latch, want 5", got)
+	}
+}
+
+func TestMBC5ROMBanking(t *testing.T) {
+	tests := []struct {
+		name   string
+		banks  ROMBanks
+		writes []bankWrite
+		want   int
+	}{
+		{"default bank", Banks_512, nil, 1},
+		{"bank 0 is selectable", Banks_512, []bankWrite{{0x2000, 0x00}}, 0},
+		{"low 8 bits", Banks_512, []bankWrite{{0x2FFF, 0xAB}}, 0xAB},
+		{"9th bit", Banks_512, []bankWrite{{0x2000, 0x23}, {0x3000, 0x01}}, 0x123},
+		{"only bit 0 of high register is used", Banks_512, []bankWrite{{0x2000, 0x04}, {0x3000, 0xFE}}, 0x004},
+		{"low write keeps 9th bit", Banks_512, []bankWrite{{0x3000, 0x01}, {0x2000, 0xFF}}, 0x1FF},
+		{"bank past ROM end wraps around", Banks_64, []bankWrite{{0x2000, 0x45}}, 0x05},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cart := loadTestCartridge(t, testROM(CART_MBC5, tt.banks, ExtRAMNone))
+			for _, w := range tt.writes {
+				cart.Write(w.addr, w.data)
+			}
+			if got := romBank(cart); got != tt.want {
+				t.Errorf("got bank %03X, want %03X", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMBC5RAMBanking(t *testing.T) {
+	tests := []struct {
+		name      string
+		cart      CartrigeType
+		banks     int
+		wantBanks int
+	}{
+		{"16 RAM banks", CART_MBC5_RAM_BATTERY, 16, 16},
+		{"rumble uses bit 3 for the motor", CART_MBC5_RUMBLE_RAM_BATTERY, 16, 8},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cart := loadTestCartridge(t, testROM(tt.cart, Banks_4, ExtRAM128KB))
+			cart.Write(0x0000, 0x0A)
+			for bank := 0; bank < tt.banks; bank++ {
+				cart.Write(0x4000, uint8(bank))
+				cart.Write(0xA000, uint8(0x40+bank))
+			}
+			for bank := 0; bank < tt.banks; bank++ {
+				cart.Write(0x4000, uint8(bank))
+				// Banks past the selectable ones alias the lower banks,
+				// so the later writes win
+				want := uint8(0x40 + bank%tt.wantBanks + tt.banks - tt.wantBanks)
+				if got := cart.Read(0xA000); got != want {
+					t.Errorf("bank %d read %02X, want %02X", bank, got, want)
+				}
+			}
+			cart.Write(0x0000, 0x00)
+			if got := cart.Read(0xA000); got != 0xFF {
+				t.Errorf("disabled RAM read %02X, want FF", got)
+			}
+		})
+	}
+}
+
+func TestMBC5Rumble(t *testing.T) {
+	tests := []struct {
+		name   string
+		cart   CartrigeType
+		writes []uint8
+		want   []bool
+	}{
+		{"motor on and off", CART_MBC5_RUMBLE_RAM, []uint8{0x08, 0x08, 0x00, 0x0B}, []bool{true, false, true}},
+		{"bank changes don't rumble", CART_MBC5_RUMBLE_RAM, []uint8{0x01, 0x07}, nil},
+		{"no motor without rumble", CART_MBC5_RAM, []uint8{0x08, 0x00}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cart := loadTestCartridge(t, testROM(tt.cart, Banks_4, ExtRAM128KB))
+			mbc := cart.MBC.(*MBC5)
+			var got []bool
+			mbc.OnRumble = func(on bool) {
+				got = append(got, on)
+			}
+			for _, data := range tt.writes {
+				cart.Write(0x4000, data)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got rumble changes %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("got rumble changes %v, want %v", got, tt.want)
+				}
+			}
+			if len(got) > 0 && mbc.Rumble() != got[len(got)-1] {
+				t.Errorf("Rumble() = %v, want %v", mbc.Rumble(), got[len(got)-1])
+			}
+		})
+	}
+}
+
+func TestMBC2Registers(t *testing.T) {
+	tests := []struct {
+		name       string
+		writes     []bankWrite
+		wantBank   int
+		ramEnabled bool
+	}{
+		{"default", nil, 1, false},
+		{"bit 8 set selects ROM bank", []bankWrite{{0x2100, 0x05}}, 5, false},
+		{"bank register in lower half", []bankWrite{{0x0100, 0x03}}, 3, false},
+		{"bank 0 maps bank 1", []bankWrite{{0x2100, 0x00}}, 1, false},
+		{"4-bit bank", []bankWrite{{0x2100, 0xFE}}, 0x0E, false},
+		{"bit 8 clear enables RAM", []bankWrite{{0x0000, 0x0A}}, 1, true},
+		{"RAM enable in upper half", []bankWrite{{0x2000, 0x0A}}, 1, true},
+		{"bank write doesn't enable RAM", []bankWrite{{0x2100, 0x0A}}, 0x0A, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cart := loadTestCartridge(t, testROM(CART_MBC2, Banks_16, ExtRAMNone))
+			for _, w := range tt.writes {
+				cart.Write(w.addr, w.data)
+			}
+			if got := romBank(cart); got != tt.wantBank {
+				t.Errorf("got bank %d, want %d", got, tt.wantBank)
+			}
+			if got := cart.MBC.(*MBC2).RAMEnabled(); got != tt.ramEnabled {
+				t.Errorf("RAM enabled %v, want %v", got, tt.ramEnabled)
+			}
+		})
+	}
+}
+
+func TestMBC2RAM(t *testing.T) {
+	cart := loadTestCartridge(t, testROM(CART_MBC2_BATTERY, Banks_16, ExtRAMNone))
+	cart.Write(0xA000, 0x05)
+	if got := cart.Read(0xA000); got != 0xFF {
+		t.Errorf("disabled RAM read %02X, want FF", got)
+	}
+	cart.Write(0x0000, 0x0A)
+	cart.Write(0xA000, 0x35)
+	cart.Write(0xA1FF, 0x0C)
+	tests := []struct {
+		addr uint16
+		want uint8
+	}{
+		// Only the lower nibble is stored, upper bits read as 1
+		{0xA000, 0xF5},
+		{0xA1FF, 0xFC},
+		// RAM is echoed through the external RAM area
+		{0xA200, 0xF5},
+		{0xB000, 0xF5},
+		{0xBFFF, 0xFC},
+	}
+	for _, tt := range tests {
+		if got := cart.Read(tt.addr); got != tt.want {
+			t.Errorf("read %04X = %02X, want %02X", tt.addr, got, tt.want)
+		}
+	}
+	if ram := cart.MBC.(*MBC2).RAM(); len(ram) != MBC2RAMSize {
+		t.Errorf("RAM size %d, want %d", len(ram), MBC2RAMSize)
+	}
+}
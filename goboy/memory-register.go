@@ -6,13 +6,18 @@ type MemoryRegister interface {
 	Get() uint8
 }
 
+// CallbackRegister calls fn on every write and reads back the last written value
 type CallbackRegister struct {
-	fn func(data uint8)
+	fn    func(data uint8)
+	value uint8
 }
 
-func (r CallbackRegister) RawSet(uint8)   {}
-func (r CallbackRegister) Set(data uint8) { r.fn(data) }
-func (r CallbackRegister) Get() uint8     { return 0 }
+func (r *CallbackRegister) RawSet(data uint8) { r.value = data }
+func (r *CallbackRegister) Get() uint8        { return r.value }
+func (r *CallbackRegister) Set(data uint8) {
+	r.value = data
+	r.fn(data)
+}
 
 const (
 	Bit0 = 1 << 0
@@ -49,3 +54,19 @@ func NewRWRegister(initialValue uint8, readOnlyBits uint8) *RWRegister {
 	}
 	return reg
 }
+
+// BankRegister selects a memory bank, fn is called with the new value on
+// every write. Bits outside mask read as 1.
+type BankRegister struct {
+	fn    func(bank uint8)
+	mask  uint8
+	value uint8
+}
+
+func (r *BankRegister) RawSet(data uint8) {
+	r.value = data & r.mask
+	r.fn(r.value)
+}
+
+func (r *BankRegister) Set(data uint8) { r.RawSet(data) }
+func (r *BankRegister) Get() uint8     { return r.value | ^r.mask }
@@ -1,9 +1,5 @@
 package goboy
 
-import (
-	"fmt"
-)
-
 // Memory is an interface for cpu to communicate with external devices (RAM, display etc.)
 type Memory interface {
 	Read(addr uint16) uint8
@@ -81,139 +77,297 @@ const (
 	HRAMSize     = HRAMEnd - HRAMStart + 1
 )
 
-func NewMMU(cart *Cartridge) *MMU {
+const (
+	AddrVBK  = 0xFF4F
+	AddrSVBK = 0xFF70
+)
+
+// WRAMBankSize is the size of the switchable WRAM bank at 0xD000
+const WRAMBankSize = 0x1000
+
+// page maps 256 bytes of the address space either directly to memory or to
+// read and write functions of a device
+type page struct {
+	data  []uint8
+	read  func(addr uint16) uint8
+	write func(addr uint16, data uint8)
+}
+
+func NewMMU(cart *Cartridge, model Model) *MMU {
 	mmu := &MMU{}
-	mmu.BootEnabled = true
+	mmu.Model = model
 	mmu.Cartridge = cart
 	mmu.Pad = &Joypad{
 		mmu: mmu,
 	}
 	mmu.GPU = NewDisplay(mmu)
-	mmu.WRAM = &GenericRAM{
-		data:   make([]uint8, WRAMSize),
-		offset: WRAMStart,
-	}
-	mmu.HRAM = &GenericRAM{
-		data:   make([]uint8, HRAMSize),
-		offset: HRAMStart,
-	}
-	mmu.registers = map[uint16]MemoryRegister{
-		AddrLCDC:     NewRWRegister(0x91, 0),
-		AddrLCDCStat: NewRWRegister(0, 0b111),
-		// AddrLCDCStat: NewRWRegister(0, 0),
-		AddrLYC:  NewRWRegister(0, 0),
-		AddrLY:   NewRWRegister(0, 255),
-		AddrIF:   NewRWRegister(0, 0),
-		AddrIE:   NewRWRegister(0, 0),
-		AddrSCX:  NewRWRegister(0, 0),
-		AddrSCY:  NewRWRegister(0, 0),
-		AddrBGP:  NewRWRegister(0, 0),
-		AddrOBP0: NewRWRegister(0, 0),
-		AddrOBP1: NewRWRegister(0, 0),
-		AddrSB:   NewRWRegister(0, 0),
-		// TODO: Should reset on write
-		AddrDIV:  NewRWRegister(0, 0),
-		AddrTIMA: NewRWRegister(0, 0),
-		AddrTMA:  NewRWRegister(0, 0),
-		AddrTAC:  NewRWRegister(0, 0),
-		AddrDMA: CallbackRegister{
-			fn: func(data uint8) {
-				addr := uint16(data) << 8
-				for i := OAMStart; i <= OAMEnd; i++ {
-					mmu.Write(uint16(i), mmu.Read(addr))
-					addr++
-				}
-			},
+	mmu.APU = NewAPU()
+	mmu.DMA = &OAMDMA{
+		mmu: mmu,
+	}
+	mmu.Timer = &Timer{
+		mmu: mmu,
+	}
+	mmu.Serial = &Serial{
+		mmu: mmu,
+	}
+	if model == ModelSGB {
+		mmu.SGB = NewSuperGameBoy(mmu)
+	}
+	mmu.ifReg = NewRWRegister(0, 0)
+	mmu.ie = NewRWRegister(0, 0)
+	// Color hardware has 8 banks of WRAM, the first is always mapped at 0xC000
+	wramBanks := 2
+	if model == ModelCGB {
+		wramBanks = 8
+	}
+	mmu.wram = make([]uint8, wramBanks*WRAMBankSize)
+
+	gpu := mmu.GPU
+	registers := map[uint16]MemoryRegister{
+		AddrLCDC:     gpu.lcdc,
+		AddrLCDCStat: gpu.stat,
+		AddrLYC:      gpu.lyc,
+		AddrLY:       gpu.ly,
+		AddrSCX:      gpu.scx,
+		AddrSCY:      gpu.scy,
+		AddrBGP:      gpu.bgp,
+		AddrOBP0:     gpu.obp0,
+		AddrOBP1:     gpu.obp1,
+		AddrWX:       gpu.wx,
+		AddrWY:       gpu.wy,
+		AddrIF:       mmu.ifReg,
+		AddrIE:       mmu.ie,
+		AddrSB:       serialRegister{mmu.Serial, AddrSB},
+		AddrSC:       serialRegister{mmu.Serial, AddrSC},
+		AddrDIV:      timerRegister{mmu.Timer, AddrDIV},
+		AddrTIMA:     timerRegister{mmu.Timer, AddrTIMA},
+		AddrTMA:      timerRegister{mmu.Timer, AddrTMA},
+		AddrTAC:      timerRegister{mmu.Timer, AddrTAC},
+		AddrDMA: &CallbackRegister{
+			fn: mmu.DMA.Start,
 		},
-		AddrWX:  NewRWRegister(0, 0),
-		AddrWY:  NewRWRegister(0, 0),
 		AddrJoy: mmu.Pad,
+		AddrBoot: &CallbackRegister{
+			fn: mmu.disableBootROM,
+		},
+	}
+	// Color hardware starts in Color mode, boot ROM switches to DMG
+	// compatibility mode where Color registers are unmapped
+	if model == ModelCGB {
+		mmu.key0 = &modeRegister{mmu: mmu}
+		registers[AddrKEY0] = mmu.key0
+		mmu.Speed = &SpeedSwitch{}
+		colorRegisters := map[uint16]MemoryRegister{
+			AddrKEY1: mmu.Speed,
+		}
+		colorRegisters[AddrVBK] = &BankRegister{
+			mask: 0x01,
+			fn:   gpu.selectVRAMBank,
+		}
+		colorRegisters[AddrSVBK] = &BankRegister{
+			mask: 0x07,
+			fn:   mmu.selectWRAMBank,
+		}
+		colorRegisters[AddrBCPS] = paletteRegister{gpu, &gpu.bgColors, false}
+		colorRegisters[AddrBCPD] = paletteRegister{gpu, &gpu.bgColors, true}
+		colorRegisters[AddrOCPS] = paletteRegister{gpu, &gpu.objColors, false}
+		colorRegisters[AddrOCPD] = paletteRegister{gpu, &gpu.objColors, true}
+		mmu.HDMA = &HDMA{
+			mmu:  mmu,
+			dest: VideoRAMStart,
+		}
+		for addr := uint16(AddrHDMA1); addr <= AddrHDMA5; addr++ {
+			colorRegisters[addr] = hdmaRegister{mmu.HDMA, addr}
+		}
+		mmu.colorRegisters = colorRegisters
 	}
+	for addr, reg := range registers {
+		mmu.io[addr-IOPortsStart] = reg
+	}
+	mmu.setColorMode(model == ModelCGB)
+
+	for i := range mmu.pages {
+		addr := uint16(i) << 8
+		p := &mmu.pages[i]
+		switch {
+		case addr <= ROMBankEnd, ExtRAMStart <= addr && addr <= ExtRAMEnd:
+			p.read = mmu.Cartridge.Read
+			p.write = mmu.Cartridge.Write
+		case VideoRAMStart <= addr && addr <= VideoRAMEnd:
+			p.read = mmu.GPU.Read
+			p.write = mmu.GPU.Write
+		case WRAMStart <= addr && addr < WRAMStart+WRAMBankSize:
+			p.data = mmu.wram[addr-WRAMStart:][:0x100]
+		case addr >= OAMStart:
+			p.read = mmu.readHigh
+			p.write = mmu.writeHigh
+		}
+	}
+	mmu.selectWRAMBank(1)
 	return mmu
 }
 
 type MMU struct {
-	Cartridge   *Cartridge
-	GPU         *Display
-	Pad         *Joypad
-	WRAM        Memory
-	HRAM        Memory
-	registers   map[uint16]MemoryRegister
+	Model     Model
+	Cartridge *Cartridge
+	GPU       *Display
+	APU       *APU
+	DMA       *OAMDMA
+	Timer     *Timer
+	Serial    *Serial
+	// Speed and HDMA are nil on hardware without Color features
+	Speed *SpeedSwitch
+	HDMA  *HDMA
+	// Registers mapped in Color mode and KEY0 selecting the mode, nil on
+	// hardware without Color features
+	colorRegisters map[uint16]MemoryRegister
+	key0           *modeRegister
+	// Color features are enabled, false in DMG compatibility mode
+	cgb bool
+	// SGB is nil on hardware other than Super Game Boy
+	SGB  *SuperGameBoy
+	Pad  *Joypad
+	wram []uint8
+	hram [HRAMSize]uint8
+	// Memory map in 256 byte pages and I/O registers from 0xFF00 to 0xFFFF,
+	// unmapped registers are nil
+	pages [0x100]page
+	io    [0x100]MemoryRegister
+	ifReg *RWRegister
+	ie    *RWRegister
+
+	// Cycles CPU is halted for by VRAM DMA
+	stall int
+
+	// Boot ROM is mapped over the start of cartridge ROM while enabled
 	BootEnabled bool
+	bootROM     []byte
+	// Display finished a frame since the flag was last cleared
+	frameReady bool
+}
+
+// setColorMode enables or disables Color features on Color hardware
+func (mmu *MMU) setColorMode(color bool) {
+	for addr, reg := range mmu.colorRegisters {
+		if !color {
+			reg = nil
+		}
+		mmu.io[addr-IOPortsStart] = reg
+	}
+	mmu.cgb = color
+	mmu.GPU.cgb = color
+	mmu.GPU.compat = !color && mmu.Model == ModelCGB
+}
+
+// ColorMode reports whether Color features are enabled. It's false on other
+// hardware and when Color hardware runs a DMG cartridge.
+func (mmu *MMU) ColorMode() bool {
+	return mmu.cgb
+}
+
+// selectWRAMBank maps WRAM bank at 0xD000 and its echo, bank 0 selects bank 1
+func (mmu *MMU) selectWRAMBank(bank uint8) {
+	if bank == 0 {
+		bank = 1
+	}
+	offset := int(bank) * WRAMBankSize
+	for i := 0; i < WRAMBankSize>>8; i++ {
+		mmu.pages[(WRAMStart+WRAMBankSize)>>8+i].data = mmu.wram[offset+i<<8:][:0x100]
+	}
+	// Echo RAM mirrors 0xC000-0xDDFF
+	for i := EchoStart >> 8; i <= EchoEnd>>8; i++ {
+		mmu.pages[i] = mmu.pages[i-EchoOffset>>8]
+	}
+}
+
+// Tick advances the components clocked by the system clock. In double speed
+// mode display and APU run at half the rate of the CPU.
+func (mmu *MMU) Tick(cycles int) {
+	mmu.Timer.Run(cycles)
+	mmu.Serial.Run(cycles)
+	mmu.DMA.Run(cycles)
+	if mmu.Speed != nil && mmu.Speed.DoubleSpeed {
+		cycles /= 2
+	}
+	if mmu.GPU.Run(cycles) {
+		mmu.frameReady = true
+		if mmu.SGB != nil {
+			mmu.SGB.frameDone()
+		}
+	}
+	mmu.APU.Run(cycles)
+}
+
+// requestInterrupt sets the interrupt flag of the given interrupt
+func (mmu *MMU) requestInterrupt(interrupt uint8) {
+	mmu.ifReg.RawSet(setBit(mmu.ifReg.Get(), interrupt))
 }
 
+// Read reads addr as the CPU sees it, accessing memory below I/O registers
+// during OAM DMA returns 0xFF
 func (mmu *MMU) Read(addr uint16) uint8 {
-	if reg, found := mmu.registers[addr]; found {
-		return reg.Get()
+	if mmu.DMA.Blocks(addr) {
+		return 0xFF
 	}
+	return mmu.read(addr)
+}
+
+func (mmu *MMU) read(addr uint16) uint8 {
+	p := &mmu.pages[addr>>8]
+	if p.data != nil {
+		return p.data[addr&0xFF]
+	}
+	return p.read(addr)
+}
+
+// readHigh reads OAM, I/O registers and HRAM
+func (mmu *MMU) readHigh(addr uint16) uint8 {
 	switch {
-	case ROMStart <= addr && addr <= ROMBankEnd:
-		// if addr <= ROMBootEnd && mmu.BootEnabled {
-		// 	return boot2[addr]
-		// }
-		return mmu.Cartridge.Read(addr)
-	case VideoRAMStart <= addr && addr <= VideoRAMEnd:
-		return mmu.GPU.Read(addr)
-	case ExtRAMStart <= addr && addr <= ExtRAMEnd:
-		return mmu.Cartridge.Read(addr)
-	case WRAMStart <= addr && addr <= EchoEnd:
-		if addr >= EchoStart {
-			addr -= EchoOffset
-		}
-		return mmu.WRAM.Read(addr)
-	case OAMStart <= addr && addr <= OAMEnd:
+	case addr <= OAMEnd:
 		return mmu.GPU.Read(addr)
-	// case IOPortsStart <= addr && addr <= IOPortsEnd:
-	// 	return mmu.IOPorts.Read(addr)
+	case addr < IOPortsStart:
+		return 0xFF
 	case HRAMStart <= addr && addr <= HRAMEnd:
-		return mmu.HRAM.Read(addr)
-	case addr == 0xFFFF:
+		return mmu.hram[addr-HRAMStart]
+	case SoundStart <= addr && addr <= SoundEnd:
+		return mmu.APU.Read(addr)
+	}
+	if reg := mmu.io[addr-IOPortsStart]; reg != nil {
+		return reg.Get()
 	}
 	return 0xFF
 }
 
+// Write writes to addr as the CPU sees it, writes to memory below I/O
+// registers are ignored during OAM DMA
 func (mmu *MMU) Write(addr uint16, data uint8) {
-	if addr == 0xFF02 && data == 0x81 {
-		fmt.Print(string(mmu.Read(AddrSB)))
+	if mmu.DMA.Blocks(addr) {
+		return
 	}
-	if reg, found := mmu.registers[addr]; found {
-		reg.Set(data)
+	p := &mmu.pages[addr>>8]
+	if p.data != nil {
+		p.data[addr&0xFF] = data
 		return
 	}
+	p.write(addr, data)
+}
+
+func (mmu *MMU) writeHigh(addr uint16, data uint8) {
 	switch {
-	case ROMStart <= addr && addr <= ROMBankEnd:
-		mmu.Cartridge.Write(addr, data)
-		return
-	case VideoRAMStart <= addr && addr <= VideoRAMEnd:
+	case addr <= OAMEnd:
 		mmu.GPU.Write(addr, data)
 		return
-	case ExtRAMStart <= addr && addr <= ExtRAMEnd:
-		mmu.Cartridge.Write(addr, data)
-		return
-	case WRAMStart <= addr && addr <= EchoEnd:
-		if addr >= EchoStart {
-			addr -= EchoOffset
-		}
-		mmu.WRAM.Write(addr, data)
-		return
-	case OAMStart <= addr && addr <= OAMEnd:
-		mmu.GPU.Write(addr, data)
+	case addr < IOPortsStart:
 		return
 	case HRAMStart <= addr && addr <= HRAMEnd:
-		mmu.HRAM.Write(addr, data)
+		mmu.hram[addr-HRAMStart] = data
+		return
+	case SoundStart <= addr && addr <= SoundEnd:
+		mmu.APU.Write(addr, data)
 		return
 	}
-}
-
-type GenericRAM struct {
-	data   []uint8
-	offset uint16
-}
-
-func (r *GenericRAM) Write(addr uint16, data uint8) {
-	r.data[addr-r.offset] = data
-}
-
-func (r *GenericRAM) Read(addr uint16) uint8 {
-	return r.data[addr-r.offset]
+	if reg := mmu.io[addr-IOPortsStart]; reg != nil {
+		reg.Set(data)
+	}
 }
@@ -0,0 +1,62 @@
+package goboy
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Model is the Game Boy hardware being emulated
+type Model int
+
+const (
+	ModelDMG Model = iota
+	// Game Boy Color running in Color mode
+	ModelCGB
+	// Early DMG with a different boot ROM
+	ModelDMG0
+	// Game Boy Pocket
+	ModelMGB
+	// Super Game Boy
+	ModelSGB
+)
+
+var modelNames = map[Model]string{
+	ModelDMG:  "DMG",
+	ModelCGB:  "CGB",
+	ModelDMG0: "DMG0",
+	ModelMGB:  "MGB",
+	ModelSGB:  "SGB",
+}
+
+func (m Model) String() string {
+	if name, ok := modelNames[m]; ok {
+		return name
+	}
+	return "Unknown"
+}
+
+// ParseModel returns the model with the given name, case is ignored
+func ParseModel(name string) (Model, error) {
+	for model, modelName := range modelNames {
+		if strings.EqualFold(name, modelName) {
+			return model, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown model %q", name)
+}
+
+// DefaultModel returns the hardware cartridge is made for, Color hardware for
+// cartridges supporting it and DMG for the rest
+func DefaultModel(cart *Cartridge) Model {
+	if cart.GCBFlag() != GB {
+		return ModelCGB
+	}
+	return ModelDMG
+}
+
+// colorMode reports whether Color features are enabled when cart runs on
+// model. Color hardware runs cartridges made for DMG in compatibility mode,
+// where only the palettes set by boot ROM are used.
+func colorMode(model Model, cart *Cartridge) bool {
+	return model == ModelCGB && cart.GCBFlag() != GB
+}
@@ -0,0 +1,297 @@
+package goboy
+
+import (
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+)
+
+// Printer commands
+const (
+	printerInit   = 0x01
+	printerPrint  = 0x02
+	printerData   = 0x04
+	printerStatus = 0x0F
+)
+
+// Printer status bits
+const (
+	printerChecksumError = Bit0
+	printerPrinting      = Bit1
+	printerFull          = Bit2
+	printerUnprocessed   = Bit3
+)
+
+// Parts of a printer packet in the order they are sent
+const (
+	printerMagic1 = iota
+	printerMagic2
+	printerCommand
+	printerCompression
+	printerLengthLow
+	printerLengthHigh
+	printerPayload
+	printerChecksumLow
+	printerChecksumHigh
+	printerAlive
+	printerStatusByte
+)
+
+const (
+	// printerBufferSize is the amount of image data printer can hold
+	printerBufferSize = 0x2000
+	// printerBusyPackets is the number of status packets printing takes
+	printerBusyPackets = 4
+	// printerAliveReply is sent after the checksum of each packet
+	printerAliveReply = 0x81
+)
+
+// Gray levels of the printed shades
+var printerShades = [4]uint8{0xFF, 0xAA, 0x55, 0x00}
+
+// Printer is the Game Boy Printer. It is connected with a link cable and
+// receives image data in packets from the Game Boy. Printed images are saved
+// as PNG files in Dir. Images printed without a margin between them are
+// saved as one image.
+type Printer struct {
+	Dir string
+
+	// Packet being received
+	part        int
+	command     uint8
+	compression uint8
+	length      int
+	payload     []uint8
+	checksum    uint16
+
+	status uint8
+	busy   int
+	buffer []uint8
+	// Printed rows of shades 160 pixels wide not saved yet
+	page  []uint8
+	count int
+	err   error
+}
+
+// NewPrinter creates a printer saving images into dir
+func NewPrinter(dir string) *Printer {
+	return &Printer{
+		Dir: dir,
+	}
+}
+
+// Transfer receives one byte of a packet and returns the reply. Printer
+// replies with zero until the end of the packet, where it sends the alive
+// byte and its status.
+func (p *Printer) Transfer(data uint8) uint8 {
+	switch p.part {
+	case printerMagic1:
+		if data == 0x88 {
+			p.part++
+		}
+	case printerMagic2:
+		if data == 0x33 {
+			p.part++
+		} else {
+			p.part = printerMagic1
+		}
+	case printerCommand:
+		p.command = data
+		p.checksum = uint16(data)
+		p.part++
+	case printerCompression:
+		p.compression = data
+		p.checksum += uint16(data)
+		p.part++
+	case printerLengthLow:
+		p.length = int(data)
+		p.checksum += uint16(data)
+		p.part++
+	case printerLengthHigh:
+		p.length |= int(data) << 8
+		p.checksum += uint16(data)
+		p.payload = p.payload[:0]
+		p.part = printerPayload
+		if p.length == 0 {
+			p.part = printerChecksumLow
+		}
+	case printerPayload:
+		p.payload = append(p.payload, data)
+		p.checksum += uint16(data)
+		if len(p.payload) == p.length {
+			p.part++
+		}
+	case printerChecksumLow:
+		p.checksum -= uint16(data)
+		p.part++
+	case printerChecksumHigh:
+		p.checksum -= uint16(data) << 8
+		if p.checksum == 0 {
+			p.status &^= printerChecksumError
+			p.runCommand()
+		} else {
+			p.status |= printerChecksumError
+		}
+		p.part++
+	case printerAlive:
+		p.part++
+		return printerAliveReply
+	case printerStatusByte:
+		p.part = printerMagic1
+		return p.status
+	}
+	return 0
+}
+
+// Receive never returns data, printer doesn't clock transfers
+func (p *Printer) Receive(reply uint8) (uint8, bool) {
+	return 0, false
+}
+
+func (p *Printer) runCommand() {
+	switch p.command {
+	case printerInit:
+		p.buffer = p.buffer[:0]
+		p.status = 0
+		p.busy = 0
+	case printerData:
+		// Empty data packet ends the data
+		if p.length == 0 {
+			return
+		}
+		data := p.payload
+		if p.compression != 0 {
+			data = decompressRLE(data)
+		}
+		p.buffer = append(p.buffer, data...)
+		if len(p.buffer) > printerBufferSize {
+			p.buffer = p.buffer[:printerBufferSize]
+		}
+		if len(p.buffer) == printerBufferSize {
+			p.status |= printerFull
+		}
+		p.status |= printerUnprocessed
+	case printerPrint:
+		if len(p.payload) < 4 {
+			return
+		}
+		p.print(p.payload[1], p.payload[2])
+		p.buffer = p.buffer[:0]
+		p.status &^= printerUnprocessed | printerFull
+		p.status |= printerPrinting
+		p.busy = printerBusyPackets
+	case printerStatus:
+		if p.busy > 0 {
+			p.busy--
+			if p.busy == 0 {
+				p.status &^= printerPrinting
+			}
+		}
+	}
+}
+
+// decompressRLE expands run length encoded data. Control byte with the top
+// bit set repeats the next byte (control & 0x7F) + 2 times, otherwise
+// control + 1 bytes are copied as is.
+func decompressRLE(data []uint8) []uint8 {
+	var out []uint8
+	for i := 0; i < len(data); {
+		control := data[i]
+		i++
+		if control&Bit7 != 0 {
+			if i >= len(data) {
+				break
+			}
+			for n := 0; n < int(control&0x7F)+2; n++ {
+				out = append(out, data[i])
+			}
+			i++
+			continue
+		}
+		for n := 0; n <= int(control) && i < len(data); n++ {
+			out = append(out, data[i])
+			i++
+		}
+	}
+	return out
+}
+
+// print adds the buffered tiles to the page. Upper nibble of margins is the
+// margin before and lower nibble the margin after the image, page is saved
+// when there's a margin. Palette maps each color to a shade.
+func (p *Printer) print(margins, palette uint8) {
+	if palette == 0 {
+		palette = 0xE4
+	}
+	if margins>>4 != 0 {
+		p.savePage()
+	}
+	// Tiles are in rows of 20, each row is 8 pixels high
+	rows := len(p.buffer) / (20 * 16) * 8
+	start := len(p.page)
+	p.page = append(p.page, make([]uint8, rows*160)...)
+	for y := 0; y < rows; y++ {
+		for x := 0; x < 160; x++ {
+			tile := p.buffer[((y/8)*20+x/8)*16:]
+			bit := 7 - uint(x%8)
+			low, high := tile[(y%8)*2], tile[(y%8)*2+1]
+			c := (low>>bit)&1 | ((high>>bit)&1)<<1
+			p.page[start+y*160+x] = printerShades[(palette>>(c*2))&0x3]
+		}
+	}
+	if margins&0xF != 0 {
+		p.savePage()
+	}
+}
+
+// savePage writes the printed page into the next free file in Dir, Dir is
+// created if it doesn't exist
+func (p *Printer) savePage() {
+	if len(p.page) == 0 {
+		return
+	}
+	img := &image.Gray{
+		Pix:    p.page,
+		Stride: 160,
+		Rect:   image.Rect(0, 0, 160, len(p.page)/160),
+	}
+	p.page = nil
+	if err := os.MkdirAll(p.Dir, 0755); err != nil {
+		p.err = err
+		return
+	}
+	var path string
+	for {
+		p.count++
+		path = filepath.Join(p.Dir, fmt.Sprintf("print-%03d.png", p.count))
+		if _, err := os.Stat(path); err != nil {
+			break
+		}
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		p.err = err
+		return
+	}
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		p.err = err
+		return
+	}
+	if err := f.Close(); err != nil {
+		p.err = err
+	}
+}
+
+// Flush saves the page printed since the last margin
+func (p *Printer) Flush() error {
+	p.savePage()
+	return p.Err()
+}
+
+// Err returns the last error saving an image
+func (p *Printer) Err() error {
+	return p.err
+}
@@ -0,0 +1,236 @@
+package goboy
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDecompressRLE(t *testing.T) {
+	tests := []struct {
+		name string
+		in   []uint8
+		want []uint8
+	}{
+		{"empty", nil, nil},
+		{"literal", []uint8{0x02, 1, 2, 3}, []uint8{1, 2, 3}},
+		{"run", []uint8{0x81, 0xAA}, []uint8{0xAA, 0xAA, 0xAA}},
+		{"shortest run", []uint8{0x80, 0x55}, []uint8{0x55, 0x55}},
+		{"longest run", []uint8{0xFF, 0x11}, bytes.Repeat([]uint8{0x11}, 129)},
+		{"literal and run", []uint8{0x00, 7, 0x82, 9, 0x01, 1, 2}, []uint8{7, 9, 9, 9, 9, 1, 2}},
+		{"truncated literal", []uint8{0x03, 1, 2}, []uint8{1, 2}},
+		{"truncated run", []uint8{0x00, 5, 0x85}, []uint8{5}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := decompressRLE(tt.in); !bytes.Equal(got, tt.want) {
+				t.Errorf("got %X, want %X", got, tt.want)
+			}
+		})
+	}
+}
+
+// sendPrinterPacket sends a packet with checksum off by checksumError and
+// returns the alive and status bytes replied by the printer
+func sendPrinterPacket(t *testing.T, p *Printer, command, compression uint8, payload []uint8, checksumError uint16) (uint8, uint8) {
+	t.Helper()
+	header := []uint8{command, compression, uint8(len(payload)), uint8(len(payload) >> 8)}
+	var sum uint16
+	for _, b := range append(header, payload...) {
+		sum += uint16(b)
+	}
+	sum += checksumError
+	packet := append([]uint8{0x88, 0x33}, header...)
+	packet = append(packet, payload...)
+	packet = append(packet, uint8(sum), uint8(sum>>8))
+	for i, b := range packet {
+		if reply := p.Transfer(b); reply != 0 {
+			t.Fatalf("printer replied %02X to byte %d of the packet", reply, i)
+		}
+	}
+	return p.Transfer(0), p.Transfer(0)
+}
+
+func TestPrinterStatus(t *testing.T) {
+	type packet struct {
+		command       uint8
+		payload       []uint8
+		checksumError uint16
+		status        uint8
+	}
+	print := []uint8{1, 0x00, 0xE4, 0x40}
+	tests := []struct {
+		name    string
+		packets []packet
+	}{
+		{"status after reset", []packet{{printerStatus, nil, 0, 0}}},
+		{"data waits to be printed", []packet{
+			{printerInit, nil, 0, 0},
+			{printerData, make([]uint8, 0x280), 0, printerUnprocessed},
+			{printerData, nil, 0, printerUnprocessed},
+		}},
+		{"full buffer", []packet{
+			{printerData, make([]uint8, printerBufferSize), 0, printerUnprocessed | printerFull},
+		}},
+		{"printing", []packet{
+			{printerData, make([]uint8, 0x280), 0, printerUnprocessed},
+			{printerPrint, print, 0, printerPrinting},
+			{printerStatus, nil, 0, printerPrinting},
+			{printerStatus, nil, 0, printerPrinting},
+			{printerStatus, nil, 0, printerPrinting},
+			{printerStatus, nil, 0, 0},
+		}},
+		{"init stops printing", []packet{
+			{printerData, make([]uint8, 0x280), 0, printerUnprocessed},
+			{printerPrint, print, 0, printerPrinting},
+			{printerInit, nil, 0, 0},
+		}},
+		{"checksum error", []packet{
+			{printerData, make([]uint8, 0x280), 1, printerChecksumError},
+			{printerStatus, nil, 0, 0},
+		}},
+		{"command isn't run on checksum error", []packet{
+			{printerData, make([]uint8, 0x280), 0, printerUnprocessed},
+			{printerInit, nil, 0x100, printerUnprocessed | printerChecksumError},
+		}},
+		{"print with short payload is ignored", []packet{
+			{printerData, make([]uint8, 0x280), 0, printerUnprocessed},
+			{printerPrint, print[:3], 0, printerUnprocessed},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewPrinter("")
+			for i, pkt := range tt.packets {
+				alive, status := sendPrinterPacket(t, p, pkt.command, 0, pkt.payload, pkt.checksumError)
+				if alive != printerAliveReply || status != pkt.status {
+					t.Errorf("packet %d: replied %02X %02X, want %02X %02X", i, alive, status, printerAliveReply, pkt.status)
+				}
+			}
+		})
+	}
+}
+
+// Bytes before the magic bytes are ignored
+func TestPrinterSync(t *testing.T) {
+	p := NewPrinter("")
+	for _, b := range []uint8{0x00, 0x88, 0x00, 0x33, 0x12} {
+		p.Transfer(b)
+	}
+	if p.part != printerMagic1 {
+		t.Fatalf("printer at part %d after noise", p.part)
+	}
+	if alive, status := sendPrinterPacket(t, p, printerData, 0, []uint8{1}, 0); alive != printerAliveReply || status != printerUnprocessed {
+		t.Errorf("replied %02X %02X", alive, status)
+	}
+}
+
+// printerTiles returns rows of tiles, in each tile pixel x has color x/2
+func printerTiles(rows int) []uint8 {
+	var data []uint8
+	for i := 0; i < rows*20*8; i++ {
+		data = append(data, 0x33, 0x0F)
+	}
+	return data
+}
+
+func readPNG(t *testing.T, path string) *image.Gray {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	img, err := png.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gray, ok := img.(*image.Gray)
+	if !ok {
+		t.Fatalf("%s is %T, want gray image", path, img)
+	}
+	return gray
+}
+
+func TestPrinterPNG(t *testing.T) {
+	dir, err := ioutil.TempDir("", "printer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	// Existing images aren't overwritten
+	if err := ioutil.WriteFile(filepath.Join(dir, "print-001.png"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	p := NewPrinter(dir)
+	// Compressed data with margin after the image
+	compressed := []uint8{0xFF, 0x00, 0xFF, 0x00, 0xBC, 0x00}
+	sendPrinterPacket(t, p, printerData, 1, compressed, 0)
+	sendPrinterPacket(t, p, printerData, 0, printerTiles(1), 0)
+	sendPrinterPacket(t, p, printerPrint, 0, []uint8{1, 0x01, 0x1B, 0x40}, 0)
+	// Two images without margins are saved as one on flush
+	sendPrinterPacket(t, p, printerData, 0, printerTiles(2), 0)
+	sendPrinterPacket(t, p, printerPrint, 0, []uint8{1, 0x00, 0x00, 0x40}, 0)
+	sendPrinterPacket(t, p, printerData, 0, printerTiles(1), 0)
+	sendPrinterPacket(t, p, printerPrint, 0, []uint8{1, 0x00, 0xE4, 0x40}, 0)
+	if err := p.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		file   string
+		height int
+		// Shades of the first 8 pixels of the first and the last row
+		first, last [8]uint8
+	}{
+		{
+			"print-002.png", 16,
+			[8]uint8{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+			[8]uint8{0x00, 0x00, 0x55, 0x55, 0xAA, 0xAA, 0xFF, 0xFF},
+		},
+		{
+			"print-003.png", 24,
+			[8]uint8{0xFF, 0xFF, 0xAA, 0xAA, 0x55, 0x55, 0x00, 0x00},
+			[8]uint8{0xFF, 0xFF, 0xAA, 0xAA, 0x55, 0x55, 0x00, 0x00},
+		},
+	}
+	for _, tt := range tests {
+		img := readPNG(t, filepath.Join(dir, tt.file))
+		if w, h := img.Rect.Dx(), img.Rect.Dy(); w != 160 || h != tt.height {
+			t.Errorf("%s is %dx%d, want 160x%d", tt.file, w, h, tt.height)
+			continue
+		}
+		for x := 0; x < 8; x++ {
+			if got := img.GrayAt(x, 0).Y; got != tt.first[x] {
+				t.Errorf("%s pixel %d,0 is %02X, want %02X", tt.file, x, got, tt.first[x])
+			}
+			if got := img.GrayAt(x, tt.height-1).Y; got != tt.last[x] {
+				t.Errorf("%s pixel %d,%d is %02X, want %02X", tt.file, x, tt.height-1, got, tt.last[x])
+			}
+		}
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "*.png"))
+	if len(files) != 3 {
+		t.Errorf("%d files in output directory, want 3", len(files))
+	}
+}
+
+func TestPrinterSaveError(t *testing.T) {
+	f, err := ioutil.TempFile("", "printer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+	// Output directory can't be created over a file
+	p := NewPrinter(filepath.Join(f.Name(), "prints"))
+	sendPrinterPacket(t, p, printerData, 0, printerTiles(1), 0)
+	sendPrinterPacket(t, p, printerPrint, 0, []uint8{1, 0x00, 0xE4, 0x40}, 0)
+	if err := p.Flush(); err == nil || p.Err() != err {
+		t.Errorf("Flush returned %v, Err %v", err, p.Err())
+	}
+}
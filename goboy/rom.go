@@ -1,8 +1,10 @@
 package goboy
 
 import (
+	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 )
 
 type CGB uint8
@@ -26,7 +28,7 @@ func (f CGB) String() string {
 	case OnlyCGB:
 		return "Only CGB cartridge"
 	default:
-		panic("Invalid CGBFlag")
+		return fmt.Sprintf("Unknown CGB flag %02X", uint8(f))
 	}
 }
 
@@ -39,13 +41,15 @@ const (
 	Banks_64  ROMBanks = 0x05
 	Banks_128 ROMBanks = 0x06
 	Banks_256 ROMBanks = 0x07
+	Banks_512 ROMBanks = 0x08
 	Banks_72  ROMBanks = 0x52
 	Banks_80  ROMBanks = 0x53
 	Banks_96  ROMBanks = 0x54
 )
 
+// Count returns the number of 16KB ROM banks, or 0 for unknown values
 func (banks ROMBanks) Count() int {
-	if banks <= Banks_256 {
+	if banks <= Banks_512 {
 		return 2 << int(banks)
 	} else if banks == Banks_72 {
 		return 72
@@ -54,7 +58,7 @@ func (banks ROMBanks) Count() int {
 	} else if banks == Banks_96 {
 		return 96
 	}
-	panic("Invalid ROMBanks")
+	return 0
 }
 
 func (banks ROMBanks) String() string {
@@ -75,6 +79,8 @@ func (banks ROMBanks) String() string {
 		return "Banks 128"
 	case Banks_256:
 		return "Banks 256"
+	case Banks_512:
+		return "Banks 512"
 	case Banks_72:
 		return "Banks 72"
 	case Banks_80:
@@ -82,9 +88,8 @@ func (banks ROMBanks) String() string {
 	case Banks_96:
 		return "Banks 96"
 	default:
-		panic("Invalid RomBanks")
+		return fmt.Sprintf("Unknown ROM size %02X", uint8(banks))
 	}
-	return ""
 }
 
 const (
@@ -180,17 +185,37 @@ func (ct CartrigeType) String() string {
 	case CART_HuC1_RAM_BATTERY:
 		return "HuC1_RAM_BATTERY"
 	default:
-		panic("Invalid CartridgeType")
+		return fmt.Sprintf("Unknown cartridge type %02X", uint8(ct))
 	}
 }
 
 const (
-	ExtRAMNone ExtRAMBanks = 0x00
-	ExtRAM2KB  ExtRAMBanks = 0x01
-	ExtRAM8KB  ExtRAMBanks = 0x02
-	ExtRAM32KB ExtRAMBanks = 0x03
+	ExtRAMNone  ExtRAMBanks = 0x00
+	ExtRAM2KB   ExtRAMBanks = 0x01
+	ExtRAM8KB   ExtRAMBanks = 0x02
+	ExtRAM32KB  ExtRAMBanks = 0x03
+	ExtRAM128KB ExtRAMBanks = 0x04
+	ExtRAM64KB  ExtRAMBanks = 0x05
 )
 
+// Size returns the size of external RAM in bytes
+func (ram ExtRAMBanks) Size() int {
+	switch ram {
+	case ExtRAM2KB:
+		return 2 * 1024
+	case ExtRAM8KB:
+		return 8 * 1024
+	case ExtRAM32KB:
+		return 32 * 1024
+	case ExtRAM128KB:
+		return 128 * 1024
+	case ExtRAM64KB:
+		return 64 * 1024
+	default:
+		return 0
+	}
+}
+
 func (ram ExtRAMBanks) String() string {
 	switch ram {
 	case ExtRAMNone:
@@ -201,8 +226,12 @@ func (ram ExtRAMBanks) String() string {
 		return "External RAM 8KB"
 	case ExtRAM32KB:
 		return "External RAM 32KB"
+	case ExtRAM128KB:
+		return "External RAM 128KB"
+	case ExtRAM64KB:
+		return "External RAM 64KB"
 	default:
-		panic("Invalid ExtRAMBanks")
+		return fmt.Sprintf("Unknown RAM size %02X", uint8(ram))
 	}
 }
 
@@ -218,46 +247,162 @@ func (code DestinationCode) String() string {
 	case DestinationNonJP:
 		return "Non-Japanese"
 	default:
-		panic("Invalid DestinationCode")
+		return fmt.Sprintf("Unknown destination code %02X", uint8(code))
 	}
 }
 
 type Cartridge struct {
-	Bank0 [ROMBankSize]byte
-	MBC   Memory
+	Bank0  [ROMBankSize]byte
+	MBC    Memory
+	Header Header
+
+	globalChecksum uint16
 }
 
 func (r *Cartridge) String() string {
 	return fmt.Sprintf(
 		`Title: %v
+Manufacturer code: %v
 GB Mode: %v
+SGB support: %v
+Publisher: %v
 Cartridge type: %v
 ROM Banks: %v
 RAM Banks: %v
-Destination code: %v`,
-		r.Title(), r.GCBFlag(), r.Cartridge(), r.ROMBanks(), r.ExtRAMBanks(), r.DestinationCode(),
+Destination code: %v
+Version: %v`,
+		r.Header.Title, r.Header.ManufacturerCode, r.Header.CGBFlag, r.Header.SGBSupported(),
+		r.Header.Publisher(), r.Header.CartridgeType, r.Header.ROMBanks, r.Header.ExtRAMBanks,
+		r.Header.DestinationCode, r.Header.MaskROMVersion,
 	)
 }
 
+// Errors returned by LoadCartridge
+var (
+	ErrInvalidROMSize = errors.New("invalid ROM size in cartridge header")
+	ErrTruncatedROM   = errors.New("ROM image is smaller than its header states")
+)
+
+// UnsupportedMapperError is returned by LoadCartridge when the cartridge
+// uses a memory bank controller that isn't emulated
+type UnsupportedMapperError struct {
+	Type CartrigeType
+}
+
+func (e *UnsupportedMapperError) Error() string {
+	return fmt.Sprintf("unsupported cartridge type %02X", uint8(e.Type))
+}
+
+// ChecksumError is returned when a checksum stored in the cartridge header
+// doesn't match the ROM contents
+type ChecksumError struct {
+	Global   bool
+	Expected uint16
+	Actual   uint16
+}
+
+func (e *ChecksumError) Error() string {
+	kind := "header"
+	if e.Global {
+		kind = "global"
+	}
+	return fmt.Sprintf("invalid %s checksum: expected %04X, got %04X", kind, e.Expected, e.Actual)
+}
+
+// LoadCartridge reads the whole ROM image from r. Cartridges with an invalid
+// header checksum are rejected like the boot ROM of real hardware does.
+// Global checksum isn't verified by hardware, use VerifyGlobalChecksum to check it.
 func LoadCartridge(r io.Reader) (*Cartridge, error) {
+	image, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(image) < 2*ROMBankSize {
+		return nil, ErrTruncatedROM
+	}
 	rom := Cartridge{}
-	// TODO: Error handling
-	r.Read(rom.Bank0[:])
+	copy(rom.Bank0[:], image)
+	rom.Header = ParseHeader(rom.Bank0[:])
+	if err := rom.verifyHeaderChecksum(); err != nil {
+		return nil, err
+	}
+	romSize := rom.ROMBanks().Count() * ROMBankSize
+	if romSize == 0 {
+		return nil, ErrInvalidROMSize
+	}
+	if len(image) < romSize {
+		return nil, ErrTruncatedROM
+	}
+	image = image[:romSize]
+	rom.globalChecksum = globalChecksum(image)
+	ram := make([]byte, rom.ExtRAMBanks().Size())
 	switch rom.Cartridge() {
-	case CART_ROM_ONLY:
-		mbc := MBC0{}
-		r.Read(mbc.rom[:])
-		rom.MBC = &mbc
+	case CART_ROM_ONLY, CART_ROM_RAM, CART_ROM_RAM_BATTERY:
+		rom.MBC = &MBC0{
+			rom: image,
+			ram: ram,
+		}
 	case CART_MBC1, CART_MBC1_RAM, CART_MBC1_RAM_BATTERY:
-		mbc := MBC1{
+		rom.MBC = &MBC1{
+			romBankNumber: 1,
+			rom:           image,
+			ram:           ram,
+		}
+	case CART_MBC2, CART_MBC2_BATTERY:
+		rom.MBC = &MBC2{
+			romBankNumber: 1,
+			rom:           image,
+		}
+	case CART_MBC3, CART_MBC3_RAM, CART_MBC3_RAM_BATTERY,
+		CART_MBC3_TIMER_BATTERY, CART_MBC3_TIMER_RAM_BATTERY:
+		rom.MBC = &MBC3{
 			romBankNumber: 1,
+			hasTimer:      rom.Cartridge() == CART_MBC3_TIMER_BATTERY || rom.Cartridge() == CART_MBC3_TIMER_RAM_BATTERY,
+			rom:           image,
+			ram:           ram,
 		}
-		r.Read(mbc.rom[:])
-		rom.MBC = &mbc
+	case CART_MBC5, CART_MBC5_RAM, CART_MBC5_RAM_BATTERY,
+		CART_MBC5_RUMBLE, CART_MBC5_RUMBLE_RAM, CART_MBC5_RUMBLE_RAM_BATTERY:
+		rom.MBC = &MBC5{
+			romBankNumber: 1,
+			hasRumble:     rom.Cartridge() >= CART_MBC5_RUMBLE,
+			rom:           image,
+			ram:           ram,
+		}
+	default:
+		return nil, &UnsupportedMapperError{Type: rom.Cartridge()}
 	}
 	return &rom, nil
 }
 
+func (r *Cartridge) verifyHeaderChecksum() error {
+	sum := headerChecksum(r.Bank0[:])
+	if expected := r.Header.HeaderChecksum; sum != expected {
+		return &ChecksumError{Expected: uint16(expected), Actual: uint16(sum)}
+	}
+	return nil
+}
+
+// globalChecksum sums all bytes of the image except the checksum itself
+func globalChecksum(image []byte) uint16 {
+	var sum uint16
+	for i, b := range image {
+		if i != 0x14E && i != 0x14F {
+			sum += uint16(b)
+		}
+	}
+	return sum
+}
+
+// VerifyGlobalChecksum checks the checksum of the whole ROM image stored at 0x14E
+func (r *Cartridge) VerifyGlobalChecksum() error {
+	expected := r.Header.GlobalChecksum
+	if r.globalChecksum != expected {
+		return &ChecksumError{Global: true, Expected: expected, Actual: r.globalChecksum}
+	}
+	return nil
+}
+
 func (rom *Cartridge) Read(addr uint16) uint8 {
 	if addr <= ROMEnd {
 		return rom.Bank0[addr]
@@ -269,39 +414,87 @@ func (rom *Cartridge) Write(addr uint16, data uint8) {
 	rom.MBC.Write(addr, data)
 }
 
-func (rom *Cartridge) Title() string {
-	titleBytes := rom.Bank0[0x134:0x144]
-	title := ""
-	for _, titleByte := range titleBytes {
-		if titleByte == 32 || ('A' <= titleByte && titleByte <= 'z') {
-			title += string(titleByte)
-		} else {
-			break
-		}
-	}
-	return title
+func (r *Cartridge) Title() string {
+	return r.Header.Title
 }
 
 func (r *Cartridge) GCBFlag() CGB {
-	flag := CGB(r.Bank0[0x143])
-	if flag == NonCGB || flag == OnlyCGB {
+	if flag := r.Header.CGBFlag; flag == NonCGB || flag == OnlyCGB {
 		return flag
 	}
 	return GB
 }
 
 func (r *Cartridge) Cartridge() CartrigeType {
-	return CartrigeType(r.Bank0[0x147])
+	return r.Header.CartridgeType
 }
 
 func (r *Cartridge) ROMBanks() ROMBanks {
-	return ROMBanks(r.Bank0[0x148])
+	return r.Header.ROMBanks
 }
 
 func (r *Cartridge) ExtRAMBanks() ExtRAMBanks {
-	return ExtRAMBanks(r.Bank0[0x148])
+	return r.Header.ExtRAMBanks
 }
 
 func (r *Cartridge) DestinationCode() DestinationCode {
-	return DestinationCode(r.Bank0[0x14A])
+	return r.Header.DestinationCode
+}
+
+// ErrNoSaveRAM is returned when saving or loading RAM of a cartridge without battery
+var ErrNoSaveRAM = errors.New("cartridge has no battery backed RAM")
+
+// HasBattery reports whether the cartridge keeps its RAM contents when powered off
+func (r *Cartridge) HasBattery() bool {
+	switch r.Cartridge() {
+	case CART_MBC1_RAM_BATTERY, CART_MBC2_BATTERY, CART_ROM_RAM_BATTERY,
+		CART_MMM01_RAM_BATTERY, CART_MBC3_TIMER_BATTERY, CART_MBC3_TIMER_RAM_BATTERY,
+		CART_MBC3_RAM_BATTERY, CART_MBC4_RAM_BATTERY, CART_MBC5_RAM_BATTERY,
+		CART_MBC5_RUMBLE_RAM_BATTERY, CART_HuC1_RAM_BATTERY:
+		return true
+	}
+	return false
+}
+
+// saveRAM returns mapper RAM that is persisted
+func (r *Cartridge) saveRAM() ([]byte, error) {
+	mbc, ok := r.MBC.(BatteryBacked)
+	if !r.HasBattery() || !ok {
+		return nil, ErrNoSaveRAM
+	}
+	return mbc.RAM(), nil
+}
+
+// SaveRAM writes battery backed RAM to w using the raw .sav layout used by
+// other emulators. MBC3 cartridges with timer have the RTC state appended
+// to the RAM in the same 48 byte format as VBA-M and BGB.
+func (r *Cartridge) SaveRAM(w io.Writer) error {
+	ram, err := r.saveRAM()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(ram); err != nil {
+		return err
+	}
+	if mbc, ok := r.MBC.(*MBC3); ok && mbc.hasTimer {
+		return mbc.RTC.SaveRTC(w)
+	}
+	return nil
+}
+
+// LoadRAM restores battery backed RAM written by SaveRAM
+func (r *Cartridge) LoadRAM(rd io.Reader) error {
+	ram, err := r.saveRAM()
+	if err != nil {
+		return err
+	}
+	data, err := ioutil.ReadAll(rd)
+	if err != nil {
+		return err
+	}
+	n := copy(ram, data)
+	if mbc, ok := r.MBC.(*MBC3); ok && mbc.hasTimer && len(data) > len(ram) {
+		return mbc.RTC.LoadRTC(data[n:])
+	}
+	return nil
 }
@@ -0,0 +1,226 @@
+package goboy
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestSaveRAMRoundTrip(t *testing.T) {
+	tests := []struct {
+		name     string
+		cart     CartrigeType
+		ram      ExtRAMBanks
+		wantSize int
+	}{
+		{"ROM with RAM", CART_ROM_RAM_BATTERY, ExtRAM8KB, 8 * 1024},
+		{"MBC1", CART_MBC1_RAM_BATTERY, ExtRAM32KB, 32 * 1024},
+		{"MBC2", CART_MBC2_BATTERY, ExtRAMNone, MBC2RAMSize},
+		{"MBC3", CART_MBC3_RAM_BATTERY, ExtRAM32KB, 32 * 1024},
+		{"MBC3 with timer", CART_MBC3_TIMER_RAM_BATTERY, ExtRAM32KB, 32*1024 + RTCSaveSize},
+		{"MBC3 timer only", CART_MBC3_TIMER_BATTERY, ExtRAMNone, RTCSaveSize},
+		{"MBC5", CART_MBC5_RAM_BATTERY, ExtRAM128KB, 128 * 1024},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img := testROM(tt.cart, Banks_4, tt.ram)
+			cart := loadTestCartridge(t, img)
+			ram := cart.MBC.(BatteryBacked).RAM()
+			for i := range ram {
+				ram[i] = uint8(i*7) & 0x0F
+			}
+			var buf bytes.Buffer
+			if err := cart.SaveRAM(&buf); err != nil {
+				t.Fatal(err)
+			}
+			if buf.Len() != tt.wantSize {
+				t.Fatalf("saved %d bytes, want %d", buf.Len(), tt.wantSize)
+			}
+			loaded := loadTestCartridge(t, img)
+			if err := loaded.LoadRAM(&buf); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(loaded.MBC.(BatteryBacked).RAM(), ram) {
+				t.Error("loaded RAM differs from saved RAM")
+			}
+		})
+	}
+}
+
+func TestSaveRAMWithoutBattery(t *testing.T) {
+	for _, ct := range []CartrigeType{CART_ROM_ONLY, CART_MBC1_RAM, CART_MBC3_RAM, CART_MBC5_RUMBLE_RAM} {
+		cart := loadTestCartridge(t, testROM(ct, Banks_4, ExtRAM8KB))
+		if err := cart.SaveRAM(&bytes.Buffer{}); err != ErrNoSaveRAM {
+			t.Errorf("%v: SaveRAM returned %v, want ErrNoSaveRAM", ct, err)
+		}
+		if err := cart.LoadRAM(&bytes.Buffer{}); err != ErrNoSaveRAM {
+			t.Errorf("%v: LoadRAM returned %v, want ErrNoSaveRAM", ct, err)
+		}
+	}
+}
+
+func TestSaveRAMRTCBlock(t *testing.T) {
+	img := testROM(CART_MBC3_TIMER_RAM_BATTERY, Banks_4, ExtRAM8KB)
+	clock := newTestClock()
+	cart := loadTestCartridge(t, img)
+	rtc := &cart.MBC.(*MBC3).RTC
+	rtc.Now = clock.now
+	setRTC(rtc, rtcTime{seconds: 50, minutes: 59, hours: 23, days: 0x1FF})
+	rtc.Latch()
+	var buf bytes.Buffer
+	if err := cart.SaveRAM(&buf); err != nil {
+		t.Fatal(err)
+	}
+	// RTC block follows RAM: live registers, latched registers and timestamp
+	block := buf.Bytes()[8*1024:]
+	if len(block) != RTCSaveSize {
+		t.Fatalf("RTC block is %d bytes, want %d", len(block), RTCSaveSize)
+	}
+	wantRegs := []uint8{50, 59, 23, 0xFF, 0x01}
+	for i, want := range wantRegs {
+		if block[i*4] != want || block[20+i*4] != want {
+			t.Errorf("register %d saved as %02X/%02X, want %02X", i, block[i*4], block[20+i*4], want)
+		}
+	}
+	if got := int64(block[40]) | int64(block[41])<<8 | int64(block[42])<<16 | int64(block[43])<<24; got != clock.t.Unix() {
+		t.Errorf("timestamp %d, want %d", got, clock.t.Unix())
+	}
+
+	// Time passed while the game wasn't running is added on load
+	clock.advance(15)
+	loaded := loadTestCartridge(t, img)
+	loadedRTC := &loaded.MBC.(*MBC3).RTC
+	loadedRTC.Now = clock.now
+	if err := loaded.LoadRAM(&buf); err != nil {
+		t.Fatal(err)
+	}
+	want := rtcTime{seconds: 5, carry: true}
+	if got := latchedRTC(loadedRTC); got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+type errReader struct{}
+
+func (errReader) Read([]byte) (int, error) {
+	return 0, errors.New("read failed")
+}
+
+func TestLoadCartridgeErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(img []byte) []byte
+		check  func(err error) bool
+	}{
+		{
+			"header checksum",
+			func(img []byte) []byte {
+				img[0x14D]++
+				return img
+			},
+			func(err error) bool {
+				cerr, ok := err.(*ChecksumError)
+				return ok && !cerr.Global
+			},
+		},
+		{
+			"modified header",
+			func(img []byte) []byte {
+				img[0x134] = 'X'
+				return img
+			},
+			func(err error) bool {
+				_, ok := err.(*ChecksumError)
+				return ok
+			},
+		},
+		{
+			"shorter than two banks",
+			func(img []byte) []byte {
+				return img[:ROMBankSize]
+			},
+			func(err error) bool { return err == ErrTruncatedROM },
+		},
+		{
+			"shorter than header ROM size",
+			func(img []byte) []byte {
+				return img[:3*ROMBankSize]
+			},
+			func(err error) bool { return err == ErrTruncatedROM },
+		},
+		{
+			"invalid ROM size",
+			func(img []byte) []byte {
+				img[0x148] = 0x20
+				fixChecksums(img)
+				return img
+			},
+			func(err error) bool { return err == ErrInvalidROMSize },
+		},
+		{
+			"unsupported mapper",
+			func(img []byte) []byte {
+				img[0x147] = uint8(CART_HuC3)
+				fixChecksums(img)
+				return img
+			},
+			func(err error) bool {
+				merr, ok := err.(*UnsupportedMapperError)
+				return ok && merr.Type == CART_HuC3
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img := tt.modify(testROM(CART_MBC1, Banks_4, ExtRAMNone))
+			cart, err := LoadCartridge(bytes.NewReader(img))
+			if cart != nil || !tt.check(err) {
+				t.Errorf("got %v, %v", cart, err)
+			}
+		})
+	}
+	if _, err := LoadCartridge(errReader{}); err == nil || err.Error() != "read failed" {
+		t.Errorf("read error not returned, got %v", err)
+	}
+}
+
+func TestLoadCartridgeSizes(t *testing.T) {
+	tests := []struct {
+		name    string
+		banks   ROMBanks
+		ram     ExtRAMBanks
+		extra   int
+		wantROM int
+		wantRAM int
+	}{
+		{"32KB ROM", Banks_0, ExtRAMNone, 0, 32 * 1024, 0},
+		{"256KB ROM with 8KB RAM", Banks_16, ExtRAM8KB, 0, 256 * 1024, 8 * 1024},
+		{"2MB ROM with 32KB RAM", Banks_128, ExtRAM32KB, 0, 2 * 1024 * 1024, 32 * 1024},
+		{"trailing data is dropped", Banks_4, ExtRAM2KB, 100, 64 * 1024, 2 * 1024},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img := testROM(CART_MBC1_RAM, tt.banks, tt.ram)
+			img = append(img, make([]byte, tt.extra)...)
+			mbc := loadTestCartridge(t, img).MBC.(*MBC1)
+			if len(mbc.rom) != tt.wantROM {
+				t.Errorf("ROM size %d, want %d", len(mbc.rom), tt.wantROM)
+			}
+			if len(mbc.ram) != tt.wantRAM {
+				t.Errorf("RAM size %d, want %d", len(mbc.ram), tt.wantRAM)
+			}
+		})
+	}
+}
+
+func TestVerifyGlobalChecksum(t *testing.T) {
+	img := testROM(CART_MBC1, Banks_4, ExtRAMNone)
+	if err := loadTestCartridge(t, img).VerifyGlobalChecksum(); err != nil {
+		t.Errorf("valid ROM: %v", err)
+	}
+	img[3*ROMBankSize+100] = 0x55
+	err := loadTestCartridge(t, img).VerifyGlobalChecksum()
+	if cerr, ok := err.(*ChecksumError); !ok || !cerr.Global || cerr.Actual != cerr.Expected+0x55 {
+		t.Errorf("modified ROM: got %v", err)
+	}
+}
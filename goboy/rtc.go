@@ -0,0 +1,197 @@
+package goboy
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"time"
+)
+
+// RTC register numbers, selected by writing them to MBC3 RAM bank register
+const (
+	RTCSeconds  = 0x08
+	RTCMinutes  = 0x09
+	RTCHours    = 0x0A
+	RTCDaysLow  = 0x0B
+	RTCDaysHigh = 0x0C
+)
+
+// Bits of the RTCDaysHigh register
+const (
+	rtcDayHighBit = Bit0
+	rtcHaltBit    = Bit6
+	rtcCarryBit   = Bit7
+)
+
+// RTC is the real-time clock found in MBC3 cartridges with a timer
+type RTC struct {
+	// Now returns the current time, time.Now is used if it's nil.
+	// It can be replaced to control the clock e.g. in tests.
+	Now func() time.Time
+
+	seconds  uint8
+	minutes  uint8
+	hours    uint8
+	days     uint16
+	halt     bool
+	dayCarry bool
+
+	// Values visible to the game, updated on latch
+	latched [5]uint8
+	// Time of the last sync, sub-second remainder is kept here
+	last time.Time
+}
+
+func (rtc *RTC) now() time.Time {
+	if rtc.Now == nil {
+		return time.Now()
+	}
+	return rtc.Now()
+}
+
+// Latch copies the current time into the registers readable by the game
+func (rtc *RTC) Latch() {
+	rtc.sync()
+	rtc.latched = rtc.registers()
+}
+
+// Read returns the latched value of RTC register reg
+func (rtc *RTC) Read(reg uint8) uint8 {
+	return rtc.latched[reg-RTCSeconds]
+}
+
+// Write sets the running value of RTC register reg
+func (rtc *RTC) Write(reg uint8, data uint8) {
+	rtc.sync()
+	switch reg {
+	case RTCSeconds:
+		rtc.seconds = data & 0x3F
+		// Writing seconds resets the sub-second counter
+		rtc.last = rtc.now()
+	case RTCMinutes:
+		rtc.minutes = data & 0x3F
+	case RTCHours:
+		rtc.hours = data & 0x1F
+	case RTCDaysLow:
+		rtc.days = rtc.days&0x100 | uint16(data)
+	case RTCDaysHigh:
+		rtc.days = rtc.days&0xFF | uint16(data&rtcDayHighBit)<<8
+		rtc.dayCarry = data&rtcCarryBit != 0
+		rtc.halt = data&rtcHaltBit != 0
+	}
+	rtc.latched[reg-RTCSeconds] = rtc.registers()[reg-RTCSeconds]
+}
+
+func (rtc *RTC) registers() [5]uint8 {
+	daysHigh := uint8(rtc.days>>8) & rtcDayHighBit
+	if rtc.halt {
+		daysHigh |= rtcHaltBit
+	}
+	if rtc.dayCarry {
+		daysHigh |= rtcCarryBit
+	}
+	return [5]uint8{rtc.seconds, rtc.minutes, rtc.hours, uint8(rtc.days), daysHigh}
+}
+
+// sync advances the clock by the whole seconds passed since the last sync
+func (rtc *RTC) sync() {
+	now := rtc.now()
+	if rtc.last.IsZero() || rtc.halt {
+		rtc.last = now
+		return
+	}
+	elapsed := now.Sub(rtc.last)
+	if elapsed < time.Second {
+		return
+	}
+	secs := int64(elapsed / time.Second)
+	rtc.last = rtc.last.Add(time.Duration(secs) * time.Second)
+	rtc.advance(secs)
+}
+
+func (rtc *RTC) advance(secs int64) {
+	// Out of range values written by the game count up to their bit width
+	// without carrying, step those one second at a time
+	for secs > 0 && (rtc.seconds > 59 || rtc.minutes > 59 || rtc.hours > 23) {
+		rtc.tick()
+		secs--
+	}
+	if secs == 0 {
+		return
+	}
+	total := int64(rtc.days)*86400 + int64(rtc.hours)*3600 + int64(rtc.minutes)*60 + int64(rtc.seconds) + secs
+	rtc.seconds = uint8(total % 60)
+	rtc.minutes = uint8(total / 60 % 60)
+	rtc.hours = uint8(total / 3600 % 24)
+	days := total / 86400
+	if days > 0x1FF {
+		rtc.dayCarry = true
+		days %= 0x200
+	}
+	rtc.days = uint16(days)
+}
+
+func (rtc *RTC) tick() {
+	rtc.seconds = (rtc.seconds + 1) & 0x3F
+	if rtc.seconds != 60 {
+		return
+	}
+	rtc.seconds = 0
+	rtc.minutes = (rtc.minutes + 1) & 0x3F
+	if rtc.minutes != 60 {
+		return
+	}
+	rtc.minutes = 0
+	rtc.hours = (rtc.hours + 1) & 0x1F
+	if rtc.hours != 24 {
+		return
+	}
+	rtc.hours = 0
+	rtc.days++
+	if rtc.days > 0x1FF {
+		rtc.days = 0
+		rtc.dayCarry = true
+	}
+}
+
+// RTCSaveSize is the size of RTC state appended to .sav files
+const RTCSaveSize = 48
+
+// SaveRTC writes clock state in the layout VBA-M and BGB append to .sav
+// files: live and latched registers as 32-bit little endian values followed
+// by 64-bit unix timestamp of the moment they were valid.
+func (rtc *RTC) SaveRTC(w io.Writer) error {
+	rtc.sync()
+	var buf [RTCSaveSize]byte
+	live := rtc.registers()
+	for i := range live {
+		binary.LittleEndian.PutUint32(buf[i*4:], uint32(live[i]))
+		binary.LittleEndian.PutUint32(buf[20+i*4:], uint32(rtc.latched[i]))
+	}
+	binary.LittleEndian.PutUint64(buf[40:], uint64(rtc.last.Unix()))
+	_, err := w.Write(buf[:])
+	return err
+}
+
+// LoadRTC restores clock state written by SaveRTC. The older 44 byte
+// variant with 32-bit timestamp is also accepted. Time passed since the
+// save is added to the clock unless it was halted.
+func (rtc *RTC) LoadRTC(data []byte) error {
+	var timestamp int64
+	switch {
+	case len(data) >= RTCSaveSize:
+		timestamp = int64(binary.LittleEndian.Uint64(data[40:]))
+	case len(data) >= 44:
+		timestamp = int64(binary.LittleEndian.Uint32(data[40:]))
+	default:
+		return errors.New("invalid RTC save data")
+	}
+	rtc.last = time.Time{}
+	for i := 0; i < 5; i++ {
+		rtc.Write(RTCSeconds+uint8(i), uint8(binary.LittleEndian.Uint32(data[i*4:])))
+		rtc.latched[i] = uint8(binary.LittleEndian.Uint32(data[20+i*4:]))
+	}
+	rtc.last = time.Unix(timestamp, 0)
+	rtc.sync()
+	return nil
+}
@@ -0,0 +1,171 @@
+package goboy
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+// testClock is a time source for RTC that only moves when told to
+type testClock struct {
+	t time.Time
+}
+
+func newTestClock() *testClock {
+	return &testClock{t: time.Unix(1600000000, 0)}
+}
+
+func (c *testClock) now() time.Time {
+	return c.t
+}
+
+func (c *testClock) advance(secs int) {
+	c.t = c.t.Add(time.Duration(secs) * time.Second)
+}
+
+type rtcTime struct {
+	seconds, minutes, hours uint8
+	days                    uint16
+	carry                   bool
+}
+
+func setRTC(rtc *RTC, tm rtcTime) {
+	daysHigh := uint8(tm.days>>8) & rtcDayHighBit
+	if tm.carry {
+		daysHigh |= rtcCarryBit
+	}
+	rtc.Write(RTCSeconds, tm.seconds)
+	rtc.Write(RTCMinutes, tm.minutes)
+	rtc.Write(RTCHours, tm.hours)
+	rtc.Write(RTCDaysLow, uint8(tm.days))
+	rtc.Write(RTCDaysHigh, daysHigh)
+}
+
+func latchedRTC(rtc *RTC) rtcTime {
+	rtc.Latch()
+	daysHigh := rtc.Read(RTCDaysHigh)
+	return rtcTime{
+		seconds: rtc.Read(RTCSeconds),
+		minutes: rtc.Read(RTCMinutes),
+		hours:   rtc.Read(RTCHours),
+		days:    uint16(rtc.Read(RTCDaysLow)) | uint16(daysHigh&rtcDayHighBit)<<8,
+		carry:   daysHigh&rtcCarryBit != 0,
+	}
+}
+
+func TestRTCAdvance(t *testing.T) {
+	tests := []struct {
+		name    string
+		start   rtcTime
+		elapsed int
+		want    rtcTime
+	}{
+		{"under a second", rtcTime{seconds: 10}, 0, rtcTime{seconds: 10}},
+		{"seconds", rtcTime{seconds: 10}, 5, rtcTime{seconds: 15}},
+		{"59 to 60 carries into minutes", rtcTime{seconds: 59}, 1, rtcTime{minutes: 1}},
+		{"hour carry", rtcTime{seconds: 59, minutes: 59, hours: 22}, 1, rtcTime{hours: 23}},
+		{"day carry", rtcTime{seconds: 59, minutes: 59, hours: 23, days: 0xFF}, 1, rtcTime{days: 0x100}},
+		{"out of range seconds wrap without carry", rtcTime{seconds: 62, minutes: 5}, 2, rtcTime{minutes: 5}},
+		{"out of range seconds pass 60", rtcTime{seconds: 0x3F}, 61, rtcTime{seconds: 0, minutes: 1}},
+		{"out of range hours wrap without carry", rtcTime{seconds: 59, minutes: 59, hours: 31, days: 7}, 1, rtcTime{days: 7}},
+		{"day counter overflow sets carry", rtcTime{seconds: 59, minutes: 59, hours: 23, days: 0x1FF}, 1, rtcTime{carry: true}},
+		{"overflow by many days", rtcTime{days: 0x1FE}, 3 * 86400, rtcTime{days: 1, carry: true}},
+		{"carry stays set", rtcTime{days: 3, carry: true}, 86400, rtcTime{days: 4, carry: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := newTestClock()
+			rtc := &RTC{Now: clock.now}
+			setRTC(rtc, tt.start)
+			clock.advance(tt.elapsed)
+			if got := latchedRTC(rtc); got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRTCLatch(t *testing.T) {
+	clock := newTestClock()
+	rtc := &RTC{Now: clock.now}
+	setRTC(rtc, rtcTime{seconds: 10})
+	clock.advance(5)
+	if got := rtc.Read(RTCSeconds); got != 10 {
+		t.Errorf("seconds %d before latch, want 10", got)
+	}
+	rtc.Latch()
+	clock.advance(20)
+	if got := rtc.Read(RTCSeconds); got != 15 {
+		t.Errorf("seconds %d after latch, want 15", got)
+	}
+}
+
+func TestRTCHalt(t *testing.T) {
+	clock := newTestClock()
+	rtc := &RTC{Now: clock.now}
+	setRTC(rtc, rtcTime{seconds: 10})
+	clock.advance(2)
+	rtc.Write(RTCDaysHigh, rtcHaltBit)
+	clock.advance(100)
+	if got := latchedRTC(rtc); got.seconds != 12 {
+		t.Errorf("halted clock moved to %d seconds, want 12", got.seconds)
+	}
+	if rtc.Read(RTCDaysHigh)&rtcHaltBit == 0 {
+		t.Error("halt bit not readable")
+	}
+	rtc.Write(RTCDaysHigh, 0)
+	clock.advance(3)
+	if got := latchedRTC(rtc); got.seconds != 15 {
+		t.Errorf("seconds %d after resuming, want 15", got.seconds)
+	}
+}
+
+func TestRTCSaveLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		halt    bool
+		size    int
+		elapsed int
+		want    rtcTime
+	}{
+		{"catches up elapsed time", false, RTCSaveSize, 90, rtcTime{seconds: 40, minutes: 21, hours: 3, days: 2}},
+		{"catches up days", false, RTCSaveSize, 2 * 86400, rtcTime{seconds: 10, minutes: 20, hours: 3, days: 4}},
+		{"halted clock doesn't catch up", true, RTCSaveSize, 90, rtcTime{seconds: 10, minutes: 20, hours: 3, days: 2}},
+		{"32-bit timestamp", false, 44, 90, rtcTime{seconds: 40, minutes: 21, hours: 3, days: 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := newTestClock()
+			rtc := &RTC{Now: clock.now}
+			setRTC(rtc, rtcTime{seconds: 10, minutes: 20, hours: 3, days: 2})
+			if tt.halt {
+				rtc.Write(RTCDaysHigh, rtcHaltBit)
+			}
+			rtc.Latch()
+			var buf bytes.Buffer
+			if err := rtc.SaveRTC(&buf); err != nil {
+				t.Fatal(err)
+			}
+			if buf.Len() != RTCSaveSize {
+				t.Fatalf("saved %d bytes, want %d", buf.Len(), RTCSaveSize)
+			}
+			clock.advance(tt.elapsed)
+			loaded := &RTC{Now: clock.now}
+			if err := loaded.LoadRTC(buf.Bytes()[:tt.size]); err != nil {
+				t.Fatal(err)
+			}
+			got := latchedRTC(loaded)
+			got.carry = false
+			if got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRTCLoadInvalid(t *testing.T) {
+	rtc := &RTC{Now: newTestClock().now}
+	if err := rtc.LoadRTC(make([]byte, 40)); err == nil {
+		t.Error("loading truncated RTC data succeeded")
+	}
+}
@@ -0,0 +1,148 @@
+package goboy
+
+// AddrSC is the serial transfer control register
+const AddrSC = 0xFF02
+
+// Serial control bits
+const (
+	scTransfer      = Bit7
+	scFastClock     = Bit1
+	scInternalClock = Bit0
+)
+
+// Number of cycles it takes to shift one bit with the internal clock
+const (
+	serialBitCycles     = ClockSpeed / 8192
+	serialFastBitCycles = ClockSpeed / 262144
+)
+
+// LinkCable connects the serial port to another device
+type LinkCable interface {
+	// Transfer sends a byte clocked by this Game Boy and returns the byte
+	// sent back by the other end
+	Transfer(data uint8) uint8
+	// Receive checks whether the other end has clocked a byte. Reply is sent
+	// back to it, ok is false when nothing has been received.
+	Receive(reply uint8) (data uint8, ok bool)
+}
+
+// Serial is the serial port. A transfer shifts SB out one bit at a time
+// while bits from the other end are shifted in. With the internal clock
+// this Game Boy drives the transfer, with the external clock it waits for
+// the other end to send a byte.
+type Serial struct {
+	mmu   *MMU
+	cable LinkCable
+	sb    uint8
+	sc    uint8
+	// Byte received from the other end, shifted in during the transfer
+	in uint8
+	// Bits left in the current transfer and cycles until the next one
+	bits  int
+	timer int
+}
+
+// Run advances the serial port by the given amount of cycles
+func (s *Serial) Run(cycles int) {
+	if s.sc&scTransfer == 0 {
+		s.poll(cycles, false)
+		return
+	}
+	if s.sc&scInternalClock == 0 {
+		s.poll(cycles, true)
+		return
+	}
+	for s.timer -= cycles; s.timer <= 0 && s.bits > 0; s.timer += s.bitCycles() {
+		s.sb = s.sb<<1 | s.in>>7
+		s.in <<= 1
+		s.bits--
+	}
+	if s.bits == 0 {
+		s.finish()
+	}
+}
+
+// poll checks the cable for bytes from the other end every bit period. When
+// no transfer with the external clock is waiting, the other end reads 0xFF.
+func (s *Serial) poll(cycles int, waiting bool) {
+	if s.cable == nil {
+		return
+	}
+	if s.timer -= cycles; s.timer > 0 {
+		return
+	}
+	s.timer += serialBitCycles
+	reply := uint8(0xFF)
+	if waiting {
+		reply = s.sb
+	}
+	data, ok := s.cable.Receive(reply)
+	if ok && waiting {
+		s.sb = data
+		s.finish()
+	}
+}
+
+func (s *Serial) bitCycles() int {
+	if s.mmu.cgb && s.sc&scFastClock != 0 {
+		return serialFastBitCycles
+	}
+	return serialBitCycles
+}
+
+func (s *Serial) finish() {
+	s.sc &^= scTransfer
+	s.bits = 0
+	s.timer = 0
+	s.mmu.requestInterrupt(SerialInt)
+}
+
+// setControl starts a transfer when the transfer bit is set. Byte from the
+// other end is exchanged at the start, without a cable all bits read as 1.
+func (s *Serial) setControl(data uint8) {
+	s.sc = data
+	s.timer = 0
+	if data&scTransfer == 0 || data&scInternalClock == 0 {
+		return
+	}
+	s.in = 0xFF
+	if s.cable != nil {
+		s.in = s.cable.Transfer(s.sb)
+	}
+	s.bits = 8
+	s.timer = s.bitCycles()
+}
+
+// serialRegister exposes SB and SC to the MMU
+type serialRegister struct {
+	serial *Serial
+	addr   uint16
+}
+
+func (r serialRegister) RawSet(data uint8) {
+	if r.addr == AddrSB {
+		r.serial.sb = data
+		return
+	}
+	r.serial.sc = data & (scTransfer | scFastClock | scInternalClock)
+}
+
+func (r serialRegister) Set(data uint8) {
+	if r.addr == AddrSB {
+		r.serial.sb = data
+		return
+	}
+	r.serial.setControl(data)
+}
+
+func (r serialRegister) Get() uint8 {
+	s := r.serial
+	if r.addr == AddrSB {
+		return s.sb
+	}
+	// Clock speed bit only exists in Color mode, unused bits read as 1
+	if s.mmu.cgb {
+		return s.sc | 0x7C
+	}
+	return s.sc | 0x7E
+}
@@ -0,0 +1,201 @@
+package goboy
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+// fakeCable records bytes sent to it. Transfer replies with reply and
+// Receive delivers the bytes queued in clocked.
+type fakeCable struct {
+	reply    uint8
+	clocked  []uint8
+	sent     []uint8
+	received []uint8
+}
+
+func (c *fakeCable) Transfer(data uint8) uint8 {
+	c.sent = append(c.sent, data)
+	return c.reply
+}
+
+func (c *fakeCable) Receive(reply uint8) (uint8, bool) {
+	c.received = append(c.received, reply)
+	if len(c.clocked) == 0 {
+		return 0, false
+	}
+	data := c.clocked[0]
+	c.clocked = c.clocked[1:]
+	return data, true
+}
+
+func serialInterrupt(e *Emulator) bool {
+	return e.MMU.ifReg.Get()&(1<<SerialInt) != 0
+}
+
+// runSerial runs the serial port and returns the cycles until the serial
+// interrupt, or -1 if it isn't requested within limit cycles
+func runSerial(e *Emulator, limit int) int {
+	for cycles := 0; cycles <= limit; cycles += 4 {
+		if serialInterrupt(e) {
+			return cycles
+		}
+		e.MMU.Serial.Run(4)
+	}
+	return -1
+}
+
+func TestSerialInternalClock(t *testing.T) {
+	tests := []struct {
+		name   string
+		model  Model
+		sc     uint8
+		cable  *fakeCable
+		cycles int
+		sb     uint8
+	}{
+		{"no cable", ModelDMG, 0x81, nil, 8 * serialBitCycles, 0xFF},
+		{"cable", ModelDMG, 0x81, &fakeCable{reply: 0x5A}, 8 * serialBitCycles, 0x5A},
+		{"fast clock on CGB", ModelCGB, 0x83, &fakeCable{reply: 0x0F}, 8 * serialFastBitCycles, 0x0F},
+		{"fast clock ignored on DMG", ModelDMG, 0x83, nil, 8 * serialBitCycles, 0xFF},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := testEmulator(t, tt.model)
+			e.MMU.ifReg.RawSet(0)
+			if tt.cable != nil {
+				e.SetLinkCable(tt.cable)
+			}
+			e.MMU.Write(AddrSB, 0x42)
+			e.MMU.Write(AddrSC, tt.sc)
+			if got := runSerial(e, 2*tt.cycles); got != tt.cycles {
+				t.Errorf("interrupt after %d cycles, want %d", got, tt.cycles)
+			}
+			if got := e.MMU.Read(AddrSB); got != tt.sb {
+				t.Errorf("SB is %02X, want %02X", got, tt.sb)
+			}
+			if e.MMU.Read(AddrSC)&scTransfer != 0 {
+				t.Error("transfer bit still set")
+			}
+			if tt.cable != nil && (len(tt.cable.sent) != 1 || tt.cable.sent[0] != 0x42) {
+				t.Errorf("cable got %X, want 42", tt.cable.sent)
+			}
+		})
+	}
+}
+
+// Bits are shifted in one at a time while the transfer runs
+func TestSerialShift(t *testing.T) {
+	e := testEmulator(t, ModelDMG)
+	e.SetLinkCable(&fakeCable{reply: 0x0F})
+	e.MMU.Write(AddrSB, 0xAA)
+	e.MMU.Write(AddrSC, 0x81)
+	want := []uint8{0x54, 0xA8, 0x50, 0xA0, 0x41, 0x83, 0x07, 0x0F}
+	for i, sb := range want {
+		e.MMU.Serial.Run(serialBitCycles)
+		if got := e.MMU.Read(AddrSB); got != sb {
+			t.Errorf("bit %d: SB is %02X, want %02X", i, got, sb)
+		}
+	}
+}
+
+func TestSerialExternalClock(t *testing.T) {
+	tests := []struct {
+		name      string
+		sc        uint8
+		clocked   []uint8
+		interrupt bool
+		sb        uint8
+		reply     uint8
+	}{
+		{"byte received", 0x80, []uint8{0x99}, true, 0x99, 0x42},
+		{"nothing received", 0x80, nil, false, 0x42, 0x42},
+		{"not waiting for a transfer", 0x00, []uint8{0x99}, false, 0x42, 0xFF},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := testEmulator(t, ModelDMG)
+			e.MMU.ifReg.RawSet(0)
+			cable := &fakeCable{clocked: tt.clocked}
+			e.SetLinkCable(cable)
+			e.MMU.Write(AddrSB, 0x42)
+			e.MMU.Write(AddrSC, tt.sc)
+			// Cable is polled right away and then once per bit period
+			for i := 0; i < 3*serialBitCycles; i += 4 {
+				e.MMU.Serial.Run(4)
+			}
+			if len(cable.received) != 4 || cable.received[0] != tt.reply {
+				t.Fatalf("replies %X, want four starting with %02X", cable.received, tt.reply)
+			}
+			if serialInterrupt(e) != tt.interrupt {
+				t.Errorf("interrupt %v, want %v", serialInterrupt(e), tt.interrupt)
+			}
+			if got := e.MMU.Read(AddrSB); got != tt.sb {
+				t.Errorf("SB is %02X, want %02X", got, tt.sb)
+			}
+			if len(cable.sent) != 0 {
+				t.Error("external clock transfer clocked a byte")
+			}
+		})
+	}
+}
+
+func TestSerialControlRead(t *testing.T) {
+	tests := []struct {
+		model Model
+		write uint8
+		want  uint8
+	}{
+		{ModelDMG, 0x00, 0x7E},
+		{ModelDMG, 0xFF, 0xFF},
+		{ModelDMG, 0x02, 0x7E},
+		{ModelCGB, 0x00, 0x7C},
+		{ModelCGB, 0x02, 0x7E},
+	}
+	for _, tt := range tests {
+		e := testEmulator(t, tt.model)
+		e.MMU.Write(AddrSC, tt.write)
+		if got := e.MMU.Read(AddrSC); got != tt.want {
+			t.Errorf("%v: wrote %02X, read %02X, want %02X", tt.model, tt.write, got, tt.want)
+		}
+	}
+}
+
+// receiveTCP polls cable until the other end has clocked a byte
+func receiveTCP(t *testing.T, cable *TCPLinkCable, reply uint8) uint8 {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if data, ok := cable.Receive(reply); ok {
+			return data
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatal("nothing received")
+	return 0
+}
+
+func TestTCPLinkCable(t *testing.T) {
+	c1, c2 := net.Pipe()
+	a, b := NewTCPLinkCable(c1), NewTCPLinkCable(c2)
+	defer b.Close()
+	for _, data := range []uint8{0x12, 0x00, 0xFF} {
+		reply := make(chan uint8)
+		go func() { reply <- a.Transfer(data) }()
+		if got := receiveTCP(t, b, ^data); got != data {
+			t.Errorf("received %02X, want %02X", got, data)
+		}
+		if got := <-reply; got != ^data {
+			t.Errorf("reply %02X, want %02X", got, ^data)
+		}
+	}
+	if _, ok := b.Receive(0); ok {
+		t.Error("received a byte that wasn't sent")
+	}
+	// Without a cable connected to the other end all bits read as 1
+	a.Close()
+	if got := a.Transfer(0x12); got != 0xFF {
+		t.Errorf("transfer on closed cable returned %02X", got)
+	}
+}
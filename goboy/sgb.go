@@ -0,0 +1,406 @@
+package goboy
+
+// Size of the Super Game Boy output including the border
+const (
+	SGBWidth  = 256
+	SGBHeight = 224
+)
+
+// Game screen position inside the border
+const (
+	sgbScreenX = 48
+	sgbScreenY = 40
+)
+
+// SGB commands
+const (
+	sgbPAL01   = 0x00
+	sgbPAL23   = 0x01
+	sgbPAL03   = 0x02
+	sgbPAL12   = 0x03
+	sgbATTRBLK = 0x04
+	sgbATTRLIN = 0x05
+	sgbATTRDIV = 0x06
+	sgbATTRCHR = 0x07
+	sgbMLTREQ  = 0x11
+	sgbCHRTRN  = 0x13
+	sgbPCTTRN  = 0x14
+	sgbMASKEN  = 0x17
+)
+
+// Screen mask modes set by MASK_EN
+const (
+	sgbMaskNone = iota
+	sgbMaskFreeze
+	sgbMaskBlack
+	sgbMaskColor0
+)
+
+// sgbPacketSize is the number of bytes in one packet
+const sgbPacketSize = 16
+
+// sgbTransferSize is the number of bytes sent through VRAM by *_TRN commands
+const sgbTransferSize = 0x1000
+
+// Palette used before the game sets its own
+var sgbDefaultPalette = [4]uint16{0x67BF, 0x265B, 0x10B5, 0x2866}
+
+// SuperGameBoy is the Super Game Boy adapter. Game sends it command packets
+// by pulsing P14 and P15 of the joypad register and larger data by showing
+// it on the screen. Game screen is colorized with 4 palettes and shown
+// inside a border.
+type SuperGameBoy struct {
+	mmu *MMU
+	// Cartridge supports SGB functions, packets are ignored otherwise
+	enabled bool
+
+	// Packet transfer
+	joypadSelect uint8
+	receiving    bool
+	bits         int
+	packet       [sgbPacketSize]uint8
+	// Packets of a command with multiple packets
+	command []uint8
+
+	// Multiplayer
+	players int
+	player  int
+
+	palettes [4][4]uint16
+	// Palette of each 8x8 cell of the game screen
+	attributes [20 * 18]uint8
+	mask       int
+	// Command waiting for data shown on the next frame and its parameter
+	transfer      uint8
+	transferParam uint8
+
+	// Border tiles in 4 bits per pixel SNES format, tile map and the
+	// palettes 4-7 used by it
+	borderTiles    [2 * sgbTransferSize]uint8
+	borderMap      [32 * 28]uint16
+	borderPalettes [4][16]uint16
+
+	screen [160 * 144]uint16
+	output [SGBWidth * SGBHeight]uint16
+}
+
+// NewSuperGameBoy creates the adapter for the cartridge
+func NewSuperGameBoy(mmu *MMU) *SuperGameBoy {
+	s := &SuperGameBoy{
+		mmu:          mmu,
+		enabled:      mmu.Cartridge != nil && mmu.Cartridge.Header.SGBSupported(),
+		joypadSelect: 0x30,
+		players:      1,
+	}
+	for i := range s.palettes {
+		s.palettes[i] = sgbDefaultPalette
+	}
+	s.render()
+	return s
+}
+
+// writeJoypad decodes packets from writes to the select bits of the joypad
+// register. Both lines low resets the transfer, after which each bit is
+// sent by pulling P14 (0) or P15 (1) low and releasing both. 128 bits are
+// sent least significant bit first and followed by a 0 stop bit.
+func (s *SuperGameBoy) writeJoypad(data uint8) {
+	sel := data & 0x30
+	prev := s.joypadSelect
+	s.joypadSelect = sel
+	switch sel {
+	case 0x00:
+		s.receiving = true
+		s.bits = 0
+		s.packet = [sgbPacketSize]uint8{}
+	case 0x10, 0x20:
+		if !s.receiving || prev != 0x30 {
+			return
+		}
+		bit := sel == 0x10
+		if s.bits == sgbPacketSize*8 {
+			s.receiving = false
+			if !bit {
+				s.receivePacket()
+			}
+			return
+		}
+		if bit {
+			s.packet[s.bits/8] |= 1 << (s.bits % 8)
+		}
+		s.bits++
+	case 0x30:
+		// Next controller is selected when P15 goes high
+		if prev&0x20 == 0 {
+			s.player = (s.player + 1) % s.players
+		}
+	}
+}
+
+// joypadID is the value of the lower bits of the joypad register when
+// neither key group is selected
+func (s *SuperGameBoy) joypadID() uint8 {
+	return 0xF - uint8(s.player)
+}
+
+// receivePacket collects packets until the whole command has been received.
+// Number of packets is in the lower 3 bits of the first byte.
+func (s *SuperGameBoy) receivePacket() {
+	if !s.enabled {
+		return
+	}
+	if len(s.command) == 0 && s.packet[0]&0x7 == 0 {
+		return
+	}
+	s.command = append(s.command, s.packet[:]...)
+	if len(s.command)/sgbPacketSize < int(s.command[0]&0x7) {
+		return
+	}
+	s.runCommand(s.command)
+	s.command = s.command[:0]
+}
+
+func (s *SuperGameBoy) runCommand(data []uint8) {
+	switch data[0] >> 3 {
+	case sgbPAL01:
+		s.setPalettes(0, 1, data)
+	case sgbPAL23:
+		s.setPalettes(2, 3, data)
+	case sgbPAL03:
+		s.setPalettes(0, 3, data)
+	case sgbPAL12:
+		s.setPalettes(1, 2, data)
+	case sgbATTRBLK:
+		s.attrBlock(data)
+	case sgbATTRLIN:
+		s.attrLine(data)
+	case sgbATTRDIV:
+		s.attrDivide(data)
+	case sgbATTRCHR:
+		s.attrChar(data)
+	case sgbMLTREQ:
+		s.players = [4]int{1, 2, 1, 4}[data[1]&0x3]
+		s.player = 0
+	case sgbCHRTRN, sgbPCTTRN:
+		s.transfer = data[0] >> 3
+		s.transferParam = data[1]
+	case sgbMASKEN:
+		s.mask = int(data[1] & 0x3)
+	}
+}
+
+// setPalettes sets colors 1-3 of two palettes, color 0 is shared by all
+func (s *SuperGameBoy) setPalettes(a, b int, data []uint8) {
+	color := func(i int) uint16 {
+		return (uint16(data[1+i*2]) | uint16(data[2+i*2])<<8) & 0x7FFF
+	}
+	for i := range s.palettes {
+		s.palettes[i][0] = color(0)
+	}
+	for i := 1; i < 4; i++ {
+		s.palettes[a][i] = color(i)
+		s.palettes[b][i] = color(i + 3)
+	}
+}
+
+// attrBlock sets palettes inside, on the border of and outside rectangles
+func (s *SuperGameBoy) attrBlock(data []uint8) {
+	count := int(data[1])
+	for i := 0; i < count && 2+i*6+5 < len(data); i++ {
+		set := data[2+i*6:]
+		control, palettes := set[0]&0x7, set[1]
+		x1, y1, x2, y2 := int(set[2]), int(set[3]), int(set[4]), int(set[5])
+		inside, border, outside := palettes&0x3, (palettes>>2)&0x3, (palettes>>4)&0x3
+		// With only inside or outside selected border is changed too
+		switch control {
+		case Bit0:
+			control |= Bit1
+			border = inside
+		case Bit2:
+			control |= Bit1
+			border = outside
+		}
+		for y := 0; y < 18; y++ {
+			for x := 0; x < 20; x++ {
+				idx := y*20 + x
+				switch {
+				case x > x1 && x < x2 && y > y1 && y < y2:
+					if control&Bit0 != 0 {
+						s.attributes[idx] = inside
+					}
+				case x >= x1 && x <= x2 && y >= y1 && y <= y2:
+					if control&Bit1 != 0 {
+						s.attributes[idx] = border
+					}
+				default:
+					if control&Bit2 != 0 {
+						s.attributes[idx] = outside
+					}
+				}
+			}
+		}
+	}
+}
+
+// attrLine sets palettes of whole rows or columns
+func (s *SuperGameBoy) attrLine(data []uint8) {
+	count := int(data[1])
+	for i := 0; i < count && 2+i < len(data); i++ {
+		line, palette := int(data[2+i]&0x1F), (data[2+i]>>5)&0x3
+		if data[2+i]&Bit7 != 0 {
+			for x := 0; x < 20 && line < 18; x++ {
+				s.attributes[line*20+x] = palette
+			}
+		} else {
+			for y := 0; y < 18 && line < 20; y++ {
+				s.attributes[y*20+line] = palette
+			}
+		}
+	}
+}
+
+// attrDivide splits the screen in two at a row or column
+func (s *SuperGameBoy) attrDivide(data []uint8) {
+	after, before, on := data[1]&0x3, (data[1]>>2)&0x3, (data[1]>>4)&0x3
+	horizontal := data[1]&Bit6 != 0
+	split := int(data[2])
+	for y := 0; y < 18; y++ {
+		for x := 0; x < 20; x++ {
+			pos := x
+			if horizontal {
+				pos = y
+			}
+			palette := on
+			if pos < split {
+				palette = before
+			} else if pos > split {
+				palette = after
+			}
+			s.attributes[y*20+x] = palette
+		}
+	}
+}
+
+// attrChar sets palettes of consecutive cells, 2 bits per cell
+func (s *SuperGameBoy) attrChar(data []uint8) {
+	x, y := int(data[1]), int(data[2])
+	count := int(data[3]) | int(data[4])<<8
+	vertical := data[5]&Bit0 != 0
+	for i := 0; i < count && 6+i/4 < len(data); i++ {
+		if x >= 20 || y >= 18 {
+			return
+		}
+		s.attributes[y*20+x] = (data[6+i/4] >> (6 - uint(i%4)*2)) & 0x3
+		if vertical {
+			if y++; y == 18 {
+				y = 0
+				x++
+			}
+		} else {
+			if x++; x == 20 {
+				x = 0
+				y++
+			}
+		}
+	}
+}
+
+// frameDone is called when the game has finished drawing a frame
+func (s *SuperGameBoy) frameDone() {
+	shades := &s.mmu.GPU.shades
+	if s.transfer != 0 {
+		s.receiveTransfer(shades)
+	}
+	switch s.mask {
+	case sgbMaskNone:
+		for i, shade := range shades {
+			s.screen[i] = s.palettes[s.attributes[(i/160/8)*20+(i%160)/8]][shade]
+		}
+	case sgbMaskBlack:
+		for i := range s.screen {
+			s.screen[i] = 0
+		}
+	case sgbMaskColor0:
+		for i := range s.screen {
+			s.screen[i] = s.palettes[0][0]
+		}
+	}
+	s.render()
+}
+
+// receiveTransfer reads 4KB of data from the screen. Data is shown as 256
+// tiles placed row by row on the screen.
+func (s *SuperGameBoy) receiveTransfer(shades *[160 * 144]uint8) {
+	var data [sgbTransferSize]uint8
+	for tile := 0; tile < 256; tile++ {
+		tileX, tileY := tile%20, tile/20
+		for row := 0; row < 8; row++ {
+			var low, high uint8
+			for x := 0; x < 8; x++ {
+				shade := shades[(tileY*8+row)*160+tileX*8+x]
+				low |= (shade & 1) << (7 - x)
+				high |= (shade >> 1) << (7 - x)
+			}
+			data[tile*16+row*2] = low
+			data[tile*16+row*2+1] = high
+		}
+	}
+	switch s.transfer {
+	case sgbCHRTRN:
+		// Parameter selects the upper or lower half of the tiles
+		offset := int(s.transferParam&Bit0) * sgbTransferSize
+		copy(s.borderTiles[offset:], data[:])
+	case sgbPCTTRN:
+		for i := range s.borderMap {
+			s.borderMap[i] = uint16(data[i*2]) | uint16(data[i*2+1])<<8
+		}
+		for p := range s.borderPalettes {
+			for c := range s.borderPalettes[p] {
+				i := 0x800 + (p*16+c)*2
+				s.borderPalettes[p][c] = (uint16(data[i]) | uint16(data[i+1])<<8) & 0x7FFF
+			}
+		}
+	}
+	s.transfer = 0
+}
+
+// render draws the border and the game screen into the output
+func (s *SuperGameBoy) render() {
+	for y := 0; y < SGBHeight; y++ {
+		for x := 0; x < SGBWidth; x++ {
+			if x >= sgbScreenX && x < sgbScreenX+160 && y >= sgbScreenY && y < sgbScreenY+144 {
+				s.output[y*SGBWidth+x] = s.screen[(y-sgbScreenY)*160+x-sgbScreenX]
+				continue
+			}
+			s.output[y*SGBWidth+x] = s.borderPixel(x, y)
+		}
+	}
+}
+
+// borderPixel returns the color of the border at x, y. Color 0 shows the
+// shared color 0 of the game palettes.
+func (s *SuperGameBoy) borderPixel(x, y int) uint16 {
+	entry := s.borderMap[(y/8)*32+x/8]
+	tile := s.borderTiles[int(entry&0xFF)*32:]
+	tileX, tileY := x%8, y%8
+	if entry&(1<<14) != 0 {
+		tileX = 7 - tileX
+	}
+	if entry&(1<<15) != 0 {
+		tileY = 7 - tileY
+	}
+	// Bit planes 0 and 1 are in the first 16 bytes and 2 and 3 after them
+	var color uint8
+	for plane := 0; plane < 4; plane++ {
+		b := tile[(plane/2)*16+tileY*2+plane%2]
+		color |= ((b >> (7 - uint(tileX))) & 1) << uint(plane)
+	}
+	if color == 0 {
+		return s.palettes[0][0]
+	}
+	return s.borderPalettes[(entry>>10)&0x3][color]
+}
+
+// Framebuffer returns the output with border as RGB555 colors
+func (s *SuperGameBoy) Framebuffer() []uint16 {
+	return s.output[:]
+}
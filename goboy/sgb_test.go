@@ -0,0 +1,339 @@
+package goboy
+
+import (
+	"testing"
+)
+
+// sgbEmulator creates a Super Game Boy running a cartridge supporting it
+func sgbEmulator(t *testing.T) *Emulator {
+	t.Helper()
+	img := testROM(CART_ROM_ONLY, Banks_0, ExtRAMNone)
+	img[0x146] = uint8(SGBFunctions)
+	fixChecksums(img)
+	e := NewEmulator(loadTestCartridge(t, img))
+	e.Model = ModelSGB
+	e.Reset()
+	return e
+}
+
+// sendPacket sends a packet through the joypad register followed by stop
+func sendPacket(e *Emulator, packet [sgbPacketSize]uint8, stop uint8) {
+	e.MMU.Write(AddrJoy, 0x00)
+	e.MMU.Write(AddrJoy, 0x30)
+	for i := 0; i <= sgbPacketSize*8; i++ {
+		bit := packet[i/8%sgbPacketSize] >> (i % 8) & 1
+		if i == sgbPacketSize*8 {
+			bit = stop
+		}
+		if bit != 0 {
+			e.MMU.Write(AddrJoy, 0x10)
+		} else {
+			e.MMU.Write(AddrJoy, 0x20)
+		}
+		e.MMU.Write(AddrJoy, 0x30)
+	}
+}
+
+func attrAt(s *SuperGameBoy, x, y int) uint8 {
+	return s.attributes[y*20+x]
+}
+
+func TestSGBCommands(t *testing.T) {
+	colors := [sgbPacketSize]uint8{0, 0x11, 0x11, 0x22, 0x22, 0x33, 0x33, 0x44, 0x44, 0x55, 0x55, 0x66, 0x66, 0x77, 0xF7}
+	withCommand := func(command uint8, p [sgbPacketSize]uint8) [sgbPacketSize]uint8 {
+		p[0] = command
+		return p
+	}
+	tests := []struct {
+		name    string
+		packets [][sgbPacketSize]uint8
+		check   func(s *SuperGameBoy) bool
+	}{
+		{
+			"PAL01",
+			[][sgbPacketSize]uint8{withCommand(0x01, colors)},
+			func(s *SuperGameBoy) bool {
+				return s.palettes[0] == [4]uint16{0x1111, 0x2222, 0x3333, 0x4444} &&
+					s.palettes[1] == [4]uint16{0x1111, 0x5555, 0x6666, 0x7777} &&
+					s.palettes[2] == [4]uint16{0x1111, sgbDefaultPalette[1], sgbDefaultPalette[2], sgbDefaultPalette[3]}
+			},
+		},
+		{
+			"PAL23",
+			[][sgbPacketSize]uint8{withCommand(0x09, colors)},
+			func(s *SuperGameBoy) bool {
+				return s.palettes[2][1] == 0x2222 && s.palettes[3][3] == 0x7777 && s.palettes[0][1] == sgbDefaultPalette[1]
+			},
+		},
+		{
+			"PAL03",
+			[][sgbPacketSize]uint8{withCommand(0x11, colors)},
+			func(s *SuperGameBoy) bool { return s.palettes[0][1] == 0x2222 && s.palettes[3][1] == 0x5555 },
+		},
+		{
+			"PAL12",
+			[][sgbPacketSize]uint8{withCommand(0x19, colors)},
+			func(s *SuperGameBoy) bool { return s.palettes[1][1] == 0x2222 && s.palettes[2][1] == 0x5555 },
+		},
+		{
+			"ATTR_BLK inside and outside",
+			[][sgbPacketSize]uint8{{0x21, 1, 0x07, 1 | 2<<2 | 3<<4, 2, 2, 5, 5}},
+			func(s *SuperGameBoy) bool {
+				return attrAt(s, 3, 3) == 1 && attrAt(s, 2, 4) == 2 && attrAt(s, 5, 5) == 2 && attrAt(s, 6, 3) == 3
+			},
+		},
+		{
+			"ATTR_BLK inside only changes border",
+			[][sgbPacketSize]uint8{{0x21, 1, 0x01, 1 | 2<<2 | 3<<4, 2, 2, 5, 5}},
+			func(s *SuperGameBoy) bool {
+				return attrAt(s, 3, 3) == 1 && attrAt(s, 2, 2) == 1 && attrAt(s, 6, 6) == 0
+			},
+		},
+		{
+			"ATTR_BLK outside only changes border",
+			[][sgbPacketSize]uint8{{0x21, 1, 0x04, 1 | 2<<2 | 3<<4, 2, 2, 5, 5}},
+			func(s *SuperGameBoy) bool {
+				return attrAt(s, 3, 3) == 0 && attrAt(s, 5, 2) == 3 && attrAt(s, 0, 0) == 3
+			},
+		},
+		{
+			"ATTR_LIN rows and columns",
+			[][sgbPacketSize]uint8{{0x29, 2, Bit7 | 2<<5 | 4, 3<<5 | 7}},
+			func(s *SuperGameBoy) bool {
+				return attrAt(s, 0, 4) == 2 && attrAt(s, 19, 4) == 2 && attrAt(s, 7, 0) == 3 &&
+					attrAt(s, 7, 17) == 3 && attrAt(s, 0, 5) == 0
+			},
+		},
+		{
+			"ATTR_DIV vertical",
+			[][sgbPacketSize]uint8{{0x31, 2 | 1<<2 | 3<<4, 10}},
+			func(s *SuperGameBoy) bool {
+				return attrAt(s, 9, 0) == 1 && attrAt(s, 10, 17) == 3 && attrAt(s, 11, 5) == 2
+			},
+		},
+		{
+			"ATTR_DIV horizontal",
+			[][sgbPacketSize]uint8{{0x31, Bit6 | 2 | 1<<2 | 3<<4, 4}},
+			func(s *SuperGameBoy) bool {
+				return attrAt(s, 19, 3) == 1 && attrAt(s, 0, 4) == 3 && attrAt(s, 0, 5) == 2
+			},
+		},
+		{
+			"ATTR_CHR wraps to the next row",
+			[][sgbPacketSize]uint8{{0x39, 18, 0, 4, 0, 0, 0x6D}},
+			func(s *SuperGameBoy) bool {
+				return attrAt(s, 18, 0) == 1 && attrAt(s, 19, 0) == 2 && attrAt(s, 0, 1) == 3 && attrAt(s, 1, 1) == 1
+			},
+		},
+		{
+			"ATTR_CHR vertical",
+			[][sgbPacketSize]uint8{{0x39, 0, 17, 2, 0, 1, 0xB0}},
+			func(s *SuperGameBoy) bool { return attrAt(s, 0, 17) == 2 && attrAt(s, 1, 0) == 3 },
+		},
+		{
+			"ATTR_CHR continues in the second packet",
+			[][sgbPacketSize]uint8{{0x3A, 0, 0, 44, 0, 0}, {0xC0}},
+			func(s *SuperGameBoy) bool { return attrAt(s, 0, 2) == 3 && attrAt(s, 1, 2) == 0 },
+		},
+		{
+			"MLT_REQ",
+			[][sgbPacketSize]uint8{{0x89, 0x03}},
+			func(s *SuperGameBoy) bool { return s.players == 4 && s.player == 0 },
+		},
+		{
+			"MASK_EN",
+			[][sgbPacketSize]uint8{{0xB9, 0x02}},
+			func(s *SuperGameBoy) bool { return s.mask == sgbMaskBlack },
+		},
+		{
+			"CHR_TRN waits for the next frame",
+			[][sgbPacketSize]uint8{{0x99, 0x01}},
+			func(s *SuperGameBoy) bool { return s.transfer == sgbCHRTRN && s.transferParam == 1 },
+		},
+		{
+			"packet with zero length is ignored",
+			[][sgbPacketSize]uint8{withCommand(0x00, colors)},
+			func(s *SuperGameBoy) bool { return s.palettes[0] == sgbDefaultPalette },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := sgbEmulator(t)
+			for _, p := range tt.packets {
+				sendPacket(e, p, 0)
+			}
+			if !tt.check(e.MMU.SGB) {
+				t.Errorf("unexpected state after %X", tt.packets)
+			}
+		})
+	}
+}
+
+func TestSGBPacketTransfer(t *testing.T) {
+	mlt := [sgbPacketSize]uint8{0x89, 0x01}
+	tests := []struct {
+		name    string
+		sgb     bool
+		send    func(e *Emulator)
+		players int
+	}{
+		{"valid packet", true, func(e *Emulator) { sendPacket(e, mlt, 0) }, 2},
+		{"stop bit 1 discards packet", true, func(e *Emulator) { sendPacket(e, mlt, 1) }, 1},
+		{
+			"pulses without reset are ignored",
+			true,
+			func(e *Emulator) {
+				sendPacket(e, mlt, 1)
+				for i := 0; i < 8; i++ {
+					e.MMU.Write(AddrJoy, 0x20)
+					e.MMU.Write(AddrJoy, 0x30)
+				}
+			},
+			1,
+		},
+		{
+			"reset in the middle restarts the packet",
+			true,
+			func(e *Emulator) {
+				e.MMU.Write(AddrJoy, 0x00)
+				e.MMU.Write(AddrJoy, 0x30)
+				e.MMU.Write(AddrJoy, 0x10)
+				e.MMU.Write(AddrJoy, 0x30)
+				sendPacket(e, mlt, 0)
+			},
+			2,
+		},
+		{"cartridge without SGB support", false, func(e *Emulator) { sendPacket(e, mlt, 0) }, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := sgbEmulator(t)
+			e.MMU.SGB.enabled = tt.sgb
+			tt.send(e)
+			if e.MMU.SGB.players != tt.players {
+				t.Errorf("%d players, want %d", e.MMU.SGB.players, tt.players)
+			}
+		})
+	}
+}
+
+func TestSGBJoypadID(t *testing.T) {
+	tests := []struct {
+		mode uint8
+		ids  []uint8
+	}{
+		{0, []uint8{0xF, 0xF, 0xF}},
+		{1, []uint8{0xF, 0xE, 0xF}},
+		{3, []uint8{0xF, 0xE, 0xD, 0xC, 0xF}},
+	}
+	for _, tt := range tests {
+		e := sgbEmulator(t)
+		sendPacket(e, [sgbPacketSize]uint8{0x89, tt.mode}, 0)
+		for i, want := range tt.ids {
+			// Releasing P15 selects the next controller
+			if i > 0 {
+				e.MMU.Write(AddrJoy, 0x10)
+				e.MMU.Write(AddrJoy, 0x30)
+			}
+			if got := e.MMU.Read(AddrJoy) & 0xF; got != want {
+				t.Errorf("mode %d read %d: ID %X, want %X", tt.mode, i, got, want)
+			}
+		}
+	}
+}
+
+// showData sets the shades of the screen to show data as tiles, the way
+// games send data with *_TRN commands
+func showData(e *Emulator, data []uint8) {
+	shades := &e.MMU.GPU.shades
+	for tile := 0; tile < 256; tile++ {
+		for row := 0; row < 8; row++ {
+			low, high := data[tile*16+row*2], data[tile*16+row*2+1]
+			for x := 0; x < 8; x++ {
+				shade := (low>>(7-x))&1 | (high>>(7-x))&1<<1
+				shades[((tile/20)*8+row)*160+(tile%20)*8+x] = shade
+			}
+		}
+	}
+}
+
+func TestSGBTransfer(t *testing.T) {
+	e := sgbEmulator(t)
+	s := e.MMU.SGB
+	data := make([]uint8, sgbTransferSize)
+	for i := range data {
+		data[i] = uint8(i*7 + i>>8)
+	}
+	showData(e, data)
+	sendPacket(e, [sgbPacketSize]uint8{0x99, 0x01}, 0)
+	s.frameDone()
+	for i := range data {
+		if s.borderTiles[i] != 0 || s.borderTiles[sgbTransferSize+i] != data[i] {
+			t.Fatalf("CHR_TRN byte %d copied to the wrong half", i)
+		}
+	}
+	if s.transfer != 0 {
+		t.Error("transfer not finished")
+	}
+
+	// Border map entry 0 shows tile 1 of the upper half with palette 5,
+	// flipped horizontally
+	for i := range data {
+		data[i] = 0
+	}
+	data[0], data[1] = 0x81, 1<<2|1<<6
+	data[0x800+(1*16+3)*2], data[0x800+(1*16+3)*2+1] = 0x34, 0x92
+	showData(e, data)
+	sendPacket(e, [sgbPacketSize]uint8{0xA1}, 0)
+	s.frameDone()
+	if s.borderMap[0] != 0x4481 || s.borderPalettes[1][3] != 0x1234 {
+		t.Fatalf("PCT_TRN map %04X palette %04X", s.borderMap[0], s.borderPalettes[1][3])
+	}
+	for i := 0; i < 32; i++ {
+		s.borderTiles[0x81*32+i] = 0
+	}
+	// Color 3 at the right edge of the tile is shown on the left when flipped
+	s.borderTiles[0x81*32] = 0x01
+	s.borderTiles[0x81*32+1] = 0x01
+	s.render()
+	if got := e.Framebuffer()[0]; got != 0x1234 {
+		t.Errorf("border pixel %04X, want 1234", got)
+	}
+	if got := e.Framebuffer()[1]; got != s.palettes[0][0] {
+		t.Errorf("border color 0 is %04X, want %04X", got, s.palettes[0][0])
+	}
+}
+
+func TestSGBScreen(t *testing.T) {
+	tests := []struct {
+		name string
+		mask uint8
+		want func(s *SuperGameBoy) uint16
+	}{
+		{"colorized", sgbMaskNone, func(s *SuperGameBoy) uint16 { return 0x5555 }},
+		{"frozen", sgbMaskFreeze, func(s *SuperGameBoy) uint16 { return sgbDefaultPalette[0] }},
+		{"black", sgbMaskBlack, func(s *SuperGameBoy) uint16 { return 0 }},
+		{"color 0", sgbMaskColor0, func(s *SuperGameBoy) uint16 { return 0x1111 }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := sgbEmulator(t)
+			s := e.MMU.SGB
+			s.frameDone()
+			sendPacket(e, [sgbPacketSize]uint8{0x01, 0x11, 0x11, 0, 0, 0, 0, 0, 0, 0x55, 0x55}, 0)
+			sendPacket(e, [sgbPacketSize]uint8{0x31, 1, 0}, 0)
+			sendPacket(e, [sgbPacketSize]uint8{0xB9, tt.mask}, 0)
+			for i := range e.MMU.GPU.shades {
+				e.MMU.GPU.shades[i] = 1
+			}
+			s.frameDone()
+			fb := e.Framebuffer()
+			if len(fb) != SGBWidth*SGBHeight {
+				t.Fatalf("framebuffer has %d pixels", len(fb))
+			}
+			if got, want := fb[(sgbScreenY+8)*SGBWidth+sgbScreenX+8], tt.want(s); got != want {
+				t.Errorf("screen color %04X, want %04X", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package goboy
+
+const AddrKEY1 = 0xFF4D
+
+// SpeedSwitch is the KEY1 register of Color hardware. Writing bit 0 arms the
+// switch and the next STOP instruction toggles between normal and double speed.
+type SpeedSwitch struct {
+	Armed       bool
+	DoubleSpeed bool
+}
+
+func (s *SpeedSwitch) RawSet(data uint8) {
+	s.DoubleSpeed = data&Bit7 != 0
+	s.Armed = data&Bit0 != 0
+}
+
+func (s *SpeedSwitch) Set(data uint8) {
+	s.Armed = data&Bit0 != 0
+}
+
+func (s *SpeedSwitch) Get() uint8 {
+	var data uint8 = 0x7E
+	if s.DoubleSpeed {
+		data |= Bit7
+	}
+	if s.Armed {
+		data |= Bit0
+	}
+	return data
+}
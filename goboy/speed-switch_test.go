@@ -0,0 +1,81 @@
+package goboy
+
+import (
+	"testing"
+)
+
+func TestSpeedSwitch(t *testing.T) {
+	tests := []struct {
+		name        string
+		model       Model
+		key1        []uint8
+		stops       int
+		want        uint8
+		stopped     bool
+		doubleSpeed bool
+	}{
+		{"initial", ModelCGB, nil, 0, 0x7E, false, false},
+		{"armed", ModelCGB, []uint8{0x01}, 0, 0x7F, false, false},
+		{"speed bit is read only", ModelCGB, []uint8{0x80}, 0, 0x7E, false, false},
+		{"STOP without arming", ModelCGB, nil, 1, 0x7E, true, false},
+		{"switch to double speed", ModelCGB, []uint8{0x01}, 1, 0xFE, false, true},
+		{"switch back", ModelCGB, []uint8{0x01}, 2, 0xFE, true, true},
+		{"no KEY1 on DMG", ModelDMG, []uint8{0x01}, 1, 0xFF, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// STOP 0x00 twice
+			e := testEmulator(t, tt.model, 0x10, 0x00, 0x10, 0x00)
+			for _, data := range tt.key1 {
+				e.MMU.Write(AddrKEY1, data)
+			}
+			for i := 0; i < tt.stops; i++ {
+				e.StepInstruction()
+			}
+			if got := e.MMU.Read(AddrKEY1); got != tt.want {
+				t.Errorf("KEY1 read %02X, want %02X", got, tt.want)
+			}
+			if e.CPU.Stopped != tt.stopped {
+				t.Errorf("stopped %v, want %v", e.CPU.Stopped, tt.stopped)
+			}
+			if e.MMU.Speed != nil && e.MMU.Speed.DoubleSpeed != tt.doubleSpeed {
+				t.Errorf("double speed %v, want %v", e.MMU.Speed.DoubleSpeed, tt.doubleSpeed)
+			}
+		})
+	}
+}
+
+// In double speed mode the CPU and timer run twice as fast compared to the
+// display
+func TestDoubleSpeed(t *testing.T) {
+	tests := []struct {
+		name   string
+		double bool
+		want   int
+	}{
+		{"normal speed", false, CyclesPerFrame},
+		{"double speed", true, 2 * CyclesPerFrame},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// LD A,1; LDH (KEY1),A; STOP 0x00; JR -2
+			e := testEmulator(t, ModelCGB, 0x3E, 0x01, 0xE0, 0x4D, 0x10, 0x00, 0x18, 0xFE)
+			if !tt.double {
+				e.CPU.PC = 0x0106
+			}
+			e.RunFrame()
+			e.RunFrame()
+			var cycles int
+			start := e.MMU.Timer.counter
+			for !e.MMU.frameReady {
+				cycles += e.StepInstruction()
+			}
+			if cycles < tt.want-12 || cycles > tt.want+12 {
+				t.Errorf("frame took %d CPU cycles, want %d", cycles, tt.want)
+			}
+			if elapsed := uint16(cycles) - (e.MMU.Timer.counter - start); elapsed != 0 {
+				t.Errorf("timer counter is off from CPU cycles by %d", elapsed)
+			}
+		})
+	}
+}
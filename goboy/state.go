@@ -0,0 +1,612 @@
+package goboy
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Save state format is a magic string and version followed by state of
+// each component in fixed order. Version must be bumped whenever the
+// layout changes.
+const (
+	stateMagic   = "GOBOYSS\x00"
+	stateVersion = 14
+)
+
+// ErrInvalidState is returned when loading data that isn't a save state
+var ErrInvalidState = errors.New("invalid save state")
+
+// ErrStateMismatch is returned when loading a save state of a different
+// cartridge or hardware model
+var ErrStateMismatch = errors.New("save state belongs to a different cartridge")
+
+// StateVersionError is returned when loading a save state written in an
+// unsupported format version
+type StateVersionError struct {
+	Version uint32
+}
+
+func (e *StateVersionError) Error() string {
+	return fmt.Sprintf("unsupported save state version %d, expected %d", e.Version, stateVersion)
+}
+
+// stateWriter writes values in little endian, the first error is kept and
+// all writes after it are ignored
+type stateWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (s *stateWriter) write(data interface{}) {
+	if s.err != nil {
+		return
+	}
+	s.err = binary.Write(s.w, binary.LittleEndian, data)
+}
+
+func (s *stateWriter) writeInt(val int) {
+	s.write(int64(val))
+}
+
+// stateReader reads values written by stateWriter, the first error is kept
+// and all reads after it are ignored
+type stateReader struct {
+	r   io.Reader
+	err error
+}
+
+func (s *stateReader) read(data interface{}) {
+	if s.err != nil {
+		return
+	}
+	s.err = binary.Read(s.r, binary.LittleEndian, data)
+}
+
+func (s *stateReader) readInt() int {
+	var val int64
+	s.read(&val)
+	return int(val)
+}
+
+// SaveState writes the state of the whole machine into w
+func (cpu *CPU) SaveState(w io.Writer) error {
+	s := &stateWriter{w: w}
+	s.write([]byte(stateMagic))
+	s.write(uint32(stateVersion))
+	s.write(cpu.Memory.Cartridge.Header.GlobalChecksum)
+	s.write(cpu.Memory.Cartridge.Header.HeaderChecksum)
+	s.write(uint8(cpu.Memory.Model))
+	cpu.saveState(s)
+	cpu.Memory.saveState(s)
+	return s.err
+}
+
+// LoadState restores machine state written by SaveState. The state is left
+// untouched if loading fails.
+func (cpu *CPU) LoadState(r io.Reader) error {
+	var backup bytes.Buffer
+	if err := cpu.SaveState(&backup); err != nil {
+		return err
+	}
+	err := cpu.restoreState(r)
+	if err != nil {
+		cpu.restoreState(&backup)
+	}
+	return err
+}
+
+func (cpu *CPU) restoreState(r io.Reader) error {
+	s := &stateReader{r: r}
+	magic := make([]byte, len(stateMagic))
+	s.read(magic)
+	if s.err != nil || string(magic) != stateMagic {
+		return ErrInvalidState
+	}
+	var (
+		version        uint32
+		globalChecksum uint16
+		headerChecksum uint8
+		model          uint8
+	)
+	s.read(&version)
+	if s.err == nil && version != stateVersion {
+		return &StateVersionError{Version: version}
+	}
+	s.read(&globalChecksum)
+	s.read(&headerChecksum)
+	s.read(&model)
+	if s.err != nil {
+		return invalidState(s.err)
+	}
+	header := cpu.Memory.Cartridge.Header
+	if globalChecksum != header.GlobalChecksum || headerChecksum != header.HeaderChecksum {
+		return ErrStateMismatch
+	}
+	// Memory sizes depend on the hardware model
+	if Model(model) != cpu.Memory.Model {
+		return ErrStateMismatch
+	}
+	cpu.loadState(s)
+	cpu.Memory.loadState(s)
+	return invalidState(s.err)
+}
+
+// invalidState reports data ending in the middle of a save state as invalid
+func invalidState(err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return ErrInvalidState
+	}
+	return err
+}
+
+func (cpu *CPU) saveState(s *stateWriter) {
+	s.write([]uint8{cpu.A, cpu.B, cpu.C, cpu.D, cpu.E, cpu.H, cpu.L, cpu.F()})
+	s.write([]uint16{cpu.SP, cpu.PC})
+	s.write([]bool{cpu.Halt, cpu.IME, cpu.enableIME, cpu.haltBug, cpu.Stopped})
+}
+
+func (cpu *CPU) loadState(s *stateReader) {
+	var (
+		regs  [8]uint8
+		words [2]uint16
+		flags [5]bool
+	)
+	s.read(&regs)
+	s.read(&words)
+	s.read(&flags)
+	cpu.A, cpu.B, cpu.C, cpu.D, cpu.E, cpu.H, cpu.L = regs[0], regs[1], regs[2], regs[3], regs[4], regs[5], regs[6]
+	cpu.SetF(regs[7])
+	cpu.SP, cpu.PC = words[0], words[1]
+	cpu.Halt, cpu.IME, cpu.enableIME, cpu.haltBug, cpu.Stopped = flags[0], flags[1], flags[2], flags[3], flags[4]
+}
+
+func (mmu *MMU) saveState(s *stateWriter) {
+	// Mapped registers depend on the mode of Color hardware
+	s.write(mmu.cgb)
+	for _, reg := range mmu.io {
+		if reg != nil {
+			s.write(reg.Get())
+		}
+	}
+	s.write(mmu.BootEnabled)
+	s.write(mmu.wram)
+	s.write(mmu.hram[:])
+	mmu.GPU.saveState(s)
+	mmu.APU.saveState(s)
+	mmu.DMA.saveState(s)
+	mmu.Timer.saveState(s)
+	mmu.Serial.saveState(s)
+	if mmu.HDMA != nil {
+		mmu.HDMA.saveState(s)
+	}
+	if mmu.SGB != nil {
+		mmu.SGB.saveState(s)
+	}
+	if mbc, ok := mmu.Cartridge.MBC.(stateful); ok {
+		mbc.saveState(s)
+	}
+}
+
+func (mmu *MMU) loadState(s *stateReader) {
+	var color bool
+	s.read(&color)
+	if mmu.Model == ModelCGB {
+		mmu.setColorMode(color)
+	}
+	for _, reg := range mmu.io {
+		if reg == nil {
+			continue
+		}
+		var val uint8
+		s.read(&val)
+		switch reg := reg.(type) {
+		case *Joypad:
+			// Only the selected key group is stored, key state comes from input
+			reg.Set(val)
+		default:
+			reg.RawSet(val)
+		}
+	}
+	s.read(&mmu.BootEnabled)
+	mmu.mapBootROM()
+	s.read(mmu.wram)
+	s.read(mmu.hram[:])
+	mmu.GPU.loadState(s)
+	mmu.APU.loadState(s)
+	mmu.DMA.loadState(s)
+	mmu.Timer.loadState(s)
+	mmu.Serial.loadState(s)
+	if mmu.HDMA != nil {
+		mmu.HDMA.loadState(s)
+	}
+	if mmu.SGB != nil {
+		mmu.SGB.loadState(s)
+	}
+	if mbc, ok := mmu.Cartridge.MBC.(stateful); ok {
+		mbc.loadState(s)
+	}
+}
+
+func (d *Display) saveState(s *stateWriter) {
+	s.write(d.VRAM[:])
+	s.write(d.oam[:])
+	s.writeInt(d.cycles)
+	s.writeInt(d.row)
+	s.writeInt(d.windowLine)
+	s.write([]bool{d.statLine, d.lcdOn, d.skipFrame})
+	s.write(d.spriteBuffer[:])
+	s.write(d.shades[:])
+	s.write(d.bgColors.ram[:])
+	s.write(d.objColors.ram[:])
+}
+
+func (d *Display) loadState(s *stateReader) {
+	s.read(d.VRAM[:])
+	s.read(d.oam[:])
+	d.cycles = s.readInt()
+	d.row = s.readInt()
+	d.windowLine = s.readInt()
+	var flags [3]bool
+	s.read(&flags)
+	d.statLine, d.lcdOn, d.skipFrame = flags[0], flags[1], flags[2]
+	s.read(d.spriteBuffer[:])
+	s.read(d.shades[:])
+	s.read(d.bgColors.ram[:])
+	s.read(d.objColors.ram[:])
+	if d.cycles < 0 || d.cycles >= CyclesPerFrame {
+		d.cycles, d.row = 0, 0
+	}
+	if d.windowLine < 0 || d.windowLine >= ScreenHeight {
+		d.windowLine = 0
+	}
+}
+
+func (dma *OAMDMA) saveState(s *stateWriter) {
+	s.write([]bool{dma.active, dma.started, dma.busy})
+	s.write(dma.source)
+	s.writeInt(dma.index)
+	s.writeInt(dma.timer)
+}
+
+func (dma *OAMDMA) loadState(s *stateReader) {
+	var flags [3]bool
+	s.read(&flags)
+	dma.active, dma.started, dma.busy = flags[0], flags[1], flags[2]
+	s.read(&dma.source)
+	dma.index = s.readInt()
+	dma.timer = s.readInt()
+	if dma.index < 0 || dma.index >= OAMSize {
+		dma.index, dma.active, dma.busy = 0, false, false
+	}
+}
+
+func (t *Timer) saveState(s *stateWriter) {
+	s.write(t.counter)
+	s.write([]uint8{t.tima, t.tma, t.tac})
+	s.write([]bool{t.overflow, t.reloaded})
+}
+
+func (t *Timer) loadState(s *stateReader) {
+	var (
+		regs  [3]uint8
+		flags [2]bool
+	)
+	s.read(&t.counter)
+	s.read(&regs)
+	s.read(&flags)
+	t.tima, t.tma, t.tac = regs[0], regs[1], regs[2]&0x7
+	t.overflow, t.reloaded = flags[0], flags[1]
+}
+
+func (sr *Serial) saveState(s *stateWriter) {
+	s.write(sr.in)
+	s.writeInt(sr.bits)
+	s.writeInt(sr.timer)
+}
+
+func (sr *Serial) loadState(s *stateReader) {
+	s.read(&sr.in)
+	sr.bits = s.readInt()
+	sr.timer = s.readInt()
+	if sr.bits < 0 || sr.bits > 8 {
+		sr.bits = 0
+	}
+}
+
+func (h *HDMA) saveState(s *stateWriter) {
+	s.write([]uint16{h.source, h.dest})
+	s.writeInt(h.blocks)
+	s.write(h.active)
+}
+
+func (h *HDMA) loadState(s *stateReader) {
+	var addrs [2]uint16
+	s.read(&addrs)
+	h.source, h.dest = addrs[0], addrs[1]
+	h.blocks = s.readInt()
+	s.read(&h.active)
+	if h.blocks < 0 || h.blocks > 0x80 {
+		h.blocks, h.active = 0, false
+	}
+}
+
+func (sgb *SuperGameBoy) saveState(s *stateWriter) {
+	s.write(sgb.joypadSelect)
+	s.write(sgb.receiving)
+	s.writeInt(sgb.bits)
+	s.write(sgb.packet[:])
+	s.writeInt(len(sgb.command))
+	s.write(sgb.command)
+	s.writeInt(sgb.players)
+	s.writeInt(sgb.player)
+	s.write(&sgb.palettes)
+	s.write(sgb.attributes[:])
+	s.writeInt(sgb.mask)
+	s.write([]uint8{sgb.transfer, sgb.transferParam})
+	s.write(sgb.borderTiles[:])
+	s.write(sgb.borderMap[:])
+	s.write(&sgb.borderPalettes)
+	s.write(sgb.screen[:])
+}
+
+func (sgb *SuperGameBoy) loadState(s *stateReader) {
+	s.read(&sgb.joypadSelect)
+	s.read(&sgb.receiving)
+	sgb.bits = s.readInt()
+	s.read(sgb.packet[:])
+	length := s.readInt()
+	if length < 0 || length > 7*sgbPacketSize {
+		length = 0
+	}
+	sgb.command = make([]uint8, length)
+	s.read(sgb.command)
+	sgb.players = s.readInt()
+	sgb.player = s.readInt()
+	s.read(&sgb.palettes)
+	s.read(sgb.attributes[:])
+	sgb.mask = s.readInt()
+	var transfer [2]uint8
+	s.read(&transfer)
+	sgb.transfer, sgb.transferParam = transfer[0], transfer[1]
+	s.read(sgb.borderTiles[:])
+	s.read(sgb.borderMap[:])
+	s.read(&sgb.borderPalettes)
+	s.read(sgb.screen[:])
+	if sgb.players < 1 || sgb.player >= sgb.players {
+		sgb.players, sgb.player = 1, 0
+	}
+	if sgb.bits < 0 || sgb.bits > sgbPacketSize*8 {
+		sgb.bits, sgb.receiving = 0, false
+	}
+	for i := range sgb.attributes {
+		sgb.attributes[i] &= 0x3
+	}
+	sgb.render()
+}
+
+func (a *APU) saveState(s *stateWriter) {
+	s.write(a.powered)
+	s.write(a.regs[:])
+	a.ch1.saveState(s)
+	a.ch2.saveState(s)
+	a.ch3.saveState(s)
+	a.ch4.saveState(s)
+	s.writeInt(a.frameSeqTimer)
+	s.write(a.frameStep)
+}
+
+func (a *APU) loadState(s *stateReader) {
+	s.read(&a.powered)
+	s.read(a.regs[:])
+	a.ch1.loadState(s)
+	a.ch2.loadState(s)
+	a.ch3.loadState(s)
+	a.ch4.loadState(s)
+	a.frameSeqTimer = s.readInt()
+	s.read(&a.frameStep)
+}
+
+func (l *lengthCounter) saveState(s *stateWriter) {
+	s.write(l.enabled)
+	s.writeInt(l.counter)
+}
+
+func (l *lengthCounter) loadState(s *stateReader) {
+	s.read(&l.enabled)
+	l.counter = s.readInt()
+}
+
+func (e *envelope) saveState(s *stateWriter) {
+	s.write([]uint8{e.initial, e.period, e.volume, e.timer})
+	s.write(e.add)
+}
+
+func (e *envelope) loadState(s *stateReader) {
+	var regs [4]uint8
+	s.read(&regs)
+	e.initial, e.period, e.volume, e.timer = regs[0], regs[1], regs[2], regs[3]
+	s.read(&e.add)
+}
+
+func (c *squareChannel) saveState(s *stateWriter) {
+	s.write([]bool{c.enabled, c.sweepNegate, c.sweepEnabled})
+	s.write([]uint8{c.duty, c.dutyStep, c.sweepPeriod, c.sweepShift, c.sweepTimer})
+	s.write([]uint16{c.frequency, c.shadowFreq})
+	s.writeInt(c.timer)
+	c.length.saveState(s)
+	c.env.saveState(s)
+}
+
+func (c *squareChannel) loadState(s *stateReader) {
+	var (
+		flags [3]bool
+		regs  [5]uint8
+		words [2]uint16
+	)
+	s.read(&flags)
+	s.read(&regs)
+	s.read(&words)
+	c.enabled, c.sweepNegate, c.sweepEnabled = flags[0], flags[1], flags[2]
+	c.duty, c.dutyStep, c.sweepPeriod, c.sweepShift, c.sweepTimer = regs[0]&0x3, regs[1]&0x7, regs[2], regs[3], regs[4]
+	c.frequency, c.shadowFreq = words[0], words[1]
+	c.timer = s.readInt()
+	c.length.loadState(s)
+	c.env.loadState(s)
+}
+
+func (c *waveChannel) saveState(s *stateWriter) {
+	s.write([]bool{c.enabled, c.dacEnabled})
+	s.write([]uint8{c.volumeCode, c.position, c.sample})
+	s.write(c.frequency)
+	s.writeInt(c.timer)
+	s.write(c.ram[:])
+	c.length.saveState(s)
+}
+
+func (c *waveChannel) loadState(s *stateReader) {
+	var (
+		flags [2]bool
+		regs  [3]uint8
+	)
+	s.read(&flags)
+	s.read(&regs)
+	c.enabled, c.dacEnabled = flags[0], flags[1]
+	c.volumeCode, c.position, c.sample = regs[0]&0x3, regs[1]&0x1F, regs[2]
+	s.read(&c.frequency)
+	c.timer = s.readInt()
+	s.read(c.ram[:])
+	c.length.loadState(s)
+}
+
+func (c *noiseChannel) saveState(s *stateWriter) {
+	s.write([]bool{c.enabled, c.widthMode})
+	s.write([]uint8{c.clockShift, c.divisorCode})
+	s.write(c.lfsr)
+	s.writeInt(c.timer)
+	c.length.saveState(s)
+	c.env.saveState(s)
+}
+
+func (c *noiseChannel) loadState(s *stateReader) {
+	var (
+		flags [2]bool
+		regs  [2]uint8
+	)
+	s.read(&flags)
+	s.read(&regs)
+	c.enabled, c.widthMode = flags[0], flags[1]
+	c.clockShift, c.divisorCode = regs[0], regs[1]&0x7
+	s.read(&c.lfsr)
+	c.timer = s.readInt()
+	c.length.loadState(s)
+	c.env.loadState(s)
+}
+
+// stateful is implemented by cartridge mappers which have state to be
+// stored in save states
+type stateful interface {
+	saveState(s *stateWriter)
+	loadState(s *stateReader)
+}
+
+func (mbc *MBC0) saveState(s *stateWriter) {
+	s.write(mbc.ram)
+}
+
+func (mbc *MBC0) loadState(s *stateReader) {
+	s.read(mbc.ram)
+}
+
+func (mbc *MBC1) saveState(s *stateWriter) {
+	s.write([]uint8{mbc.ramEnabled, mbc.romBankNumber, mbc.ramBankNumber, mbc.romModeSelect})
+	s.write(mbc.ram)
+}
+
+func (mbc *MBC1) loadState(s *stateReader) {
+	var regs [4]uint8
+	s.read(&regs)
+	mbc.ramEnabled, mbc.romBankNumber, mbc.ramBankNumber, mbc.romModeSelect = regs[0], regs[1], regs[2], regs[3]
+	s.read(mbc.ram)
+}
+
+func (mbc *MBC2) saveState(s *stateWriter) {
+	s.write([]uint8{mbc.ramEnabled, mbc.romBankNumber})
+	s.write(mbc.ram[:])
+}
+
+func (mbc *MBC2) loadState(s *stateReader) {
+	var regs [2]uint8
+	s.read(&regs)
+	mbc.ramEnabled, mbc.romBankNumber = regs[0], regs[1]
+	s.read(mbc.ram[:])
+}
+
+func (mbc *MBC3) saveState(s *stateWriter) {
+	s.write([]uint8{mbc.ramEnabled, mbc.romBankNumber, mbc.ramBankNumber, mbc.latch})
+	s.write(mbc.ram)
+	mbc.RTC.saveState(s)
+}
+
+func (mbc *MBC3) loadState(s *stateReader) {
+	var regs [4]uint8
+	s.read(&regs)
+	mbc.ramEnabled, mbc.romBankNumber, mbc.ramBankNumber, mbc.latch = regs[0], regs[1], regs[2], regs[3]
+	s.read(mbc.ram)
+	mbc.RTC.loadState(s)
+}
+
+func (mbc *MBC5) saveState(s *stateWriter) {
+	s.write([]uint8{mbc.ramEnabled, mbc.ramBankNumber})
+	s.write(mbc.romBankNumber)
+	s.write(mbc.rumble)
+	s.write(mbc.ram)
+}
+
+func (mbc *MBC5) loadState(s *stateReader) {
+	var (
+		regs   [2]uint8
+		rumble bool
+	)
+	s.read(&regs)
+	mbc.ramEnabled, mbc.ramBankNumber = regs[0], regs[1]
+	s.read(&mbc.romBankNumber)
+	s.read(&rumble)
+	s.read(mbc.ram)
+	mbc.setRumble(rumble)
+}
+
+func (rtc *RTC) saveState(s *stateWriter) {
+	rtc.sync()
+	s.write([]uint8{rtc.seconds, rtc.minutes, rtc.hours})
+	s.write(rtc.days)
+	s.write([]bool{rtc.halt, rtc.dayCarry})
+	s.write(rtc.latched[:])
+	var last int64
+	if !rtc.last.IsZero() {
+		last = rtc.last.UnixNano()
+	}
+	s.write(last)
+}
+
+func (rtc *RTC) loadState(s *stateReader) {
+	var (
+		regs  [3]uint8
+		flags [2]bool
+		last  int64
+	)
+	s.read(&regs)
+	s.read(&rtc.days)
+	s.read(&flags)
+	s.read(rtc.latched[:])
+	s.read(&last)
+	rtc.seconds, rtc.minutes, rtc.hours = regs[0], regs[1], regs[2]
+	rtc.halt, rtc.dayCarry = flags[0], flags[1]
+	rtc.last = time.Time{}
+	if last != 0 {
+		rtc.last = time.Unix(0, last)
+	}
+}
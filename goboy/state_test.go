@@ -0,0 +1,209 @@
+package goboy
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// Fills WRAM with an increasing counter: LD HL,0xC000; INC A; LD (HL+),A; JR -4
+var fillProgram = []byte{0x21, 0x00, 0xC0, 0x3C, 0x22, 0x18, 0xFC}
+
+func saveState(t *testing.T, e *Emulator) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := e.SaveState(&buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestStateRoundTrip(t *testing.T) {
+	for _, model := range []Model{ModelDMG, ModelCGB, ModelSGB} {
+		t.Run(model.String(), func(t *testing.T) {
+			e := testEmulator(t, model, fillProgram...)
+			runInstructions(e, 5000)
+			state := saveState(t, e)
+			wram := e.MMU.Read(0xC010)
+			runInstructions(e, 5000)
+			want := saveState(t, e)
+
+			// Running the same instructions after loading ends up in
+			// exactly the same state
+			if err := e.LoadState(bytes.NewReader(state)); err != nil {
+				t.Fatal(err)
+			}
+			if got := saveState(t, e); !bytes.Equal(got, state) {
+				t.Fatal("loaded state differs from saved state")
+			}
+			runInstructions(e, 5000)
+			if got := saveState(t, e); !bytes.Equal(got, want) {
+				t.Error("state after running from loaded state differs")
+			}
+
+			// Loading into a fresh emulator gives the same machine
+			fresh := testEmulator(t, model, fillProgram...)
+			if err := fresh.LoadState(bytes.NewReader(state)); err != nil {
+				t.Fatal(err)
+			}
+			if got := fresh.MMU.Read(0xC010); got != wram {
+				t.Errorf("WRAM read %02X, want %02X", got, wram)
+			}
+			if got := saveState(t, fresh); !bytes.Equal(got, state) {
+				t.Error("state loaded into a new emulator differs from saved state")
+			}
+		})
+	}
+}
+
+func TestStateMapper(t *testing.T) {
+	img := testROM(CART_MBC5_RAM_BATTERY, Banks_16, ExtRAM32KB)
+	e := NewEmulator(loadTestCartridge(t, img))
+	e.MMU.Write(0x0000, 0x0A)
+	e.MMU.Write(0x4000, 0x02)
+	e.MMU.Write(0xA123, 0x77)
+	e.MMU.Write(0x2000, 0x05)
+	state := saveState(t, e)
+
+	e.MMU.Write(0xA123, 0x00)
+	e.MMU.Write(0x2000, 0x09)
+	e.MMU.Write(0x4000, 0x00)
+	if err := e.LoadState(bytes.NewReader(state)); err != nil {
+		t.Fatal(err)
+	}
+	if got := romBank(e.Cartridge); got != 5 {
+		t.Errorf("ROM bank %d, want 5", got)
+	}
+	if got := e.MMU.Read(0xA123); got != 0x77 {
+		t.Errorf("RAM read %02X, want 77", got)
+	}
+}
+
+func TestStateLoadErrors(t *testing.T) {
+	e := testEmulator(t, ModelDMG, fillProgram...)
+	runInstructions(e, 1000)
+	state := saveState(t, e)
+	other := testROM(CART_ROM_ONLY, Banks_0, ExtRAMNone)
+	other[0x134] = 'X'
+	fixChecksums(other)
+
+	tests := []struct {
+		name   string
+		data   func() []byte
+		target func() *Emulator
+		check  func(err error) bool
+	}{
+		{
+			"empty",
+			func() []byte { return nil },
+			nil,
+			func(err error) bool { return err == ErrInvalidState },
+		},
+		{
+			"not a save state",
+			func() []byte { return []byte("something else entirely") },
+			nil,
+			func(err error) bool { return err == ErrInvalidState },
+		},
+		{
+			"unsupported version",
+			func() []byte {
+				data := append([]byte{}, state...)
+				binary.LittleEndian.PutUint32(data[len(stateMagic):], stateVersion+1)
+				return data
+			},
+			nil,
+			func(err error) bool {
+				verr, ok := err.(*StateVersionError)
+				return ok && verr.Version == stateVersion+1
+			},
+		},
+		{
+			"different cartridge",
+			func() []byte { return state },
+			func() *Emulator { return NewEmulator(loadTestCartridge(t, other)) },
+			func(err error) bool { return err == ErrStateMismatch },
+		},
+		{
+			"different model",
+			func() []byte { return state },
+			func() *Emulator { return testEmulator(t, ModelCGB, fillProgram...) },
+			func(err error) bool { return err == ErrStateMismatch },
+		},
+		{
+			"truncated header",
+			func() []byte { return state[:len(stateMagic)+2] },
+			nil,
+			func(err error) bool { return err == ErrInvalidState },
+		},
+		{
+			"truncated in CPU state",
+			func() []byte { return state[:len(stateMagic)+12] },
+			nil,
+			func(err error) bool { return err == ErrInvalidState },
+		},
+		{
+			"truncated in memory",
+			func() []byte { return state[:len(state)/2] },
+			nil,
+			func(err error) bool { return err == ErrInvalidState },
+		},
+		{
+			"last byte missing",
+			func() []byte { return state[:len(state)-1] },
+			nil,
+			func(err error) bool { return err == ErrInvalidState },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := testEmulator(t, ModelDMG, fillProgram...)
+			if tt.target != nil {
+				target = tt.target()
+			}
+			runInstructions(target, 3000)
+			before := saveState(t, target)
+			err := target.LoadState(bytes.NewReader(tt.data()))
+			if !tt.check(err) {
+				t.Fatalf("unexpected error %v", err)
+			}
+			// Failed load leaves the machine as it was
+			if after := saveState(t, target); !bytes.Equal(before, after) {
+				t.Error("state changed by a failed load")
+			}
+		})
+	}
+}
+
+// Values out of range in a corrupted save state are reset instead of
+// crashing the emulator later
+func TestStateLoadOutOfRange(t *testing.T) {
+	e := testEmulator(t, ModelCGB, fillProgram...)
+	mmu, d, apu := e.MMU, e.MMU.GPU, e.MMU.APU
+	d.cycles, d.windowLine = -1, 1000
+	mmu.DMA.active, mmu.DMA.index = true, OAMSize+1
+	mmu.HDMA.active, mmu.HDMA.blocks = true, 1<<40
+	apu.ch1.duty, apu.ch1.dutyStep = 0xFF, 0xFF
+	apu.ch3.volumeCode, apu.ch3.position = 0xFF, 0xFF
+	apu.ch4.divisorCode = 0xFF
+	state := saveState(t, e)
+
+	fresh := testEmulator(t, ModelCGB, fillProgram...)
+	if err := fresh.LoadState(bytes.NewReader(state)); err != nil {
+		t.Fatal(err)
+	}
+	mmu, d, apu = fresh.MMU, fresh.MMU.GPU, fresh.MMU.APU
+	if d.cycles != 0 || d.row != 0 || d.windowLine != 0 {
+		t.Errorf("display cycles %d row %d window line %d", d.cycles, d.row, d.windowLine)
+	}
+	if mmu.DMA.active || mmu.DMA.index != 0 {
+		t.Errorf("OAM DMA active %v at %d", mmu.DMA.active, mmu.DMA.index)
+	}
+	if mmu.HDMA.active || mmu.HDMA.blocks != 0 {
+		t.Errorf("VRAM DMA active %v with %d blocks", mmu.HDMA.active, mmu.HDMA.blocks)
+	}
+	if apu.ch1.duty > 3 || apu.ch1.dutyStep > 7 || apu.ch3.volumeCode > 3 || apu.ch3.position > 0x1F || apu.ch4.divisorCode > 7 {
+		t.Error("sound channel state out of range")
+	}
+	fresh.RunFrame()
+}
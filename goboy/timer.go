@@ -0,0 +1,132 @@
+package goboy
+
+// timerBits maps TAC clock select to the bit of the internal counter whose
+// falling edge increments TIMA
+var timerBits = [4]uint{9, 3, 5, 7}
+
+const tacEnable = Bit2
+
+// Timer is the DIV/TIMA unit. DIV is the upper byte of a 16-bit counter
+// incremented every cycle and TIMA is clocked by the falling edge of the
+// counter bit selected by TAC.
+type Timer struct {
+	mmu     *MMU
+	counter uint16
+	tima    uint8
+	tma     uint8
+	tac     uint8
+	// TIMA overflowed during the previous machine cycle and is reloaded
+	// from TMA on the next one
+	overflow bool
+	// TIMA was reloaded during the current machine cycle
+	reloaded bool
+}
+
+// Run advances the timer by the given amount of cycles, one machine cycle at a time
+func (t *Timer) Run(cycles int) {
+	for ; cycles > 0; cycles -= 4 {
+		t.reloaded = false
+		if t.overflow {
+			t.overflow = false
+			t.reloaded = true
+			t.tima = t.tma
+			t.mmu.requestInterrupt(TimerInt)
+		}
+		t.setCounter(t.counter + 4)
+	}
+}
+
+func (t *Timer) signal() bool {
+	return t.tac&tacEnable != 0 && t.counter&(1<<timerBits[t.tac&0x3]) != 0
+}
+
+// setCounter sets the internal counter and increments TIMA if the
+// timer signal goes from high to low
+func (t *Timer) setCounter(counter uint16) {
+	old := t.signal()
+	t.counter = counter
+	if old && !t.signal() {
+		t.increment()
+	}
+}
+
+func (t *Timer) setTAC(tac uint8) {
+	old := t.signal()
+	t.tac = tac & 0x7
+	if old && !t.signal() {
+		t.increment()
+	}
+}
+
+func (t *Timer) increment() {
+	t.tima++
+	if t.tima == 0 {
+		t.overflow = true
+	}
+}
+
+func (t *Timer) setTIMA(tima uint8) {
+	// Writes on the cycle TIMA is reloaded are ignored
+	if t.reloaded {
+		return
+	}
+	// Writing during the delay cancels the reload and the interrupt
+	t.overflow = false
+	t.tima = tima
+}
+
+func (t *Timer) setTMA(tma uint8) {
+	t.tma = tma
+	if t.reloaded {
+		t.tima = tma
+	}
+}
+
+// timerRegister exposes one of the timer registers to the MMU
+type timerRegister struct {
+	timer *Timer
+	addr  uint16
+}
+
+func (r timerRegister) RawSet(data uint8) {
+	t := r.timer
+	switch r.addr {
+	case AddrDIV:
+		t.counter = uint16(data) << 8
+	case AddrTIMA:
+		t.tima = data
+	case AddrTMA:
+		t.tma = data
+	case AddrTAC:
+		t.tac = data & 0x7
+	}
+}
+
+func (r timerRegister) Set(data uint8) {
+	t := r.timer
+	switch r.addr {
+	case AddrDIV:
+		t.setCounter(0)
+	case AddrTIMA:
+		t.setTIMA(data)
+	case AddrTMA:
+		t.setTMA(data)
+	case AddrTAC:
+		t.setTAC(data)
+	}
+}
+
+func (r timerRegister) Get() uint8 {
+	t := r.timer
+	switch r.addr {
+	case AddrDIV:
+		return uint8(t.counter >> 8)
+	case AddrTIMA:
+		return t.tima
+	case AddrTMA:
+		return t.tma
+	case AddrTAC:
+		return t.tac | 0xF8
+	}
+	return 0xFF
+}
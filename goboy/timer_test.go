@@ -0,0 +1,174 @@
+package goboy
+
+import (
+	"testing"
+)
+
+// testTimer returns a DMG with the timer counter cleared and TIMA, TMA
+// and IF set to 0
+func testTimer(t *testing.T, tac uint8) *Emulator {
+	t.Helper()
+	e := testEmulator(t, ModelDMG, 0x18, 0xFE)
+	e.MMU.Write(AddrTAC, 0)
+	e.MMU.Write(AddrDIV, 0)
+	e.MMU.Write(AddrTIMA, 0)
+	e.MMU.Write(AddrTMA, 0)
+	e.MMU.Write(AddrTAC, tac)
+	e.MMU.Write(AddrIF, 0)
+	return e
+}
+
+func timerInterrupt(e *Emulator) bool {
+	return e.MMU.Read(AddrIF)&(1<<TimerInt) != 0
+}
+
+func TestTimerFrequency(t *testing.T) {
+	tests := []struct {
+		tac  uint8
+		want uint8
+	}{
+		{0x04, 4},
+		// Overflowed and was reloaded with TMA of 0
+		{0x05, 0},
+		{0x06, 64},
+		{0x07, 16},
+		{0x00, 0},
+		{0x03, 0},
+		{0xFD, 0},
+	}
+	for _, tt := range tests {
+		e := testTimer(t, tt.tac)
+		e.MMU.Timer.Run(4096)
+		if got := e.MMU.Read(AddrTIMA); got != tt.want {
+			t.Errorf("TAC %02X: TIMA %d after 4096 cycles, want %d", tt.tac, got, tt.want)
+		}
+		if got := e.MMU.Read(AddrTAC); got != tt.tac|0xF8 {
+			t.Errorf("TAC %02X read back as %02X", tt.tac, got)
+		}
+	}
+}
+
+func TestTimerOverflowInterrupt(t *testing.T) {
+	// TAC 5 overflows TIMA after 4096 cycles, the interrupt comes a
+	// machine cycle later
+	e := testTimer(t, 0x05)
+	e.MMU.Timer.Run(4096)
+	if timerInterrupt(e) {
+		t.Error("interrupt requested on the overflow cycle")
+	}
+	e.MMU.Timer.Run(4)
+	if !timerInterrupt(e) {
+		t.Error("no interrupt after overflow")
+	}
+}
+
+func TestTimerDIV(t *testing.T) {
+	tests := []struct {
+		cycles int
+		want   uint8
+	}{
+		{0, 0},
+		{252, 0},
+		{256, 1},
+		{256 * 0x80, 0x80},
+		{256*0x100 - 4, 0xFF},
+		{256 * 0x100, 0},
+	}
+	for _, tt := range tests {
+		e := testTimer(t, 0)
+		e.MMU.Timer.Run(tt.cycles)
+		if got := e.MMU.Read(AddrDIV); got != tt.want {
+			t.Errorf("DIV %02X after %d cycles, want %02X", got, tt.cycles, tt.want)
+		}
+		// Any write resets the whole counter
+		e.MMU.Write(AddrDIV, 0x55)
+		e.MMU.Timer.Run(252)
+		if got := e.MMU.Read(AddrDIV); got != 0 {
+			t.Errorf("DIV %02X 252 cycles after reset, want 0", got)
+		}
+	}
+}
+
+// Writes to DIV and TAC that turn the timer signal from high to low
+// increment TIMA like a normal falling edge
+func TestTimerGlitches(t *testing.T) {
+	tests := []struct {
+		name   string
+		tac    uint8
+		cycles int
+		write  bankWrite
+		want   uint8
+	}{
+		{"DIV reset with bit high", 0x05, 8, bankWrite{AddrDIV, 0}, 1},
+		{"DIV reset with bit low", 0x05, 4, bankWrite{AddrDIV, 0}, 0},
+		{"DIV reset with slow clock", 0x04, 512, bankWrite{AddrDIV, 0}, 1},
+		{"DIV reset with slow clock bit low", 0x04, 256, bankWrite{AddrDIV, 0}, 0},
+		{"disable with bit high", 0x05, 8, bankWrite{AddrTAC, 0x01}, 1},
+		{"disable with bit low", 0x05, 4, bankWrite{AddrTAC, 0x01}, 0},
+		{"select low bit", 0x05, 8, bankWrite{AddrTAC, 0x06}, 1},
+		{"select high bit", 0x05, 40, bankWrite{AddrTAC, 0x06}, 0},
+		{"enable with bit high", 0x01, 8, bankWrite{AddrTAC, 0x05}, 0},
+		{"TIMA write isn't a glitch", 0x05, 8, bankWrite{AddrTMA, 0x20}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := testTimer(t, tt.tac)
+			e.MMU.Timer.Run(tt.cycles)
+			before := e.MMU.Read(AddrTIMA)
+			e.MMU.Write(tt.write.addr, tt.write.data)
+			if got := e.MMU.Read(AddrTIMA) - before; got != tt.want {
+				t.Errorf("TIMA incremented by %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+// TIMA reads 0 for one machine cycle after overflowing before it's reloaded
+// from TMA and the interrupt is requested
+func TestTimerReload(t *testing.T) {
+	tests := []struct {
+		name string
+		// Writes during the cycle TIMA reads 0
+		delayWrites []bankWrite
+		// Writes during the cycle TIMA is reloaded
+		reloadWrites []bankWrite
+		tima         uint8
+		interrupt    bool
+	}{
+		{"reload", nil, nil, 0x10, true},
+		{"TIMA write cancels reload", []bankWrite{{AddrTIMA, 0x33}}, nil, 0x33, false},
+		{"TMA write before reload", []bankWrite{{AddrTMA, 0x44}}, nil, 0x44, true},
+		{"TIMA write on reload is ignored", nil, []bankWrite{{AddrTIMA, 0x33}}, 0x10, true},
+		{"TMA write on reload goes to TIMA", nil, []bankWrite{{AddrTMA, 0x44}}, 0x44, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := testTimer(t, 0x05)
+			e.MMU.Write(AddrTMA, 0x10)
+			e.MMU.Write(AddrTIMA, 0xFF)
+			e.MMU.Timer.Run(16)
+			if got := e.MMU.Read(AddrTIMA); got != 0 || timerInterrupt(e) {
+				t.Fatalf("TIMA %02X and interrupt %v on overflow, want 00 and no interrupt", got, timerInterrupt(e))
+			}
+			for _, w := range tt.delayWrites {
+				e.MMU.Write(w.addr, w.data)
+			}
+			e.MMU.Timer.Run(4)
+			for _, w := range tt.reloadWrites {
+				e.MMU.Write(w.addr, w.data)
+			}
+			if got := e.MMU.Read(AddrTIMA); got != tt.tima {
+				t.Errorf("TIMA %02X after reload, want %02X", got, tt.tima)
+			}
+			if got := timerInterrupt(e); got != tt.interrupt {
+				t.Errorf("interrupt %v, want %v", got, tt.interrupt)
+			}
+			// Reload happens only once
+			e.MMU.Timer.Run(4)
+			e.MMU.Write(AddrTMA, 0x77)
+			if got := e.MMU.Read(AddrTIMA); got != tt.tima {
+				t.Errorf("TIMA %02X on the cycle after reload, want %02X", got, tt.tima)
+			}
+		})
+	}
+}
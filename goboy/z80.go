@@ -1,7 +1,5 @@
 package goboy
 
-import "fmt"
-
 // CPU represents internal state of the z80 cpu
 type CPU struct {
 	// General purpose registers
@@ -27,99 +25,148 @@ type CPU struct {
 	PC uint16
 
 	// Misc
-	Halt   bool
-	EI     bool
+	Halt bool
+	// Stopped CPU and PPU stay in low power mode until a joypad line goes low
+	Stopped bool
+	// Interrupt master enable
+	IME bool
+	// EI was executed and IME is set after the next instruction
+	enableIME bool
+	haltBug   bool
+	// Cycles ticked during the current instruction
+	ticked int
 	Memory *MMU
+}
+
+// interruptVectors are the addresses interrupt handlers are called at
+var interruptVectors = [5]uint16{
+	VBlankInt:  0x40,
+	LCDStatInt: 0x48,
+	TimerInt:   0x50,
+	SerialInt:  0x58,
+	JoypadInt:  0x60,
+}
 
-	Timer    int
-	DivTimer int
+// pendingInterrupts returns interrupts that are both requested and enabled
+func (cpu *CPU) pendingInterrupts() uint8 {
+	return cpu.Memory.ie.Get() & cpu.Memory.ifReg.Get() & 0x1F
 }
 
-func (cpu *CPU) HandleInterrupts() bool {
-	if !cpu.EI {
-		return false
+// HandleInterrupts wakes the CPU from HALT when an interrupt is pending and
+// dispatches it if IME is set. Returns the number of cycles the dispatch took.
+func (cpu *CPU) HandleInterrupts() int {
+	if cpu.pendingInterrupts() == 0 {
+		return 0
+	}
+	cpu.Halt = false
+	if !cpu.IME {
+		return 0
+	}
+	cpu.IME = false
+	// HALT bug after EI; HALT makes the handler return to the HALT instead
+	// of running the next opcode twice
+	ret := cpu.PC
+	if cpu.haltBug {
+		cpu.haltBug = false
+		ret--
 	}
-	ie := cpu.Memory.registers[AddrIE]
-	ifReg := cpu.Memory.registers[AddrIF]
-	ieVal := ie.Get()
-	ifRegVal := ifReg.Get()
-	for i := 0; i < 5; i++ {
+	cpu.tick(8)
+	cpu.SP--
+	cpu.write(cpu.SP, uint8(ret>>8))
+	// Pushing the high byte of PC into IE can cancel the interrupt, in which
+	// case the execution continues from 0x0000
+	pending := cpu.pendingInterrupts()
+	cpu.SP--
+	cpu.write(cpu.SP, uint8(ret))
+	cpu.PC = 0
+	for i, vector := range interruptVectors {
 		mask := uint8(1 << i)
-		if ieVal&mask != 0 && ifRegVal&mask != 0 {
-			cpu.EI = false
-			cpu.Halt = false
-			ifReg.RawSet(ifRegVal & ^mask)
-			var intVector uint16
-			switch i {
-			case VBlankInt:
-				intVector = 0x40
-				fmt.Println("VBlank")
-			case LCDStatInt:
-				intVector = 0x48
-				fmt.Println("LCDStatInt")
-			case TimerInt:
-				intVector = 0x50
-				fmt.Println("TimerInt")
-			case SerialInt:
-				intVector = 0x58
-			case JoypadInt:
-				fmt.Println("JoypadInt")
-				intVector = 0x60
-			}
-			cpu.Memory.Write(cpu.SP-1, uint8(cpu.PC>>8))
-			cpu.Memory.Write(cpu.SP-2, uint8(cpu.PC))
-			cpu.SP -= 2
-			cpu.PC = intVector
-			return true
+		if pending&mask != 0 {
+			ifReg := cpu.Memory.ifReg
+			ifReg.RawSet(ifReg.Get() & ^mask)
+			cpu.PC = vector
+			break
 		}
 	}
-	return false
+	cpu.tick(4)
+	return 20
 }
 
+// RunSingleOpcode runs one instruction, or one machine cycle when halted or
+// stopped. Memory accesses tick the rest of the system as they happen and
+// the remaining internal cycles are ticked after the instruction.
+// Returns the number of cycles taken.
 func (cpu *CPU) RunSingleOpcode() int {
+	cpu.ticked = 0
 	cycles := 4
+	if cpu.Stopped {
+		if cpu.Memory.Pad.Get()&0x0F == 0x0F {
+			cpu.tick(cycles)
+			return cycles
+		}
+		cpu.Stopped = false
+	}
 	if !cpu.Halt {
-		opcode := cpu.Memory.Read(cpu.PC)
-		cpu.PC++
+		opcode := cpu.read(cpu.PC)
+		// HALT bug causes the byte after HALT to be read twice
+		if cpu.haltBug {
+			cpu.haltBug = false
+		} else {
+			cpu.PC++
+		}
+		// EI takes effect after the instruction following it, unless it is DI
+		enableIME := cpu.enableIME
 		cycles = InstructionsTable[opcode](cpu)
+		if enableIME && cpu.enableIME {
+			cpu.enableIME = false
+			cpu.IME = true
+		}
 	}
-	cpu.updateTimers(cycles)
-	cpu.HandleInterrupts()
-
+	cpu.tick(cycles - cpu.ticked)
+	// VRAM DMA started during the instruction halts CPU until it's done.
+	// The rest of the system keeps running one machine cycle at a time, so
+	// display doesn't skip the rows drawn meanwhile.
+	for cpu.Memory.stall > 0 {
+		cpu.Memory.stall -= 4
+		cpu.tick(4)
+		cycles += 4
+	}
+	cycles += cpu.HandleInterrupts()
 	return cycles
 }
 
-func (cpu *CPU) updateTimers(cycles int) {
-	var (
-		div   = cpu.Memory.registers[AddrDIV]
-		tima  = cpu.Memory.registers[AddrTIMA]
-		tma   = cpu.Memory.registers[AddrTMA]
-		tac   = cpu.Memory.registers[AddrTAC]
-		ifReg = cpu.Memory.registers[AddrIF]
-	)
-	tacVal := tac.Get()
-	// Timer is not enabled
-	cpu.DivTimer += cycles
-	divCount := cpu.DivTimer / 256
-	cpu.DivTimer -= divCount * 256
-	div.RawSet(div.Get() + uint8(divCount))
-	if tacVal&0x04 == 0 {
+// tick advances the rest of the system by the given amount of cycles. While
+// stopped only APU keeps running so that audio pacing keeps working.
+func (cpu *CPU) tick(cycles int) {
+	if cycles <= 0 {
 		return
 	}
-	tacClock := 1024
-	if timer := tacVal & 0x3; timer > 0 {
-		tacClock = 16 << ((timer - 1) * 2)
-	}
-	cpu.Timer += cycles
-	for cpu.Timer >= tacClock {
-		cpu.Timer -= tacClock
-		tima.RawSet(tima.Get() + 1)
-		if tima.Get() == 0 {
-			ifReg.RawSet(setBit(ifReg.Get(), TimerInt))
-			tima.RawSet(tma.Get())
-			cpu.Halt = false
-		}
+	cpu.ticked += cycles
+	if cpu.Stopped {
+		cpu.Memory.APU.Run(cycles)
+		return
 	}
+	cpu.Memory.Tick(cycles)
+}
+
+// read reads from memory taking one machine cycle
+func (cpu *CPU) read(addr uint16) uint8 {
+	cpu.tick(4)
+	return cpu.Memory.Read(addr)
+}
+
+// read16 reads a little endian word taking two machine cycles, low byte is
+// read first like the hardware does
+func (cpu *CPU) read16(addr uint16) uint16 {
+	low := cpu.read(addr)
+	high := cpu.read(addr + 1)
+	return uint16(high)<<8 | uint16(low)
+}
+
+// write writes to memory taking one machine cycle
+func (cpu *CPU) write(addr uint16, data uint8) {
+	cpu.tick(4)
+	cpu.Memory.Write(addr, data)
 }
 
 // F returns flags as uint8
@@ -0,0 +1,273 @@
+package goboy
+
+import (
+	"testing"
+)
+
+func TestInterruptDispatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		prog    []byte
+		ime     bool
+		ie, ifr uint8
+		sp      uint16
+		steps   int
+		pc      uint16
+		cycles  int
+		// Return address pushed to stack, 0 when nothing is pushed
+		ret uint16
+	}{
+		{"IME off", []byte{0x00, 0x00}, false, 0x04, 0x04, 0xFFFE, 2, 0x0102, 4, 0},
+		{"dispatch", []byte{0x00}, true, 0x04, 0x04, 0xFFFE, 1, 0x0050, 24, 0x0101},
+		{"not enabled", []byte{0x00}, true, 0x01, 0x04, 0xFFFE, 1, 0x0101, 4, 0},
+		{"highest priority first", []byte{0x00}, true, 0x1F, 0x1E, 0xFFFE, 1, 0x0048, 24, 0x0101},
+		{"joypad", []byte{0x00}, true, 0x1F, 0x10, 0xFFFE, 1, 0x0060, 24, 0x0101},
+		// EI; NOP
+		{"EI takes effect after next instruction", []byte{0xFB, 0x00}, false, 0x04, 0x04, 0xFFFE, 1, 0x0101, 4, 0},
+		{"EI delay", []byte{0xFB, 0x00}, false, 0x04, 0x04, 0xFFFE, 2, 0x0050, 24, 0x0102},
+		// EI; HALT returns to HALT from the handler because of the HALT bug
+		{"EI before HALT with interrupt pending", []byte{0xFB, 0x76}, false, 0x04, 0x04, 0xFFFE, 2, 0x0050, 24, 0x0101},
+		// EI; DI; NOP
+		{"DI cancels EI", []byte{0xFB, 0xF3, 0x00}, false, 0x04, 0x04, 0xFFFE, 3, 0x0103, 4, 0},
+		// DI
+		{"DI takes effect immediately", []byte{0xF3}, true, 0x04, 0x04, 0xFFFE, 1, 0x0101, 4, 0},
+		// RETI to 0x0200
+		{"RETI enables immediately", []byte{0xD9}, false, 0x04, 0x04, 0xC000, 1, 0x0050, 36, 0x0200},
+		// Pushing PC high byte 0x01 to IE leaves only VBlank enabled
+		{"IE write during push cancels", []byte{0x00}, true, 0x04, 0x04, 0x0000, 1, 0x0000, 24, 0},
+		{"IE write during push changes interrupt", []byte{0x00}, true, 0x04, 0x05, 0x0000, 1, 0x0040, 24, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := testEmulator(t, ModelDMG, tt.prog...)
+			e.CPU.IME = tt.ime
+			e.CPU.SP = tt.sp
+			if tt.sp == 0xC000 {
+				e.MMU.Write(0xC000, 0x00)
+				e.MMU.Write(0xC001, 0x02)
+			}
+			e.MMU.Write(AddrIE, tt.ie)
+			e.MMU.Write(AddrIF, tt.ifr)
+			var cycles int
+			for i := 0; i < tt.steps; i++ {
+				cycles = e.StepInstruction()
+			}
+			if e.CPU.PC != tt.pc {
+				t.Errorf("PC %04X, want %04X", e.CPU.PC, tt.pc)
+			}
+			if cycles != tt.cycles {
+				t.Errorf("last step took %d cycles, want %d", cycles, tt.cycles)
+			}
+			if tt.ret == 0 {
+				return
+			}
+			if e.CPU.haltBug {
+				t.Error("HALT bug carried into interrupt handler")
+			}
+			if e.CPU.IME {
+				t.Error("IME set in interrupt handler")
+			}
+			ret := uint16(e.MMU.Read(e.CPU.SP)) | uint16(e.MMU.Read(e.CPU.SP+1))<<8
+			if ret != tt.ret {
+				t.Errorf("return address %04X, want %04X", ret, tt.ret)
+			}
+			if e.MMU.Read(AddrIF)&(1<<((tt.pc-0x40)/8)) != 0 {
+				t.Error("dispatched interrupt not cleared from IF")
+			}
+		})
+	}
+}
+
+func TestHalt(t *testing.T) {
+	tests := []struct {
+		name string
+		// Program starts with HALT
+		prog []byte
+		ime  bool
+		// Interrupt is already pending when HALT is run
+		pending bool
+		pc      uint16
+		a       uint8
+	}{
+		// HALT; INC A
+		{"wakes without IME", []byte{0x76, 0x3C}, false, false, 0x0102, 1},
+		{"dispatches with IME", []byte{0x76, 0x3C}, true, false, 0x0051, 0},
+		// Dispatched right after HALT, so one more NOP runs
+		{"pending with IME", []byte{0x76, 0x3C}, true, true, 0x0052, 0},
+		// HALT bug reads INC A twice
+		{"HALT bug", []byte{0x76, 0x3C}, false, true, 0x0102, 2},
+		// HALT; LD A,d8; INC A loads the opcode of LD as its operand
+		{"HALT bug with operand", []byte{0x76, 0x3E, 0x3C}, false, true, 0x0103, 0x3F},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := testEmulator(t, ModelDMG, tt.prog...)
+			// Interrupt handler at 0x50 is NOP
+			e.CPU.A = 0
+			e.CPU.IME = tt.ime
+			e.MMU.Write(AddrIF, 0)
+			e.MMU.Write(AddrIE, 1<<TimerInt)
+			if tt.pending {
+				e.MMU.Write(AddrIF, 1<<TimerInt)
+			}
+			e.StepInstruction()
+			if !tt.pending {
+				for i := 0; i < 5; i++ {
+					if !e.CPU.Halt {
+						t.Fatal("CPU woke up without an interrupt")
+					}
+					if cycles := e.StepInstruction(); cycles != 4 {
+						t.Errorf("halted step took %d cycles, want 4", cycles)
+					}
+				}
+				e.MMU.Write(AddrIF, 1<<TimerInt)
+			}
+			e.StepInstruction()
+			e.StepInstruction()
+			if e.CPU.Halt {
+				t.Fatal("CPU still halted")
+			}
+			if e.CPU.PC != tt.pc {
+				t.Errorf("PC %04X, want %04X", e.CPU.PC, tt.pc)
+			}
+			if e.CPU.A != tt.a {
+				t.Errorf("A %02X, want %02X", e.CPU.A, tt.a)
+			}
+		})
+	}
+}
+
+func TestStop(t *testing.T) {
+	tests := []struct {
+		name   string
+		p1     uint8
+		keys   Keystate
+		wakeUp bool
+	}{
+		{"button wakes up", 0x10, Keystate{A: true}, true},
+		{"direction wakes up", 0x20, Keystate{Down: true}, true},
+		{"both groups selected", 0x00, Keystate{Left: true}, true},
+		{"unselected group", 0x10, Keystate{Up: true}, false},
+		{"nothing selected", 0x30, Keystate{Start: true}, false},
+		{"no keys", 0x10, Keystate{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// STOP 0x00; INC A
+			e := testEmulator(t, ModelDMG, 0x10, 0x00, 0x3C)
+			e.CPU.A = 0
+			e.MMU.Write(AddrJoy, tt.p1)
+			e.StepInstruction()
+			if !e.CPU.Stopped || e.CPU.PC != 0x0102 {
+				t.Fatalf("stopped %v at PC %04X, want stopped at 0102", e.CPU.Stopped, e.CPU.PC)
+			}
+			// Timer and display don't run in low power mode
+			ly := e.MMU.Read(AddrLY)
+			for i := 0; i < 1000; i++ {
+				if cycles := e.StepInstruction(); cycles != 4 {
+					t.Fatalf("stopped step took %d cycles, want 4", cycles)
+				}
+			}
+			if got := e.MMU.Read(AddrDIV); got != 0 {
+				t.Errorf("DIV %02X while stopped, want 0", got)
+			}
+			if got := e.MMU.Read(AddrLY); got != ly {
+				t.Errorf("LY changed from %d to %d while stopped", ly, got)
+			}
+			e.SetInput(tt.keys)
+			e.StepInstruction()
+			if e.CPU.Stopped == tt.wakeUp {
+				t.Fatalf("stopped %v after input, want %v", e.CPU.Stopped, !tt.wakeUp)
+			}
+			if tt.wakeUp && e.CPU.A != 1 {
+				t.Errorf("A %d after waking up, want 1", e.CPU.A)
+			}
+		})
+	}
+}
+
+// busAccess is a cartridge access and the cycle it happened on, counted
+// from the start of the instruction
+type busAccess struct {
+	addr  uint16
+	write bool
+	cycle int
+}
+
+// busRecorder records accesses to switchable ROM and cartridge RAM
+type busRecorder struct {
+	Memory
+	timer    *Timer
+	start    uint16
+	accesses []busAccess
+}
+
+func (b *busRecorder) Read(addr uint16) uint8 {
+	b.accesses = append(b.accesses, busAccess{addr, false, int(b.timer.counter - b.start)})
+	return b.Memory.Read(addr)
+}
+
+func (b *busRecorder) Write(addr uint16, data uint8) {
+	b.accesses = append(b.accesses, busAccess{addr, true, int(b.timer.counter - b.start)})
+}
+
+// Every memory access ticks the rest of the system when it happens, and
+// words are accessed in the same order as the hardware does
+func TestBusTiming(t *testing.T) {
+	const (
+		code = 0x4010
+		data = 0x4100
+	)
+	r := func(addr uint16, cycle int) busAccess { return busAccess{addr, false, cycle} }
+	w := func(addr uint16, cycle int) busAccess { return busAccess{addr, true, cycle} }
+	tests := []struct {
+		name     string
+		prog     []byte
+		sp       uint16
+		accesses []busAccess
+	}{
+		{"LD BC,d16", []byte{0x01, 0x34, 0x12}, 0xFFFE, []busAccess{r(code, 4), r(code+1, 8), r(code+2, 12)}},
+		{"LD DE,d16", []byte{0x11, 0x34, 0x12}, 0xFFFE, []busAccess{r(code, 4), r(code+1, 8), r(code+2, 12)}},
+		{"LD HL,d16", []byte{0x21, 0x34, 0x12}, 0xFFFE, []busAccess{r(code, 4), r(code+1, 8), r(code+2, 12)}},
+		{"LD SP,d16", []byte{0x31, 0x34, 0x12}, 0xFFFE, []busAccess{r(code, 4), r(code+1, 8), r(code+2, 12)}},
+		{"LD A,(HL)", []byte{0x7E}, 0xFFFE, []busAccess{r(code, 4), r(data, 8)}},
+		{"INC (HL)", []byte{0x34}, 0xFFFE, []busAccess{r(code, 4), r(data, 8), w(data, 12)}},
+		{"LD A,(a16)", []byte{0xFA, 0x00, 0x41}, 0xFFFE, []busAccess{r(code, 4), r(code+1, 8), r(code+2, 12), r(data, 16)}},
+		{"LD (a16),A", []byte{0xEA, 0x00, 0x41}, 0xFFFE, []busAccess{r(code, 4), r(code+1, 8), r(code+2, 12), w(data, 16)}},
+		{"LD (a16),SP", []byte{0x08, 0x00, 0x41}, 0xFFFE, []busAccess{r(code, 4), r(code+1, 8), r(code+2, 12), w(data, 16), w(data+1, 20)}},
+		{"BIT 0,(HL)", []byte{0xCB, 0x46}, 0xFFFE, []busAccess{r(code, 4), r(code+1, 8), r(data, 12)}},
+		{"SET 0,(HL)", []byte{0xCB, 0xC6}, 0xFFFE, []busAccess{r(code, 4), r(code+1, 8), r(data, 12), w(data, 16)}},
+		{"JP a16", []byte{0xC3, 0x00, 0x41}, 0xFFFE, []busAccess{r(code, 4), r(code+1, 8), r(code+2, 12)}},
+		{"POP BC", []byte{0xC1}, data, []busAccess{r(code, 4), r(data, 8), r(data+1, 12)}},
+		{"RET", []byte{0xC9}, data, []busAccess{r(code, 4), r(data, 8), r(data+1, 12)}},
+		{"RET NZ", []byte{0xC0}, data, []busAccess{r(code, 4), r(data, 12), r(data+1, 16)}},
+		{"RETI", []byte{0xD9}, data, []busAccess{r(code, 4), r(data, 8), r(data+1, 12)}},
+		{"PUSH BC", []byte{0xC5}, data + 2, []busAccess{r(code, 4), w(data+1, 12), w(data, 16)}},
+		{"CALL a16", []byte{0xCD, 0x00, 0x41}, data + 2, []busAccess{r(code, 4), r(code+1, 8), r(code+2, 12), w(data+1, 20), w(data, 24)}},
+		{"RST 38", []byte{0xFF}, data + 2, []busAccess{r(code, 4), w(data+1, 12), w(data, 16)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img := testROM(CART_ROM_ONLY, Banks_0, ExtRAMNone)
+			copy(img[code:], tt.prog)
+			fixChecksums(img)
+			e := NewEmulator(loadTestCartridge(t, img))
+			bus := &busRecorder{Memory: e.Cartridge.MBC, timer: e.MMU.Timer}
+			e.Cartridge.MBC = bus
+			e.CPU.PC = code
+			e.CPU.SP = tt.sp
+			e.CPU.H, e.CPU.L = data>>8, data&0xFF
+			e.CPU.FZero = false
+			bus.start = e.MMU.Timer.counter
+			e.StepInstruction()
+			if len(bus.accesses) != len(tt.accesses) {
+				t.Fatalf("got accesses %+v, want %+v", bus.accesses, tt.accesses)
+			}
+			for i := range bus.accesses {
+				if bus.accesses[i] != tt.accesses[i] {
+					t.Fatalf("got accesses %+v, want %+v", bus.accesses, tt.accesses)
+				}
+			}
+		})
+	}
+}
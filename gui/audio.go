@@ -0,0 +1,62 @@
+package gui
+
+import (
+	"encoding/binary"
+	"time"
+
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// Audio plays 16-bit stereo samples through an SDL audio queue
+type Audio struct {
+	device     sdl.AudioDeviceID
+	sampleRate int
+	buffer     []byte
+}
+
+// NewAudio opens the default audio device. The sample rate of the device
+// may differ from the requested one, use SampleRate to get the actual rate.
+func NewAudio(sampleRate int) (*Audio, error) {
+	desired := &sdl.AudioSpec{
+		Freq:     int32(sampleRate),
+		Format:   sdl.AUDIO_S16LSB,
+		Channels: 2,
+		Samples:  1024,
+	}
+	var obtained sdl.AudioSpec
+	device, err := sdl.OpenAudioDevice("", false, desired, &obtained, sdl.AUDIO_ALLOW_FREQUENCY_CHANGE)
+	if err != nil {
+		return nil, err
+	}
+	sdl.PauseAudioDevice(device, false)
+	return &Audio{
+		device:     device,
+		sampleRate: int(obtained.Freq),
+	}, nil
+}
+
+// SampleRate returns the sample rate of the audio device
+func (a *Audio) SampleRate() int {
+	return a.sampleRate
+}
+
+// Queue adds interleaved left and right samples to the end of the playback queue
+func (a *Audio) Queue(samples []int16) error {
+	a.buffer = a.buffer[:0]
+	for _, sample := range samples {
+		a.buffer = append(a.buffer, 0, 0)
+		binary.LittleEndian.PutUint16(a.buffer[len(a.buffer)-2:], uint16(sample))
+	}
+	return sdl.QueueAudio(a.device, a.buffer)
+}
+
+// Queued returns the playback time of samples still in the queue
+func (a *Audio) Queued() time.Duration {
+	// 2 channels with 2 bytes per sample
+	samples := sdl.GetQueuedAudioSize(a.device) / 4
+	return time.Duration(samples) * time.Second / time.Duration(a.sampleRate)
+}
+
+func (a *Audio) Close() {
+	sdl.CloseAudioDevice(a.device)
+}
@@ -4,27 +4,16 @@ import (
 	"github.com/veandco/go-sdl2/sdl"
 )
 
-// Available colors
-const (
-	// 0xFFFFFF
-	ColorWhite = iota
-	// 0xAAAAAA
-	ColorLightGray
-	// 0x555555
-	ColorDarkGray
-	// 0x000000
-	ColorBlack
-)
-
 type Window struct {
 	window   *sdl.Window
 	renderer *sdl.Renderer
+	width    int
 }
 
-func NewWindow(title string, scale int) (*Window, error) {
-	guiWindow := &Window{}
+func NewWindow(title string, width, height, scale int) (*Window, error) {
+	guiWindow := &Window{width: width}
 	window, err := sdl.CreateWindow(title, sdl.WINDOWPOS_UNDEFINED, sdl.WINDOWPOS_UNDEFINED,
-		160*int32(scale), 144*int32(scale), sdl.WINDOW_SHOWN)
+		int32(width*scale), int32(height*scale), sdl.WINDOW_SHOWN)
 	if err != nil {
 		return nil, err
 	}
@@ -51,21 +40,20 @@ func (w *Window) Close() {
 	}
 }
 
-func (w *Window) Draw(buffer []uint8) {
-	for i, val := range buffer {
-		y := i / 160
-		x := i % 160
-		switch val {
-		case ColorBlack:
-			w.renderer.SetDrawColor(0x0f, 0x38, 0x0f, 255)
-		case ColorDarkGray:
-			w.renderer.SetDrawColor(0x30, 0x62, 0x30, 255)
-		case ColorLightGray:
-			w.renderer.SetDrawColor(0x8b, 0xac, 0x0f, 255)
-		case ColorWhite:
-			w.renderer.SetDrawColor(0x9b, 0xbc, 0x0f, 255)
-		}
+// Draw draws a frame of RGB555 colors, the width of the frame is the width
+// of the window
+func (w *Window) Draw(buffer []uint16) {
+	for i, color := range buffer {
+		y := i / w.width
+		x := i % w.width
+		w.renderer.SetDrawColor(expand5(color), expand5(color>>5), expand5(color>>10), 255)
 		w.renderer.DrawPoint(int32(x), int32(y))
 	}
 	w.renderer.Present()
 }
+
+// expand5 scales the lowest 5 bits of c to 8 bits
+func expand5(c uint16) uint8 {
+	c &= 0x1F
+	return uint8(c<<3 | c>>2)
+}
@@ -0,0 +1,52 @@
+package gui
+
+import (
+	"time"
+
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// FrameTime is the time it takes for Gameboy LCD to draw one frame,
+// 70224 cycles at 4.19MHz or 59.73 frames per second
+const FrameTime = time.Second * 70224 / 4194304
+
+// Pacer keeps emulation running at real time speed
+type Pacer interface {
+	// Wait blocks until the next frame should be emulated
+	Wait()
+}
+
+// AudioPacer paces emulation by the amount of queued audio. A new frame is
+// run whenever the queue drops below the target latency, so emulation speed
+// follows the playback speed of the audio device.
+type AudioPacer struct {
+	Audio   *Audio
+	Latency time.Duration
+}
+
+func (p *AudioPacer) Wait() {
+	for p.Audio.Queued() > p.Latency {
+		sdl.Delay(1)
+	}
+}
+
+// System clock used by TimerPacer, replaced in tests
+var (
+	timeNow   = time.Now
+	timeSleep = time.Sleep
+)
+
+// TimerPacer paces emulation to one frame per FrameTime using the system clock
+type TimerPacer struct {
+	next time.Time
+}
+
+func (p *TimerPacer) Wait() {
+	now := timeNow()
+	if p.next.IsZero() || now.Sub(p.next) > FrameTime {
+		// Don't try to catch up after falling behind
+		p.next = now
+	}
+	timeSleep(p.next.Sub(now))
+	p.next = p.next.Add(FrameTime)
+}
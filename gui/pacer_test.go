@@ -0,0 +1,68 @@
+package gui
+
+import (
+	"testing"
+	"time"
+)
+
+// fakeClock replaces the system clock, sleeping advances the time and
+// records how long was slept
+type fakeClock struct {
+	now   time.Time
+	slept []time.Duration
+}
+
+func useFakeClock(t *testing.T) *fakeClock {
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	timeNow = func() time.Time { return clock.now }
+	timeSleep = func(d time.Duration) {
+		clock.slept = append(clock.slept, d)
+		if d > 0 {
+			clock.now = clock.now.Add(d)
+		}
+	}
+	t.Cleanup(func() {
+		timeNow, timeSleep = time.Now, time.Sleep
+	})
+	return clock
+}
+
+func TestTimerPacer(t *testing.T) {
+	clock := useFakeClock(t)
+	p := &TimerPacer{}
+	// First frame runs right away
+	p.Wait()
+	if clock.slept[0] != 0 {
+		t.Errorf("slept %v before the first frame", clock.slept[0])
+	}
+	// Frame taking part of the frame time sleeps for the rest of it
+	clock.now = clock.now.Add(FrameTime / 4)
+	p.Wait()
+	if want := FrameTime - FrameTime/4; clock.slept[1] != want {
+		t.Errorf("slept %v, want %v", clock.slept[1], want)
+	}
+	// Frames are paced from when they should have started, not from when
+	// the sleep ended
+	clock.now = clock.now.Add(time.Millisecond)
+	p.Wait()
+	if want := FrameTime - time.Millisecond; clock.slept[2] != want {
+		t.Errorf("slept %v, want %v", clock.slept[2], want)
+	}
+}
+
+// After falling behind by more than a frame the pacer doesn't run frames
+// back to back to catch up, it continues from the current time
+func TestTimerPacerFallingBehind(t *testing.T) {
+	clock := useFakeClock(t)
+	p := &TimerPacer{}
+	p.Wait()
+	clock.now = clock.now.Add(10 * FrameTime)
+	p.Wait()
+	if clock.slept[1] != 0 {
+		t.Errorf("slept %v after falling behind", clock.slept[1])
+	}
+	p.Wait()
+	if clock.slept[2] != FrameTime {
+		t.Errorf("slept %v after falling behind, want a full frame %v", clock.slept[2], FrameTime)
+	}
+}
@@ -1,19 +1,40 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/MatiasLyyra/goboy/goboy"
 	"github.com/MatiasLyyra/goboy/gui"
 	"github.com/veandco/go-sdl2/sdl"
 )
 
+// How often battery backed RAM is written to disk while running
+const saveInterval = 10 * time.Second
+
+// Amount of audio kept queued when pacing emulation by audio
+const audioLatency = 50 * time.Millisecond
+
 func main() {
-	// f, err := os.Open("/home/malyy/src/gb-test-roms/cpu_instrs/individual/02-interrupts.gb")
-	f, err := os.Open("/home/malyy/src/gb-test-roms/cpu_instrs/cpu_instrs.gb")
-	// f, err := os.Open("/home/malyy/roms/tetris.gb")
+	// romPath := "/home/malyy/src/gb-test-roms/cpu_instrs/individual/02-interrupts.gb"
+	romPath := "/home/malyy/src/gb-test-roms/cpu_instrs/cpu_instrs.gb"
+	// romPath := "/home/malyy/roms/tetris.gb"
+	noAudio := flag.Bool("noaudio", false, "disable audio and pace emulation by timer")
+	bootROMPath := flag.String("bootrom", "", "boot ROM to run before the cartridge")
+	linkListen := flag.String("listen", "", "wait for a link cable connection on address, e.g. :7777")
+	linkConnect := flag.String("connect", "", "connect link cable to an emulator listening on address")
+	printerDir := flag.String("printer", "", "connect Game Boy Printer saving images into directory")
+	modelName := flag.String("model", "", "hardware model: dmg0, dmg, mgb, sgb or cgb (default depends on cartridge)")
+	flag.Parse()
+	if flag.NArg() > 0 {
+		romPath = flag.Arg(0)
+	}
+	f, err := os.Open(romPath)
 	if err != nil {
 		log.Fatalln(err)
 	}
@@ -26,44 +47,84 @@ func main() {
 		panic(err)
 	}
 	fmt.Println(rom)
+	if err := rom.VerifyGlobalChecksum(); err != nil {
+		log.Println(err)
+	}
+	savePath := strings.TrimSuffix(romPath, filepath.Ext(romPath)) + ".sav"
+	if rom.HasBattery() {
+		if err := loadSaveRAM(rom, savePath); err != nil {
+			log.Println(err)
+		}
+		defer func() {
+			if err := writeSaveRAM(rom, savePath); err != nil {
+				log.Println(err)
+			}
+		}()
+	}
 	defer sdl.Quit()
-	w, err := gui.NewWindow("Goyboy", 4)
+	running := true
+	emu := goboy.NewEmulator(rom)
+	if *modelName != "" {
+		model, err := goboy.ParseModel(*modelName)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		emu.Model = model
+		emu.Reset()
+	}
+	if *bootROMPath != "" {
+		if err := loadBootROM(emu, *bootROMPath); err != nil {
+			log.Fatalln(err)
+		}
+	}
+	if *printerDir != "" {
+		printer := goboy.NewPrinter(*printerDir)
+		defer func() {
+			if err := printer.Flush(); err != nil {
+				log.Println(err)
+			}
+		}()
+		emu.SetLinkCable(printer)
+	} else if *linkListen != "" || *linkConnect != "" {
+		cable, err := connectLinkCable(*linkListen, *linkConnect)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		defer cable.Close()
+		emu.SetLinkCable(cable)
+	}
+	width, height := emu.ScreenSize()
+	w, err := gui.NewWindow("Goyboy", width, height, 4)
 	defer w.Close()
 	if err != nil {
 		panic(err)
 	}
-	running := true
-	// sink := make(chan [160 * 144]uint8, 0)
-	// go func() {
-		mmu := goboy.NewMMU(rom)
-		cpu := goboy.CPU{
-			Memory: mmu,
-			PC:     0x0100,
-			SP:     0xFFFE,
+	var (
+		audio *gui.Audio
+		pacer gui.Pacer = &gui.TimerPacer{}
+	)
+	if !*noAudio {
+		audio, err = gui.NewAudio(goboy.DefaultSampleRate)
+		if err != nil {
+			log.Println("Audio disabled:", err)
+		} else {
+			defer audio.Close()
+			emu.SetSampleRate(audio.SampleRate())
+			pacer = &gui.AudioPacer{
+				Audio:   audio,
+				Latency: audioLatency,
+			}
 		}
-		cpu.SetF(0x80)
+	}
 	// debugger := debug.Debugger{
-	// 	CPU: &cpu,
+	// 	Emulator: emu,
 	// 	Breakpoints: map[uint16]struct{}{
 	// 		0x0100: struct{}{},
 	// 	},
 	// }
-	// debug.StartDebugger(debugger, sink)
-		const cyclesInSecond = 4213440
-		// var cycleCount uint64
-	// for {
-
-	// time.Sleep(time.Duration(cycles) * time.Nanosecond)
-
-			// cycleCount += uint64(cycles)
-			// if cycleCount > cyclesInSecond*20 {
-			// 	fmt.Println("Written")
-			// 	ioutil.WriteFile("./vram.bin", mmu.GPU.VRAM[:], 0755)
-			// 	os.Exit(0)
-			// }
-	// }
-	// }()
+	// debug.StartDebugger(debugger)
 	var keys goboy.Keystate
+	lastSave := time.Now()
 	for running {
 		for event := sdl.PollEvent(); event != nil; event = sdl.PollEvent() {
 			switch e := event.(type) {
@@ -72,26 +133,117 @@ func main() {
 				running = false
 				break
 			case *sdl.KeyboardEvent:
+				// Number keys save into a slot, shift + number loads it
+				if sym := e.Keysym.Sym; '0' <= sym && sym <= '9' {
+					slot := int(sym) - '0'
+					if e.Type == sdl.KEYDOWN && e.Repeat == 0 {
+						statePath := fmt.Sprintf("%s.ss%d", strings.TrimSuffix(romPath, filepath.Ext(romPath)), slot)
+						if e.Keysym.Mod&sdl.KMOD_SHIFT != 0 {
+							err = loadState(emu, statePath)
+						} else {
+							err = saveState(emu, statePath)
+						}
+						if err != nil {
+							log.Println(err)
+						}
+					}
+					break
+				}
 				switch e.Type {
 				case sdl.KEYDOWN, sdl.KEYUP:
 					updateKeystate(&keys, e)
 				}
 			}
 		}
-		var drawCount int
-		for drawCount < 10 {
-			mmu.Pad.Update(keys)
-			cycles := cpu.RunSingleOpcode()
-			hasDrawn := mmu.GPU.Run(cycles)
-			if hasDrawn {
-				drawCount++
+		emu.SetInput(keys)
+		pacer.Wait()
+		emu.RunFrame()
+		samples := emu.AudioSamples()
+		if audio != nil {
+			if err := audio.Queue(samples); err != nil {
+				log.Println(err)
+			}
+		}
+		w.Draw(emu.Framebuffer())
+		if rom.HasBattery() && time.Since(lastSave) >= saveInterval {
+			if err := writeSaveRAM(rom, savePath); err != nil {
+				log.Println(err)
 			}
+			lastSave = time.Now()
 		}
-		w.Draw(mmu.GPU.ScreenBuffer())
 		// <-sink
 	}
 }
 
+func saveState(emu *goboy.Emulator, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := emu.SaveState(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func loadState(emu *goboy.Emulator, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return emu.LoadState(f)
+}
+
+func loadBootROM(emu *goboy.Emulator, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return emu.LoadBootROM(f)
+}
+
+// connectLinkCable waits for a connection when listen is set and
+// otherwise connects to the given address
+func connectLinkCable(listen, connect string) (*goboy.TCPLinkCable, error) {
+	if listen != "" {
+		log.Println("Waiting for link cable connection on", listen)
+		return goboy.ListenLinkCable(listen)
+	}
+	return goboy.DialLinkCable(connect)
+}
+
+func loadSaveRAM(rom *goboy.Cartridge, path string) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+	return rom.LoadRAM(f)
+}
+
+// writeSaveRAM writes RAM into a temporary file first so that a crash
+// during writing doesn't destroy the previous save
+func writeSaveRAM(rom *goboy.Cartridge, path string) error {
+	tmpPath := path + ".tmp"
+	f, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	if err := rom.SaveRAM(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
 func updateKeystate(keyState *goboy.Keystate, keyEvent *sdl.KeyboardEvent) {
 	var state bool
 	if keyEvent.Type == sdl.KEYDOWN {